@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+	"github.com/ravishi/go-cast/pkg/cast/discovery"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	listCommand  = kingpin.Command("list", "List the devices on the network.")
+	listWaitFlag = listCommand.Flag("wait", "How long to look for devices.").Default("3s").Duration()
+
+	pauseCommand  = kingpin.Command("pause", "Pause the media.")
+	resumeCommand = kingpin.Command("resume", "Resume the media.")
+	stopCommand   = kingpin.Command("stop", "Stop the media.")
+
+	seekCommand = kingpin.Command("seek", "Seek the media.")
+	seekArg     = seekCommand.Arg("position", "Where to seek to: a time, such as 90, 1:30 or 1m30s, a step from the current position, such as +30s or -1:00 (after --), or a percentage, such as 50%.").Required().String()
+
+	volumeCommand = kingpin.Command("volume", "Set the volume of the device.")
+	volumeArg     = volumeCommand.Arg("level", "The volume, from 0 to 100, or a step, such as +5 or -10 (after --).").Required().String()
+
+	muteCommand = kingpin.Command("mute", "Mute or unmute the device.")
+	muteArg     = muteCommand.Arg("state", "on, off or toggle.").Default("on").Enum("on", "off", "toggle")
+
+	statusCommand = kingpin.Command("status", "Show what the device is doing.")
+
+	launchCommand = kingpin.Command("launch", "Launch an app, unless it's running already.")
+	launchArg     = launchCommand.Arg("appId", "The ID of the app.").Required().String()
+)
+
+// list prints the devices found until the wait is over.
+func list(ctx context.Context, discoverer discovery.Discoverer) error {
+	ctx, cancel := context.WithTimeout(ctx, *listWaitFlag)
+	defer cancel()
+
+	devices := []*deviceOutput{}
+	for e := range discoverer.Watch(ctx) {
+		if e.Type != discovery.DeviceAdded {
+			continue
+		}
+		d := e.Device
+		if jsonOutput() {
+			devices = append(devices, newDeviceOutput(&d))
+		} else {
+			fmt.Printf("%s\t%s\t%s\t%s\n", d.Name, d.Model, d.Addr(), d.ID)
+		}
+	}
+	if ctx.Err() == context.Canceled {
+		return context.Canceled
+	}
+	if jsonOutput() {
+		return printJSON(devices)
+	}
+	return nil
+}
+
+// current attaches to whatever app runs on the device, and returns the
+// status of its media.
+func current(ctx context.Context, s *session) (*ctrl.MediaStatusEvent, error) {
+	if err := s.attach(ctx, "", false); err != nil {
+		return nil, err
+	}
+	return s.mediaStatus(ctx)
+}
+
+func pause(ctx context.Context, s *session) error {
+	e, err := current(ctx, s)
+	if err != nil {
+		return err
+	} else if !e.Status.CanPause() {
+		return errors.New("The media can't be paused")
+	}
+	statuses, err := s.media.Pause(ctx)
+	if err != nil {
+		return err
+	}
+	return printMedia(s, statuses)
+}
+
+func resume(ctx context.Context, s *session) error {
+	if _, err := current(ctx, s); err != nil {
+		return err
+	}
+	statuses, err := s.media.Play(ctx)
+	if err != nil {
+		return err
+	}
+	return printMedia(s, statuses)
+}
+
+func stop(ctx context.Context, s *session) error {
+	if _, err := current(ctx, s); err != nil {
+		return err
+	}
+	statuses, err := s.media.Stop(ctx)
+	if err != nil {
+		return err
+	}
+	return printMedia(s, statuses)
+}
+
+func seek(ctx context.Context, s *session) error {
+	e, err := current(ctx, s)
+	if err != nil {
+		return err
+	} else if !e.Status.CanSeek() {
+		return errors.New("The media can't be seeked")
+	}
+
+	position, err := seekPosition(*seekArg, e)
+	if err != nil {
+		return err
+	}
+	statuses, err := s.media.Seek(ctx, position, "")
+	if err != nil {
+		return err
+	}
+	return printMedia(s, statuses)
+}
+
+// seekPosition works out where arg asks to seek to, given the current
+// status of the media.
+func seekPosition(arg string, e *ctrl.MediaStatusEvent) (ctrl.Duration, error) {
+	duration := e.Status.Duration()
+
+	var position ctrl.Duration
+	if strings.HasSuffix(arg, "%") {
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return 0, errors.New("Invalid percentage " + arg)
+		} else if duration == ctrl.UnknownDuration {
+			return 0, errors.New("The length of the media is unknown")
+		}
+		position = ctrl.Duration(float64(duration) * percent / 100)
+	} else if strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-") {
+		step, err := parseTime(arg[1:])
+		if err != nil {
+			return 0, err
+		}
+		if arg[0] == '-' {
+			step = -step
+		}
+		position = e.EstimatedPosition(time.Now()) + step
+	} else {
+		t, err := parseTime(arg)
+		if err != nil {
+			return 0, err
+		}
+		position = t
+	}
+
+	if position < 0 {
+		position = 0
+	} else if duration != ctrl.UnknownDuration && position > duration {
+		position = duration
+	}
+	return position, nil
+}
+
+// parseTime parses a time as seconds, as [[hh:]mm:]ss or as a Go duration.
+func parseTime(s string) (ctrl.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil && strings.IndexFunc(s, isUnit) >= 0 {
+		return ctrl.Duration(d), nil
+	}
+
+	parts := strings.Split(s, ":")
+	if len(parts) > 3 {
+		return 0, errors.New("Invalid time " + s)
+	}
+	var seconds float64
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 || (i > 0 && n >= 60) {
+			return 0, errors.New("Invalid time " + s)
+		}
+		seconds = seconds*60 + n
+	}
+	return ctrl.Seconds(seconds), nil
+}
+
+func isUnit(r rune) bool {
+	return r >= 'a' && r <= 'z'
+}
+
+// volume sets the volume of the device, rather than that of the media,
+// which works whatever runs on it.
+func volume(ctx context.Context, s *session) error {
+	arg := *volumeArg
+	n, err := strconv.ParseFloat(strings.TrimSuffix(arg, "%"), 64)
+	if err != nil {
+		return errors.New("Invalid volume " + arg)
+	}
+	level := n / 100
+
+	if strings.HasPrefix(arg, "+") || strings.HasPrefix(arg, "-") {
+		status, err := s.receiver.GetStatus(ctx)
+		if err != nil {
+			return err
+		} else if status.Volume == nil {
+			return errors.New("The device didn't tell its volume")
+		}
+		level += status.Volume.Level
+	}
+	level = math.Max(0, math.Min(1, level))
+
+	status, err := s.receiver.SetVolume(ctx, level)
+	if err != nil {
+		return err
+	} else if jsonOutput() {
+		return printReceiver(s, status)
+	}
+	if status.Volume != nil {
+		fmt.Printf("Volume: %.0f%%\n", status.Volume.Level*100)
+	}
+	return nil
+}
+
+func mute(ctx context.Context, s *session) error {
+	muted := *muteArg == "on"
+	if *muteArg == "toggle" {
+		status, err := s.receiver.GetStatus(ctx)
+		if err != nil {
+			return err
+		} else if status.Volume == nil {
+			return errors.New("The device didn't tell its volume")
+		}
+		muted = !status.Volume.Muted
+	}
+	status, err := s.receiver.SetMuted(ctx, muted)
+	if err != nil {
+		return err
+	}
+	return printReceiver(s, status)
+}
+
+func status(ctx context.Context, s *session) error {
+	status, err := s.receiver.GetStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	var media *ctrl.MediaStatusEvent
+	if err := s.attach(ctx, "", false); err != nil && err != NothingPlaying {
+		return err
+	} else if err == nil {
+		if media, err = s.mediaStatus(ctx); err != nil && err != NothingPlaying {
+			return err
+		}
+	}
+
+	if jsonOutput() {
+		r := report{Device: newDeviceOutput(s.info), Receiver: status}
+		if media != nil {
+			r.Media = &media.Status
+			r.Media.CurrentTime = media.EstimatedPosition(time.Now())
+		}
+		return printJSON(r)
+	}
+
+	if status.Volume != nil {
+		fmt.Printf("Volume: %.0f%%", status.Volume.Level*100)
+		if status.Volume.Muted {
+			fmt.Print(" (muted)")
+		}
+		fmt.Println()
+	}
+	for _, app := range status.Applications {
+		fmt.Printf("App: %s (%s)", app.DisplayName, app.AppID)
+		if app.StatusText != "" {
+			fmt.Printf(": %s", app.StatusText)
+		}
+		fmt.Println()
+	}
+	if media == nil {
+		return nil
+	}
+
+	if info := media.Status.Media; info != nil {
+		if title := mediaTitle(info); title != "" {
+			fmt.Println("Title:", title)
+		}
+		fmt.Println("Media:", info.ContentID)
+	}
+	fmt.Println("State:", media.Status.PlayerState)
+	fmt.Printf("Position: %s", formatTime(media.EstimatedPosition(time.Now())))
+	if d := media.Status.Duration(); d != ctrl.UnknownDuration {
+		fmt.Printf(" / %s", formatTime(d))
+	}
+	fmt.Println()
+	return nil
+}
+
+// mediaTitle returns the title in the metadata of media, if any.
+func mediaTitle(media *ctrl.MediaInfo) string {
+	switch m := media.Metadata.(type) {
+	case ctrl.GenericMediaMetadata:
+		return m.Title
+	case ctrl.MovieMediaMetadata:
+		return m.Title
+	case ctrl.TvShowMediaMetadata:
+		return m.Title
+	case ctrl.MusicTrackMediaMetadata:
+		return m.Title
+	case ctrl.PhotoMediaMetadata:
+		return m.Title
+	case ctrl.RawMediaMetadata:
+		title, _ := m.Fields["title"].(string)
+		return title
+	}
+	return ""
+}
+
+func formatTime(d ctrl.Duration) string {
+	t := time.Duration(d).Round(time.Second)
+	h, m, sec := int(t.Hours()), int(t.Minutes())%60, int(t.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, sec)
+	}
+	return fmt.Sprintf("%d:%02d", m, sec)
+}
+
+func launch(ctx context.Context, s *session) error {
+	appId := *launchArg
+	status, err := s.receiver.GetStatus(ctx)
+	if err != nil {
+		return err
+	}
+	message := "Already running:"
+	if !isRunning(status, appId) {
+		status, err = s.receiver.Launch(ctx, appId)
+		if err != nil {
+			return launchFailed(err)
+		} else if !isRunning(status, appId) {
+			return failed(exitLaunchError, "Apparently we couldnt launch", nil)
+		}
+		message = "Launched:"
+	}
+
+	if jsonOutput() {
+		return printReceiver(s, status)
+	}
+	for _, app := range status.Applications {
+		if app.AppID == appId {
+			fmt.Println(message, app.DisplayName)
+		}
+	}
+	return nil
+}
+
+func isRunning(status *ctrl.ReceiverStatus, appId string) bool {
+	for _, app := range status.Applications {
+		if app.AppID == appId {
+			return true
+		}
+	}
+	return false
+}
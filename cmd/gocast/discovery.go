@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ravishi/go-cast/pkg/cast/discovery"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var (
+	deviceFlag      = kingpin.Flag("device", "The name or UUID of the device to use, instead of the first one found.").Short('d').String()
+	timeoutFlag     = kingpin.Flag("timeout", "How long to look for the device.").Default("10s").Duration()
+	noMDNSFlag      = kingpin.Flag("no-mdns", "Don't look for devices over multicast DNS.").Bool()
+	bonjourFlag     = kingpin.Flag("bonjour", "Look for devices over multicast DNS with the bonjour resolver, which doesn't see them change or go away.").Bool()
+	dnsServerFlag   = kingpin.Flag("dns-server", "Look for devices with unicast DNS-SD on this DNS server, as host[:port].").String()
+	dnsDomainFlag   = kingpin.Flag("dns-domain", "The domain to look for devices in on the DNS server.").String()
+	deviceAddrFlag  = kingpin.Flag("device-addr", "A device to use without looking for it, as [name=]host[:port].").Strings()
+	devicesFileFlag = kingpin.Flag("devices-file", "A file of devices to use without looking for them, one [name=]host[:port] per line.").ExistingFile()
+)
+
+// newDiscoverer sets up the discoverers the flags ask for, the static ones
+// first, so that their devices win over the same ones found otherwise.
+func newDiscoverer() (discovery.Discoverer, error) {
+	var static []discovery.Device
+	if *devicesFileFlag != "" {
+		devices, err := discovery.ReadDevices(*devicesFileFlag)
+		if err != nil {
+			return nil, err
+		}
+		static = append(static, devices...)
+	}
+	for _, spec := range *deviceAddrFlag {
+		d, err := discovery.ParseDevice(spec)
+		if err != nil {
+			return nil, err
+		}
+		static = append(static, d)
+	}
+
+	var discoverers []discovery.Discoverer
+	if len(static) > 0 {
+		discoverers = append(discoverers, discovery.NewStatic(static...))
+	}
+	if *dnsServerFlag != "" {
+		if *dnsDomainFlag == "" {
+			return nil, errors.New("--dns-server needs a --dns-domain")
+		}
+		discoverers = append(discoverers, discovery.NewUnicast(*dnsServerFlag, *dnsDomainFlag, 0))
+	}
+	if !*noMDNSFlag {
+		var browser discovery.Discoverer
+		var err error
+		if *bonjourFlag {
+			browser, err = discovery.NewBonjour(nil)
+		} else {
+			browser, err = discovery.NewBrowser(nil)
+		}
+		if err != nil && len(discoverers) == 0 {
+			return nil, err
+		} else if err == nil {
+			discoverers = append(discoverers, browser)
+		}
+	}
+	if len(discoverers) == 0 {
+		return nil, errors.New("No way to look for devices left")
+	}
+	return discovery.Merge(discoverers...), nil
+}
+
+// findDevice waits for the device the flags ask for, or for the first one
+// found. Running out of time means there's no such device: discoverers only
+// tell that when they're closed.
+func findDevice(ctx context.Context, discoverer discovery.Discoverer) (*discovery.Device, error) {
+	ctx, cancel := context.WithTimeout(ctx, *timeoutFlag)
+	defer cancel()
+
+	if *deviceFlag != "" {
+		d, err := discoverer.Lookup(ctx, *deviceFlag)
+		if err == context.DeadlineExceeded {
+			return nil, failed(exitDeviceNotFound, "No device found matching "+*deviceFlag, nil)
+		}
+		return d, err
+	}
+	for e := range discoverer.Watch(ctx) {
+		if e.Type == discovery.DeviceAdded {
+			d := e.Device
+			return &d, nil
+		}
+	}
+	if err := ctx.Err(); err == context.DeadlineExceeded {
+		return nil, failed(exitDeviceNotFound, "No device found", nil)
+	} else if err != nil {
+		return nil, err
+	}
+	return nil, discovery.DeviceNotFound
+}
@@ -2,177 +2,212 @@ package main
 
 import (
 	"context"
-	"crypto/tls"
 	"errors"
 	"fmt"
-	"log"
+	"net"
 	"os"
 	"os/signal"
-	"time"
 
-	"github.com/oleksandr/bonjour"
-	"github.com/ravishi/go-cast/pkg/cast"
 	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+	"github.com/ravishi/go-cast/pkg/cast/playlist"
 	"gopkg.in/alecthomas/kingpin.v2"
 )
 
 var (
-	urlFlag         = kingpin.Flag("url", "Stream URL.").Short('u').Required().URL()
-	titleFlag       = kingpin.Flag("title", "The title of the stream.").Short('t').String()
-	contentTypeFlag = kingpin.Flag("content-type", "The content-type of the stream.").Short('c').String()
+	urlFlag           = kingpin.Flag("url", "Stream URL. Deprecated: pass it to play instead.").Short('u').String()
+	playCommand       = kingpin.Command("play", "Play a local file or a URL.").Default()
+	mediaArg          = playCommand.Arg("media", "The file, URL, playlist or directory to play, or - to stream standard input live.").String()
+	pipeFlag          = playCommand.Flag("pipe", "Stream a named pipe live.").String()
+	titleFlag         = playCommand.Flag("title", "The title of the stream.").Short('t').String()
+	contentTypeFlag   = playCommand.Flag("content-type", "The content-type of the stream.").Short('c').String()
+	subtitlesFlag     = playCommand.Flag("subtitles", "A subtitle file (SRT, ASS or WebVTT) to add to the media.").Short('s').ExistingFiles()
+	noSubtitlesFlag   = playCommand.Flag("no-subtitles", "Don't look for subtitles next to local files.").Bool()
+	subtitleDelayFlag = playCommand.Flag("subtitle-delay", "Delay subtitles by this much, e.g. 1.5s or -500ms.").Duration()
+	headerFlag        = playCommand.Flag("header", "A header to fetch the URL with, e.g. \"Authorization: Bearer x\". Relays the media.").Short('H').Strings()
+	cookieFlag        = playCommand.Flag("cookie", "A cookie to fetch the URL with. Relays the media.").String()
+	refererFlag       = playCommand.Flag("referer", "A referer to fetch the URL with. Relays the media.").String()
+	relayFlag         = playCommand.Flag("relay", "Relay the URL through gocast even without headers.").Bool()
+	repeatFlag        = playCommand.Flag("repeat", "Repeat a playlist: off, all or single.").Default("off").Enum("off", "all", "single")
+	shuffleFlag       = playCommand.Flag("shuffle", "Shuffle a playlist.").Bool()
 )
 
 func main() {
 	kingpin.UsageTemplate(kingpin.CompactUsageTemplate).Author("Dirley Rodrigues")
 	kingpin.CommandLine.Help = "A simple command line player for your Chromecast."
-	kingpin.Parse()
-	err := actualMain()
+	command := kingpin.Parse()
+	if *urlFlag != "" {
+		if command != playCommand.FullCommand() || *mediaArg != "" {
+			kingpin.Fatalf("--url only goes with play, and instead of its media")
+		}
+		*mediaArg = *urlFlag
+	}
+	if command == playCommand.FullCommand() && *mediaArg == "" && *pipeFlag == "" {
+		kingpin.Fatalf("required argument 'media' not provided, try --help")
+	}
+	err := actualMain(command)
 	if err == nil || err == context.Canceled {
 		os.Exit(0)
 	} else {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
 
-func actualMain() error {
-	resolver, err := bonjour.NewResolver(nil)
-	if err != nil {
-		return err
-	}
-	defer func() { resolver.Exit <- true }()
-
-	services := make(chan *bonjour.ServiceEntry)
-
-	err = resolver.Browse("_googlecast._tcp", ".local", services)
+func actualMain(command string) error {
+	discoverer, err := newDiscoverer()
 	if err != nil {
 		return err
 	}
+	defer discoverer.Close()
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt, os.Kill)
 	defer signal.Stop(sigCh)
 
 	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
 	go func() {
 		select {
 		case <-sigCh:
 			cancel()
+		case <-ctx.Done():
 		}
 	}()
 
-	for {
-		fmt.Println("Searching devices...")
-
-		select {
-		case service, ok := <-services:
-			if ok {
-				fmt.Println("Found device:", service.Instance)
-				err := consumeService(service, ctx)
-				if err != nil {
-					return err
-				} else {
-					log.Println("Device disconnected:", service.Instance)
-				}
-			} else {
-				return errors.New("Discovery closed unexpectedly")
-			}
-		case <-ctx.Done():
-			return ctx.Err()
-		}
+	if command == listCommand.FullCommand() {
+		return list(ctx, discoverer)
 	}
-}
 
-func consumeService(service *bonjour.ServiceEntry, ctx context.Context) error {
-	addr := fmt.Sprintf("%s:%d", service.AddrIPv4, service.Port)
-
-	conn, err := tls.Dial("tcp", addr, &tls.Config{
-		InsecureSkipVerify: true,
-	})
+	fmt.Fprintln(os.Stderr, "Searching devices...")
+	d, err := findDevice(ctx, discoverer)
 	if err != nil {
-		return fmt.Errorf("Failed to connect: %s", err)
+		return err
 	}
-	defer conn.Close()
-
-	device := cast.NewDevice(conn)
-	defer device.Close()
-
-	go device.Run()
-
-	// Send a connect in 2s
-	connection := ctrl.NewConnectionController(device, "sender-0", "receiver-0")
-	defer connection.Close()
+	fmt.Fprintln(os.Stderr, "Found device:", d.Name)
 
-	err = connection.Connect()
+	s, err := connect(d)
 	if err != nil {
-		return fmt.Errorf("Failed to connect: %s", err)
+		return err
 	}
+	defer s.Close()
+
+	switch command {
+	case playCommand.FullCommand():
+		return play(ctx, s)
+	case pauseCommand.FullCommand():
+		return pause(ctx, s)
+	case resumeCommand.FullCommand():
+		return resume(ctx, s)
+	case stopCommand.FullCommand():
+		return stop(ctx, s)
+	case seekCommand.FullCommand():
+		return seek(ctx, s)
+	case volumeCommand.FullCommand():
+		return volume(ctx, s)
+	case muteCommand.FullCommand():
+		return mute(ctx, s)
+	case statusCommand.FullCommand():
+		return status(ctx, s)
+	case launchCommand.FullCommand():
+		return launch(ctx, s)
+	case watchCommand.FullCommand():
+		return watch(ctx, s)
+	}
+	return errors.New("Unknown command " + command)
+}
 
-	heartbeat := ctrl.NewHeartbeatController(device, "sender-0", "receiver-0")
-	defer heartbeat.Close()
-
-	// Beat until it fails
-	heartbeatError := make(chan error)
-	go func() {
-		// send a PING every 5s, bail after 10.
-		err := heartbeat.Beat(time.Second*5, 2)
-		if err != nil {
-			heartbeatError <- err
-		}
-	}()
-
-	receiver := ctrl.NewReceiverController(device, "sender-0", "receiver-0")
-	defer receiver.Close()
+// play loads the media on the Default Media Receiver, launching it unless
+// it's running already, and waits for the media to end.
+func play(ctx context.Context, s *session) error {
+	if err := s.attach(ctx, defaultMediaReceiver, true); err != nil {
+		return err
+	}
 
-	appId := "CC1AD845"
-	sourceId := "client-123"
+	host, _, _ := net.SplitHostPort(s.addr)
+	source := &mediaSource{deviceHost: host}
+	defer source.Close()
 
-	status, err := receiver.Launch(appId)
-	if err != nil {
-		return fmt.Errorf("Failed to launch: %s", err)
+	it := item{
+		source:      *mediaArg,
+		pipe:        *mediaArg == "-" || *pipeFlag != "",
+		contentType: *contentTypeFlag,
+		title:       *titleFlag,
+		subtitles:   *subtitlesFlag,
+	}
+	if *pipeFlag != "" {
+		it.source = *pipeFlag
 	}
 
-	session := new(ctrl.ApplicationSession)
-	for _, s := range status.Applications {
-		if s.AppID == appId {
-			session = &s
-			break
+	var entries []playlist.Entry
+	var err error
+	if !it.pipe {
+		entries, err = readPlaylist(it.source)
+		if err != nil {
+			return err
 		}
 	}
 
-	if session == nil {
-		return errors.New("Apparently we couldnt launch")
+	var queue []ctrl.QueueItem
+	var mediaInfo ctrl.MediaInfo
+	if entries != nil {
+		queue, err = source.queueItems(ctx, entries)
+	} else {
+		mediaInfo, err = source.mediaInfo(ctx, it)
 	}
-
-	mediaConnection := ctrl.NewConnectionController(device, sourceId, session.TransportId)
-	defer mediaConnection.Close()
-
-	err = mediaConnection.Connect()
 	if err != nil {
-		return fmt.Errorf("Failed to connect to the media receiver: %s", err)
+		return err
 	}
 
-	mediaInfo := ctrl.MediaInfo{
-		ContentID:   (*urlFlag).String(),
-		ContentType: *contentTypeFlag,
-		StreamType:  ctrl.StreamTypeBuffered,
-		Metadata: map[string]interface{}{
-			"type":         0,
-			"metadataType": 0,
-			"title":        *titleFlag,
-		},
-	}
+	media := s.media.Media
+	events := media.Watch(ctx)
 
-	media := ctrl.NewMediaController(device, sourceId, session.TransportId)
-	_, err = media.Load(mediaInfo, ctrl.LoadOptions{
-		AutoPlay: true,
-	})
+	var loaded []ctrl.MediaStatus
+	if queue != nil {
+		loaded, err = loadQueue(ctx, media, queue)
+	} else {
+		loaded, err = media.Load(ctx, mediaInfo, ctrl.LoadOptions{
+			AutoPlay:       true,
+			ActiveTrackIDs: activeTracks(mediaInfo),
+		})
+	}
 	if err != nil {
-		return fmt.Errorf("Error while loading media: %s", err)
+		return failed(exitLoadFailed, "Error while loading media", err)
+	} else if len(loaded) == 0 {
+		return failed(exitLoadFailed, "The device didn't start a media session", nil)
+	}
+	if err := printMedia(s, loaded); err != nil {
+		return err
 	}
 
-	select {
-	case <-ctx.Done():
-		return nil
+	return waitForEnd(ctx, events, loaded[0].MediaSessionID, s.heartbeatError)
+}
+
+// waitForEnd waits until the media session ends.
+func waitForEnd(ctx context.Context, events <-chan ctrl.MediaStatusEvent, sessionId int, heartbeatError <-chan error) error {
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return errors.New("Lost the connection to the media receiver")
+			}
+			if e.Status.MediaSessionID != sessionId || e.Status.PlayerState != ctrl.PlayerStateIdle {
+				continue
+			}
+			switch e.Status.IdleReason {
+			case ctrl.IdleReasonError:
+				return failed(exitLoadFailed, "The device failed to play the media", nil)
+			case "":
+				continue
+			}
+			if e.Status.LoadingItemID != 0 {
+				// An item of a queue ended, and the next one is on its way.
+				continue
+			}
+			return nil
+		case err := <-heartbeatError:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
 	}
 }
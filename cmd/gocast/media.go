@@ -0,0 +1,388 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+	"github.com/ravishi/go-cast/pkg/cast/probe"
+	"github.com/ravishi/go-cast/pkg/cast/serve"
+	"github.com/ravishi/go-cast/pkg/cast/subtitles"
+	"github.com/ravishi/go-cast/pkg/cast/tags"
+)
+
+// pipeSniffSize is how much of a piped stream we look at to tell its format.
+const pipeSniffSize = 4096
+
+// probeTimeout bounds the requests we make to find out what a URL serves.
+const probeTimeout = 10 * time.Second
+
+// mediaSource builds the MediaInfo to load a URL or a local file. Local
+// files, and subtitles, are published on a server facing the device.
+type mediaSource struct {
+	deviceHost string
+	server     *serve.Server
+}
+
+// Close stops the server, if we needed one. Call it once playback ends.
+func (s *mediaSource) Close() {
+	if s.server != nil {
+		s.server.Close()
+	}
+}
+
+func (s *mediaSource) serve() (*serve.Server, error) {
+	if s.server == nil {
+		server, err := serve.Listen(s.deviceHost)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to start the media server: %s", err)
+		}
+		s.server = server
+	}
+	return s.server, nil
+}
+
+// item is something to play, along with what the command line, or the
+// playlist it's in, says about it.
+type item struct {
+	// source is a URL, the path of a local file, or a pipe.
+	source      string
+	pipe        bool
+	contentType string
+	// title wins over the tags of the media, fallbackTitle doesn't.
+	title         string
+	fallbackTitle string
+	subtitles     []string
+}
+
+func (s *mediaSource) mediaInfo(ctx context.Context, it item) (ctrl.MediaInfo, error) {
+	mediaInfo := ctrl.MediaInfo{
+		ContentType:    it.contentType,
+		StreamType:     ctrl.StreamTypeBuffered,
+		StreamDuration: ctrl.UnknownDuration,
+	}
+	source := it.source
+	title := it.title
+	if title == "" {
+		title = it.fallbackTitle
+	}
+	var subtitleFiles []subtitles.File
+	for _, path := range it.subtitles {
+		subtitleFiles = append(subtitleFiles, subtitles.File{
+			Path:   path,
+			Format: subtitles.FormatOf(path),
+		})
+	}
+
+	if it.pipe {
+		if err := s.stream(&mediaInfo, source); err != nil {
+			return mediaInfo, err
+		}
+		if title == "" && source == "-" {
+			title = "Live stream"
+		} else if title == "" {
+			title = filepath.Base(source)
+		}
+	} else if u, err := url.Parse(source); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		mediaInfo.ContentID = u.String()
+
+		header, err := requestHeader()
+		if err != nil {
+			return mediaInfo, err
+		}
+		s.probe(ctx, &mediaInfo, header)
+
+		if len(header) > 0 || *relayFlag {
+			server, err := s.serve()
+			if err != nil {
+				return mediaInfo, err
+			}
+			file, err := server.Relay(mediaInfo.ContentID, header)
+			if err != nil {
+				return mediaInfo, err
+			}
+			mediaInfo.ContentID = file.URL
+		}
+		if title == "" {
+			title = path.Base(u.Path)
+		}
+	} else {
+		if _, err := os.Stat(source); err != nil {
+			return mediaInfo, err
+		}
+
+		server, err := s.serve()
+		if err != nil {
+			return mediaInfo, err
+		}
+
+		file, err := server.Publish(source)
+		if err != nil {
+			return mediaInfo, err
+		}
+
+		mediaInfo.ContentID = file.URL
+		if mediaInfo.ContentType == "" {
+			mediaInfo.ContentType = file.ContentType
+		}
+		if title == "" {
+			title = strings.TrimSuffix(filepath.Base(source), filepath.Ext(source))
+		}
+
+		fileTags, err := tags.Read(source)
+		if err == nil {
+			metadata, err := s.metadata(fileTags, it.title, title)
+			if err != nil {
+				return mediaInfo, err
+			}
+			mediaInfo.Metadata = metadata
+			if fileTags.Duration > 0 {
+				mediaInfo.StreamDuration = ctrl.Duration(fileTags.Duration)
+			}
+		} else if err != tags.UnknownFormat {
+			log.Println("Failed to read the tags of the media:", err)
+		}
+
+		if !*noSubtitlesFlag {
+			found, err := subtitles.Find(source)
+			if err != nil {
+				log.Println("Failed to look for subtitles:", err)
+			}
+			subtitleFiles = append(subtitleFiles, found...)
+		}
+	}
+
+	if mediaInfo.Metadata == nil {
+		mediaInfo.Metadata = ctrl.GenericMediaMetadata{Title: title}
+	}
+
+	for _, f := range subtitleFiles {
+		track, err := s.subtitleTrack(f, int64(len(mediaInfo.MediaTracks)+1))
+		if err != nil {
+			return mediaInfo, fmt.Errorf("Failed to load subtitles from %s: %s", f.Path, err)
+		}
+		mediaInfo.MediaTracks = append(mediaInfo.MediaTracks, track)
+	}
+
+	return mediaInfo, nil
+}
+
+// probe fills in what the URL of mediaInfo serves. A content type given
+// on the command line wins over the one we find.
+func (s *mediaSource) probe(ctx context.Context, mediaInfo *ctrl.MediaInfo, header http.Header) {
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	prober := &probe.Prober{Header: header}
+	result, err := prober.Probe(ctx, mediaInfo.ContentID)
+	if err != nil {
+		log.Println("Failed to probe the media:", err)
+		if mediaInfo.ContentType == "" {
+			u, _ := url.Parse(mediaInfo.ContentID)
+			mediaInfo.ContentType = serve.ContentType(u.Path)
+		}
+		return
+	}
+
+	if mediaInfo.ContentType == "" {
+		mediaInfo.ContentType = result.ContentType
+	}
+	mediaInfo.StreamType = result.StreamType
+	if result.StreamType == ctrl.StreamTypeLive {
+		mediaInfo.StreamDuration = ctrl.UnknownDuration
+	} else if result.Duration > 0 {
+		mediaInfo.StreamDuration = ctrl.Duration(result.Duration)
+	}
+	mediaInfo.HLSSegmentFormat = result.HLSSegmentFormat
+	mediaInfo.HLSVideoSegmentFormat = result.HLSVideoSegmentFormat
+
+	for _, w := range result.Warnings {
+		log.Println("Warning:", w)
+	}
+}
+
+// stream publishes standard input, or the pipe at source, as a live stream.
+func (s *mediaSource) stream(mediaInfo *ctrl.MediaInfo, source string) (err error) {
+	var r io.Reader = os.Stdin
+	if source != "-" {
+		pipe, err := os.Open(source)
+		if err != nil {
+			return err
+		}
+		r = closingReader{pipe}
+	}
+	defer func() {
+		// Once published, the stream closes the pipe when it ends.
+		if c, ok := r.(closingReader); ok && err != nil {
+			c.f.Close()
+		}
+	}()
+
+	// Tell the container from the first bytes, which also waits for the
+	// stream to start.
+	buffered := bufio.NewReaderSize(r, pipeSniffSize)
+	head, err := buffered.Peek(pipeSniffSize)
+	if len(head) == 0 {
+		return fmt.Errorf("Failed to read the stream: %s", err)
+	}
+	if mediaInfo.ContentType == "" {
+		mediaInfo.ContentType = probe.Sniff(head)
+	}
+	if mediaInfo.ContentType == "" {
+		return errors.New("Failed to tell the format of the stream, try --content-type")
+	}
+
+	server, err := s.serve()
+	if err != nil {
+		return err
+	}
+	file, err := server.PublishStream("live", mediaInfo.ContentType, buffered)
+	if err != nil {
+		return err
+	}
+
+	mediaInfo.ContentID = file.URL
+	mediaInfo.StreamType = ctrl.StreamTypeLive
+	mediaInfo.StreamDuration = ctrl.UnknownDuration
+	return nil
+}
+
+// closingReader closes its file once reading it fails, which is when the
+// stream ends.
+type closingReader struct {
+	f *os.File
+}
+
+func (r closingReader) Read(p []byte) (int, error) {
+	n, err := r.f.Read(p)
+	if err != nil {
+		r.f.Close()
+	}
+	return n, err
+}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// requestHeader returns the headers to fetch URLs with, as given on the
+// command line.
+func requestHeader() (http.Header, error) {
+	header := make(http.Header)
+	for _, h := range *headerFlag {
+		i := strings.IndexByte(h, ':')
+		if i <= 0 {
+			return nil, fmt.Errorf("Invalid header %q, expected \"Name: value\"", h)
+		}
+		header.Add(strings.TrimSpace(h[:i]), strings.TrimSpace(h[i+1:]))
+	}
+	if *cookieFlag != "" {
+		header.Add("Cookie", *cookieFlag)
+	}
+	if *refererFlag != "" {
+		header.Set("Referer", *refererFlag)
+	}
+	return header, nil
+}
+
+// metadata describes a local file from its tags. An explicit title wins
+// over the one in the tags, which wins over fallback.
+func (s *mediaSource) metadata(t *tags.Tags, title, fallback string) (ctrl.MediaMetadata, error) {
+	if title == "" {
+		title = t.Title
+	}
+	if title == "" {
+		title = fallback
+	}
+
+	var images []ctrl.Image
+	if t.Picture != nil {
+		server, err := s.serve()
+		if err != nil {
+			return nil, err
+		}
+		file, err := server.PublishContent("cover", t.Picture.MIMEType, t.Picture.Data)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, ctrl.Image{URL: file.URL})
+	}
+
+	if t.HasVideo {
+		return ctrl.MovieMediaMetadata{
+			Title:       title,
+			Subtitle:    t.Artist,
+			Images:      images,
+			ReleaseDate: t.Date,
+		}, nil
+	}
+
+	return ctrl.MusicTrackMediaMetadata{
+		Title:       title,
+		AlbumName:   t.Album,
+		AlbumArtist: t.AlbumArtist,
+		Artist:      t.Artist,
+		Composer:    t.Composer,
+		TrackNumber: t.TrackNumber,
+		DiscNumber:  t.DiscNumber,
+		Images:      images,
+		ReleaseDate: t.Date,
+	}, nil
+}
+
+// subtitleTrack converts a subtitle file to WebVTT and publishes it.
+func (s *mediaSource) subtitleTrack(f subtitles.File, id int64) (ctrl.MediaTrack, error) {
+	data, err := ioutil.ReadFile(f.Path)
+	if err != nil {
+		return ctrl.MediaTrack{}, err
+	}
+
+	vtt, err := subtitles.Convert(data, f.Format, *subtitleDelayFlag)
+	if err != nil {
+		return ctrl.MediaTrack{}, err
+	}
+
+	server, err := s.serve()
+	if err != nil {
+		return ctrl.MediaTrack{}, err
+	}
+
+	stem := strings.TrimSuffix(filepath.Base(f.Path), filepath.Ext(f.Path))
+	file, err := server.PublishContent(stem+".vtt", subtitles.ContentType, vtt)
+	if err != nil {
+		return ctrl.MediaTrack{}, err
+	}
+
+	name := f.Name
+	if name == "" {
+		name = stem
+	}
+
+	track := ctrl.NewTextTrack(id, file.URL, f.Language, name)
+	track.Subtype = ctrl.TrackSubtypeCaptions
+	return track, nil
+}
+
+// activeTracks returns the tracks to enable when loading media, which is
+// its first text track, if any.
+func activeTracks(media ctrl.MediaInfo) []int64 {
+	for _, t := range media.MediaTracks {
+		if t.Type == ctrl.TrackTypeText {
+			return []int64{t.ID}
+		}
+	}
+	return nil
+}
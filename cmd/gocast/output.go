@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+	"github.com/ravishi/go-cast/pkg/cast/discovery"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var outputFlag = kingpin.Flag("output", "How to print results: text or json.").Short('o').Default("text").Enum("text", "json")
+
+// Exit codes, so that scripts can tell what went wrong.
+const (
+	exitFailure        = 1
+	exitDeviceNotFound = 2
+	exitLaunchError    = 3
+	exitLoadFailed     = 4
+	// exitTimeout is for a device that doesn't answer in time, not for one
+	// that isn't found.
+	exitTimeout = 5
+)
+
+// failure is an error that makes gocast exit with code.
+type failure struct {
+	code int
+	msg  string
+}
+
+func (f *failure) Error() string {
+	return f.msg
+}
+
+// failed describes err, with code unless what failed was a timeout.
+func failed(code int, message string, err error) error {
+	if isTimeout(err) {
+		code = exitTimeout
+	}
+	if err != nil {
+		message = fmt.Sprintf("%s: %s", message, err)
+	}
+	return &failure{code: code, msg: message}
+}
+
+// launchFailed describes err, an error launching an app, with
+// exitLaunchError only if the device refused to launch it.
+func launchFailed(err error) error {
+	code := exitFailure
+	if _, ok := err.(*ctrl.LaunchError); ok {
+		code = exitLaunchError
+	}
+	return failed(code, "Failed to launch", err)
+}
+
+func exitCode(err error) int {
+	if f, ok := err.(*failure); ok {
+		return f.code
+	} else if err == discovery.DeviceNotFound {
+		return exitDeviceNotFound
+	} else if isTimeout(err) {
+		return exitTimeout
+	}
+	return exitFailure
+}
+
+func isTimeout(err error) bool {
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		return true
+	}
+	return err == context.DeadlineExceeded
+}
+
+func jsonOutput() bool {
+	return *outputFlag == "json"
+}
+
+// deviceOutput is how devices are printed as JSON.
+type deviceOutput struct {
+	Name         string   `json:"name"`
+	ID           string   `json:"id,omitempty"`
+	Model        string   `json:"model,omitempty"`
+	Status       string   `json:"status,omitempty"`
+	Capabilities []string `json:"capabilities"`
+	Group        bool     `json:"group"`
+	Instance     string   `json:"instance,omitempty"`
+	Host         string   `json:"host,omitempty"`
+	Port         int      `json:"port"`
+	Address      string   `json:"address"`
+}
+
+func newDeviceOutput(d *discovery.Device) *deviceOutput {
+	capabilities := []string{}
+	if s := d.Capabilities.String(); s != "" {
+		capabilities = strings.Split(s, "|")
+	}
+	return &deviceOutput{
+		Name:         d.Name,
+		ID:           d.ID,
+		Model:        d.Model,
+		Status:       d.Status,
+		Capabilities: capabilities,
+		Group:        d.IsGroup(),
+		Instance:     d.Instance,
+		Host:         d.Host,
+		Port:         d.Port,
+		Address:      d.Addr(),
+	}
+}
+
+// report is what commands print as JSON: the device, and the receiver or
+// media status they got, if any.
+type report struct {
+	Device   *deviceOutput        `json:"device,omitempty"`
+	Receiver *ctrl.ReceiverStatus `json:"receiver,omitempty"`
+	Media    *ctrl.MediaStatus    `json:"media,omitempty"`
+}
+
+// printJSON writes v on a line of its own.
+func printJSON(v interface{}) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
+}
+
+// printMedia prints the status of a media session that a command got back,
+// in JSON output only.
+func printMedia(s *session, statuses []ctrl.MediaStatus) error {
+	if !jsonOutput() {
+		return nil
+	}
+	r := report{Device: newDeviceOutput(s.info)}
+	for i := range statuses {
+		if statuses[i].MediaSessionID != 0 {
+			r.Media = &statuses[i]
+			break
+		}
+	}
+	return printJSON(r)
+}
+
+// printReceiver prints a receiver status that a command got back, in JSON
+// output only.
+func printReceiver(s *session, status *ctrl.ReceiverStatus) error {
+	if !jsonOutput() {
+		return nil
+	}
+	return printJSON(report{Device: newDeviceOutput(s.info), Receiver: status})
+}
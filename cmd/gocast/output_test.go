@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+	"github.com/ravishi/go-cast/pkg/cast/discovery"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+var _ net.Error = timeoutError{}
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"other", errors.New("oops"), exitFailure},
+		{"not found", discovery.DeviceNotFound, exitDeviceNotFound},
+		{"deadline", context.DeadlineExceeded, exitTimeout},
+		{"net timeout", timeoutError{}, exitTimeout},
+		{"launch error", launchFailed(&ctrl.LaunchError{Reason: "NOT_FOUND"}), exitLaunchError},
+		{"launch failure", launchFailed(errors.New("oops")), exitFailure},
+		{"launch timeout", launchFailed(context.DeadlineExceeded), exitTimeout},
+		{"load failure", failed(exitLoadFailed, "Error while loading media", errors.New("oops")), exitLoadFailed},
+		{"load timeout", failed(exitLoadFailed, "Error while loading media", timeoutError{}), exitTimeout},
+	}
+	for _, test := range tests {
+		if code := exitCode(test.err); code != test.want {
+			t.Errorf("%s: got %d, want %d", test.name, code, test.want)
+		}
+	}
+}
+
+func TestFindDeviceNotFound(t *testing.T) {
+	static := discovery.NewStatic(discovery.Device{Name: "Living Room", Host: "10.0.0.2", Port: discovery.DefaultPort})
+	defer static.Close()
+	defer func(device string, timeout time.Duration) {
+		*deviceFlag, *timeoutFlag = device, timeout
+	}(*deviceFlag, *timeoutFlag)
+	*timeoutFlag = 10 * time.Millisecond
+
+	*deviceFlag = "living room"
+	if d, err := findDevice(context.Background(), static); err != nil || d.Name != "Living Room" {
+		t.Errorf("got %+v, %v", d, err)
+	}
+
+	*deviceFlag = "Kitchen"
+	if _, err := findDevice(context.Background(), static); exitCode(err) != exitDeviceNotFound {
+		t.Errorf("got %v, exiting with %d", err, exitCode(err))
+	}
+
+	*deviceFlag = ""
+	empty := discovery.NewStatic()
+	defer empty.Close()
+	if _, err := findDevice(context.Background(), empty); exitCode(err) != exitDeviceNotFound {
+		t.Errorf("got %v, exiting with %d", err, exitCode(err))
+	}
+}
+
+func TestJSONOutput(t *testing.T) {
+	d := &discovery.Device{
+		ID:           "abc",
+		Name:         "Speakers",
+		Model:        "Google Cast Group",
+		Capabilities: discovery.CapabilityAudioOut | discovery.CapabilityMultizoneGroup,
+		Host:         "abc.local",
+		Port:         32187,
+		IPv4:         []net.IP{net.ParseIP("10.0.0.2")},
+	}
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"device", newDeviceOutput(d),
+			`{"name":"Speakers","id":"abc","model":"Google Cast Group","capabilities":["AUDIO_OUT","MULTIZONE_GROUP"],"group":true,"host":"abc.local","port":32187,"address":"10.0.0.2:32187"}`},
+		{"bare device", newDeviceOutput(&discovery.Device{Name: "TV"}),
+			`{"name":"TV","capabilities":[],"group":false,"port":0,"address":""}`},
+		{"receiver", report{Device: newDeviceOutput(&discovery.Device{Name: "TV"}), Receiver: &ctrl.ReceiverStatus{}},
+			`{"device":{"name":"TV","capabilities":[],"group":false,"port":0,"address":""},"receiver":{"applications":null}}`},
+		{"media", report{Media: &ctrl.MediaStatus{MediaSessionID: 1, PlayerState: ctrl.PlayerStatePlaying}},
+			`{"media":{"mediaSessionId":1,"playbackRate":0,"playerState":"PLAYING","currentTime":0,"supportedMediaCommands":0}}`},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(test.v)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if string(data) != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, data, test.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+	"github.com/ravishi/go-cast/pkg/cast/playlist"
+)
+
+// queueBatchSize is how many items we send at once, keeping messages under
+// the size limit of the protocol.
+const queueBatchSize = 20
+
+var repeatModes = map[string]ctrl.RepeatMode{
+	"off":    ctrl.RepeatOff,
+	"all":    ctrl.RepeatAll,
+	"single": ctrl.RepeatSingle,
+}
+
+// readPlaylist returns the entries of source if it's a playlist or a
+// directory, and nil otherwise. Remote playlists that can't be fetched are
+// left for the device to try.
+func readPlaylist(source string) ([]playlist.Entry, error) {
+	if info, err := os.Stat(source); (err != nil || !info.IsDir()) && playlist.FormatOf(source) == 0 {
+		return nil, nil
+	}
+
+	header, err := requestHeader()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := (&playlist.Reader{Header: header}).Read(source)
+	if err == playlist.IsHLS {
+		return nil, nil
+	} else if err != nil && isURL(source) {
+		log.Printf("Playing %s as it is, since reading it as a playlist failed: %s", source, err)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	} else if len(entries) == 0 {
+		return nil, errors.New("Nothing to play in " + source)
+	}
+	return entries, nil
+}
+
+// queueItems builds the items of a queue, skipping the entries we can't
+// play.
+func (s *mediaSource) queueItems(ctx context.Context, entries []playlist.Entry) ([]ctrl.QueueItem, error) {
+	var items []ctrl.QueueItem
+	for _, e := range entries {
+		mediaInfo, err := s.mediaInfo(ctx, item{source: e.Location, fallbackTitle: e.Title})
+		if err != nil {
+			log.Printf("Skipping %s: %s", e.Location, err)
+			continue
+		}
+		queueItem := ctrl.NewQueueItem(mediaInfo)
+		queueItem.ActiveTrackIDs = activeTracks(mediaInfo)
+		items = append(items, queueItem)
+	}
+	if len(items) == 0 {
+		return nil, errors.New("None of the playlist entries can be played")
+	}
+	return items, nil
+}
+
+// loadQueue loads items as a queue, in batches.
+func loadQueue(ctx context.Context, media *ctrl.MediaController, items []ctrl.QueueItem) ([]ctrl.MediaStatus, error) {
+	repeatMode := repeatModes[*repeatFlag]
+	if *shuffleFlag {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		r.Shuffle(len(items), func(i, j int) {
+			items[i], items[j] = items[j], items[i]
+		})
+		if repeatMode == ctrl.RepeatAll {
+			repeatMode = ctrl.RepeatAllAndShuffle
+		}
+	}
+
+	first := items
+	if len(first) > queueBatchSize {
+		first = first[:queueBatchSize]
+	}
+	loaded, err := media.QueueLoad(ctx, first, ctrl.QueueLoadOptions{RepeatMode: repeatMode})
+	if err != nil {
+		return nil, err
+	} else if len(loaded) == 0 {
+		return nil, failed(exitLoadFailed, "The device didn't start a media session", nil)
+	}
+
+	sessionId := loaded[0].MediaSessionID
+	for rest := items[len(first):]; len(rest) > 0; {
+		batch := rest
+		if len(batch) > queueBatchSize {
+			batch = batch[:queueBatchSize]
+		}
+		if _, err := media.QueueInsert(ctx, sessionId, batch, 0); err != nil {
+			return nil, err
+		}
+		rest = rest[len(batch):]
+	}
+	return loaded, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast"
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+	"github.com/ravishi/go-cast/pkg/cast/discovery"
+)
+
+const (
+	defaultMediaReceiver = "CC1AD845"
+	senderId             = "sender-0"
+	receiverId           = "receiver-0"
+	sourceId             = "client-123"
+
+	// connectTimeout bounds both the connection and the TLS handshake.
+	connectTimeout = 10 * time.Second
+)
+
+var NothingPlaying = errors.New("Nothing is playing")
+
+// session is a connection to a device, to its receiver and, once attached,
+// to the media of the app that runs on it.
+type session struct {
+	info           *discovery.Device
+	addr           string
+	conn           net.Conn
+	device         *cast.Device
+	connection     *ctrl.ConnectionController
+	heartbeat      *ctrl.HeartbeatController
+	heartbeatError chan error
+	receiver       *ctrl.ReceiverController
+
+	media *ctrl.MediaSession
+}
+
+func connect(d *discovery.Device) (*session, error) {
+	addr := d.Addr()
+
+	dialer := &net.Dialer{Timeout: connectTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return nil, failed(exitFailure, "Failed to connect", err)
+	}
+
+	s := &session{
+		info:           d,
+		addr:           addr,
+		conn:           conn,
+		device:         cast.NewDevice(conn),
+		heartbeatError: make(chan error, 1),
+	}
+	go s.device.Run()
+
+	s.connection = ctrl.NewConnectionController(s.device, senderId, receiverId)
+	if err := s.connection.Connect(); err != nil {
+		s.Close()
+		return nil, failed(exitFailure, "Failed to connect", err)
+	}
+
+	s.heartbeat = ctrl.NewHeartbeatController(s.device, senderId, receiverId)
+	go func() {
+		// send a PING every 5s, bail after 10.
+		if err := s.heartbeat.Beat(time.Second*5, 2); err != nil {
+			s.heartbeatError <- err
+		}
+	}()
+
+	s.receiver = ctrl.NewReceiverController(s.device, senderId, receiverId)
+	return s, nil
+}
+
+// attach joins the media of the running app, which must be appId if given.
+// If there's none, it launches appId when asked to, and fails with
+// NothingPlaying otherwise.
+func (s *session) attach(ctx context.Context, appId string, launch bool) error {
+	media, err := s.receiver.JoinMedia(ctx, sourceId, appId)
+	if err == ctrl.AppNotRunning && launch {
+		if _, err := s.receiver.Launch(ctx, appId); err != nil {
+			return launchFailed(err)
+		}
+		media, err = s.receiver.JoinMedia(ctx, sourceId, appId)
+		if err == ctrl.AppNotRunning {
+			return failed(exitLaunchError, "Apparently we couldnt launch", nil)
+		}
+	} else if err == ctrl.AppNotRunning {
+		return NothingPlaying
+	}
+	if err != nil {
+		return failed(exitFailure, "Failed to connect to the media receiver", err)
+	}
+
+	s.detach()
+	s.media = media
+	return nil
+}
+
+func (s *session) detach() {
+	if s.media != nil {
+		s.media.Close()
+		s.media = nil
+	}
+}
+
+// mediaStatus returns the status of the media session that plays.
+func (s *session) mediaStatus(ctx context.Context) (*ctrl.MediaStatusEvent, error) {
+	status, err := s.media.Status(ctx)
+	if err == ctrl.NoMediaSession {
+		return nil, NothingPlaying
+	} else if err != nil {
+		return nil, err
+	}
+	return &ctrl.MediaStatusEvent{Status: *status, Received: time.Now()}, nil
+}
+
+func (s *session) Close() {
+	s.detach()
+	if s.receiver != nil {
+		s.receiver.Close()
+	}
+	if s.heartbeat != nil {
+		s.heartbeat.Close()
+	}
+	if s.connection != nil {
+		s.connection.Close()
+	}
+	s.device.Close()
+	s.conn.Close()
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+	"gopkg.in/alecthomas/kingpin.v2"
+)
+
+var watchCommand = kingpin.Command("watch", "Print a JSON line for every change in the receiver or media status, until interrupted.")
+
+// watchLine is a line of the watch output. Type is either receiver or
+// media, and Event, for receiver lines, tells what changed.
+type watchLine struct {
+	Type     string               `json:"type"`
+	Event    string               `json:"event,omitempty"`
+	Time     time.Time            `json:"time"`
+	Device   *deviceOutput        `json:"device"`
+	Receiver *ctrl.ReceiverStatus `json:"receiver,omitempty"`
+	Media    *ctrl.MediaStatus    `json:"media,omitempty"`
+}
+
+// watch follows the receiver, and the media of whatever app runs on it as
+// apps come and go.
+func watch(ctx context.Context, s *session) error {
+	device := newDeviceOutput(s.info)
+	receiverEvents := s.receiver.Watch(ctx)
+	if _, err := s.receiver.GetStatus(ctx); err != nil {
+		return failed(exitFailure, "Failed to get the receiver status", err)
+	}
+
+	// The media events stop when we detach from the media.
+	var mediaEvents <-chan ctrl.MediaStatusEvent
+
+	for {
+		select {
+		case e, ok := <-receiverEvents:
+			if !ok {
+				return failed(exitFailure, "Lost the connection to the receiver", nil)
+			}
+			err := printJSON(watchLine{
+				Type:     "receiver",
+				Event:    e.Type.String(),
+				Time:     e.Received,
+				Device:   device,
+				Receiver: e.Status,
+			})
+			if err != nil {
+				return err
+			}
+
+			// Follow the media of the app that runs now, if it changed.
+			app := e.Status.MediaApp("")
+			if app == nil && s.media != nil {
+				s.detach()
+				mediaEvents = nil
+			} else if app != nil && (s.media == nil || s.media.App.SessionID != app.SessionID) {
+				if err := s.attach(ctx, app.AppID, false); err == NothingPlaying {
+					continue
+				} else if err != nil {
+					return err
+				}
+				media := s.media.Media
+				mediaEvents = media.Watch(ctx)
+				go media.GetStatus(ctx)
+			}
+		case e, ok := <-mediaEvents:
+			if !ok {
+				mediaEvents = nil
+				continue
+			}
+			err := printJSON(watchLine{
+				Type:   "media",
+				Time:   e.Received,
+				Device: device,
+				Media:  &e.Status,
+			})
+			if err != nil {
+				return err
+			}
+		case err := <-s.heartbeatError:
+			return err
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
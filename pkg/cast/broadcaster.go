@@ -1,77 +1,193 @@
 package cast
 
-type broadcaster struct {
-	pub       chan *CastMessage
-	subs      map[chan<- *CastMessage]filterFunc
-	subReqs   chan *subRequest
-	unsubReqs chan chan<- *CastMessage
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy decides what happens to a message published to a channel
+// whose queue is full.
+type OverflowPolicy int
+
+const (
+	// DropOldest discards the oldest queued message to make room.
+	DropOldest OverflowPolicy = iota
+	// DropNewest discards the message being published.
+	DropNewest
+	// Disconnect unsubscribes the channel and closes its Read channel.
+	Disconnect
+)
+
+const DefaultQueueSize = 16
+
+type ChannelOptions struct {
+	// QueueSize is how many messages can wait for the reader of a channel.
+	// Zero means DefaultQueueSize.
+	QueueSize int
+	Overflow  OverflowPolicy
 }
 
-type subRequest struct {
-	ch     chan<- *CastMessage
-	filter filterFunc
+type routeKey struct {
+	namespace     string
+	sourceId      string
+	destinationId string
 }
 
-type filterFunc func(*CastMessage) bool
+type subscription struct {
+	key     routeKey
+	ch      chan *CastMessage
+	policy  OverflowPolicy
+	dropped uint64
+	closed  bool
+}
 
-func newMessageBroadcaster() *broadcaster {
-	b := &broadcaster{
-		pub:       make(chan *CastMessage),
-		subs:      make(map[chan<- *CastMessage]filterFunc),
-		subReqs:   make(chan *subRequest),
-		unsubReqs: make(chan chan<- *CastMessage),
+type subscriptions map[routeKey]map[*subscription]struct{}
+
+func (s subscriptions) add(key routeKey, sub *subscription) {
+	subs := s[key]
+	if subs == nil {
+		subs = make(map[*subscription]struct{})
+		s[key] = subs
 	}
-	go b.broadcastForever()
-	return b
+	subs[sub] = struct{}{}
 }
 
-func (b *broadcaster) broadcast(m *CastMessage) {
-	for ch := range b.subs {
-		if filter := b.subs[ch]; filter == nil || filter(m) {
-			ch <- m
-		}
+func (s subscriptions) remove(key routeKey, sub *subscription) {
+	subs := s[key]
+	delete(subs, sub)
+	if len(subs) == 0 {
+		delete(s, key)
 	}
 }
 
-func (b *broadcaster) broadcastForever() {
-	defer close(b.pub)
-	defer close(b.unsubReqs)
-	for {
-		select {
-		case m := <-b.pub:
-			b.broadcast(m)
-		case req, ok := <-b.subReqs:
-			if ok {
-				b.subs[req.ch] = req.filter
-			} else {
-				return
-			}
-		case ch := <-b.unsubReqs:
-			delete(b.subs, ch)
-		}
+func (sub *subscription) sourceKey() routeKey {
+	return routeKey{namespace: sub.key.namespace, sourceId: sub.key.sourceId}
+}
+
+// broadcaster dispatches messages to the subscriptions matching their
+// namespace, source and destination. Publishing never blocks: each
+// subscription has its own bounded queue and overflows according to its
+// policy.
+type broadcaster struct {
+	mu sync.Mutex
+	// subs indexes subscriptions by namespace, source and destination, and
+	// bySource by namespace and source only, for messages sent to "*".
+	subs     subscriptions
+	bySource subscriptions
+	closed   bool
+}
+
+func newMessageBroadcaster() *broadcaster {
+	return &broadcaster{
+		subs:     make(subscriptions),
+		bySource: make(subscriptions),
 	}
 }
 
 func (b *broadcaster) Publish(m *CastMessage) {
-	b.pub <- m
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := routeKey{
+		namespace:     m.GetNamespace(),
+		sourceId:      m.GetSourceId(),
+		destinationId: m.GetDestinationId(),
+	}
+
+	subs := b.subs[key]
+	if key.destinationId == "*" {
+		key.destinationId = ""
+		subs = b.bySource[key]
+	}
+
+	for sub := range subs {
+		b.deliver(sub, m)
+	}
 }
 
-func noFilter(*CastMessage) bool {
-	return true
+func (b *broadcaster) deliver(sub *subscription, m *CastMessage) {
+	select {
+	case sub.ch <- m:
+		return
+	default:
+	}
+
+	atomic.AddUint64(&sub.dropped, 1)
+
+	switch sub.policy {
+	case DropOldest:
+		select {
+		case <-sub.ch:
+		default:
+		}
+		select {
+		case sub.ch <- m:
+		default:
+		}
+	case DropNewest:
+	case Disconnect:
+		log.Printf("Disconnecting slow subscriber of %s", sub.key.namespace)
+		b.unsubscribe(sub)
+	}
 }
 
-func (b *broadcaster) Subscribe(ch chan<- *CastMessage) {
-	b.FilteredSubscribe(ch, noFilter)
+func (b *broadcaster) Subscribe(namespace, sourceId, destinationId string, options ChannelOptions) *subscription {
+	size := options.QueueSize
+	if size <= 0 {
+		size = DefaultQueueSize
+	}
+
+	sub := &subscription{
+		key: routeKey{
+			namespace:     namespace,
+			sourceId:      sourceId,
+			destinationId: destinationId,
+		},
+		ch:     make(chan *CastMessage, size),
+		policy: options.Overflow,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		sub.closed = true
+		close(sub.ch)
+		return sub
+	}
+
+	b.subs.add(sub.key, sub)
+	b.bySource.add(sub.sourceKey(), sub)
+
+	return sub
 }
 
-func (b *broadcaster) FilteredSubscribe(ch chan<- *CastMessage, filter filterFunc) {
-	b.subReqs <- &subRequest{ch: ch, filter: filter}
+func (b *broadcaster) Unsub(sub *subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.unsubscribe(sub)
 }
 
-func (b *broadcaster) Unsub(ch chan<- *CastMessage) {
-	b.unsubReqs <- ch
+func (b *broadcaster) unsubscribe(sub *subscription) {
+	if sub.closed {
+		return
+	}
+	sub.closed = true
+	close(sub.ch)
+
+	b.subs.remove(sub.key, sub)
+	b.bySource.remove(sub.sourceKey(), sub)
 }
 
 func (b *broadcaster) Close() {
-	close(b.subReqs)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	for _, subs := range b.subs {
+		for sub := range subs {
+			b.unsubscribe(sub)
+		}
+	}
 }
@@ -0,0 +1,128 @@
+package cast
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+)
+
+const testNamespace = "urn:x-cast:com.google.cast.media"
+
+func routedMessage(sourceId, destinationId, payload string) *CastMessage {
+	m := response(testNamespace, sourceId, payload)
+	m.DestinationId = proto.String(destinationId)
+	return m
+}
+
+// queued takes every message waiting on sub, and tells whether its channel
+// is closed.
+func queued(sub *subscription) (payloads []string, closed bool) {
+	for {
+		select {
+		case m, ok := <-sub.ch:
+			if !ok {
+				return payloads, true
+			}
+			payloads = append(payloads, m.GetPayloadUtf8())
+		default:
+			return payloads, false
+		}
+	}
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	tests := []struct {
+		policy OverflowPolicy
+		want   []string
+		closed bool
+	}{
+		{DropOldest, []string{"3", "4"}, false},
+		{DropNewest, []string{"0", "1"}, false},
+		{Disconnect, []string{"0", "1"}, true},
+	}
+	for _, test := range tests {
+		b := newMessageBroadcaster()
+		sub := b.Subscribe(testNamespace, "receiver-0", "sender-0", ChannelOptions{QueueSize: 2, Overflow: test.policy})
+		for i := 0; i < 5; i++ {
+			b.Publish(routedMessage("receiver-0", "sender-0", fmt.Sprint(i)))
+		}
+
+		payloads, closed := queued(sub)
+		if !reflect.DeepEqual(payloads, test.want) || closed != test.closed {
+			t.Errorf("policy %d: got %v, closed %t, want %v, closed %t", test.policy, payloads, closed, test.want, test.closed)
+		}
+		// Disconnected subscribers hear of nothing more, and so drop
+		// nothing more.
+		wantDropped := uint64(3)
+		if test.policy == Disconnect {
+			wantDropped = 1
+		}
+		if n := atomic.LoadUint64(&sub.dropped); n != wantDropped {
+			t.Errorf("policy %d: dropped %d, want %d", test.policy, n, wantDropped)
+		}
+		b.Close()
+	}
+}
+
+func TestDestinationRouting(t *testing.T) {
+	b := newMessageBroadcaster()
+	defer b.Close()
+	options := ChannelOptions{QueueSize: 4}
+	mine := b.Subscribe(testNamespace, "receiver-0", "sender-0", options)
+	other := b.Subscribe(testNamespace, "receiver-0", "sender-1", options)
+	elsewhere := b.Subscribe(testNamespace, "web-1", "sender-0", options)
+
+	b.Publish(routedMessage("receiver-0", "sender-0", "mine"))
+	b.Publish(routedMessage("receiver-0", "sender-1", "other"))
+	b.Publish(routedMessage("receiver-0", "*", "broadcast"))
+	b.Publish(routedMessage("web-1", "sender-2", "nobody"))
+
+	for _, test := range []struct {
+		sub  *subscription
+		want []string
+	}{
+		{mine, []string{"mine", "broadcast"}},
+		{other, []string{"other", "broadcast"}},
+		{elsewhere, nil},
+	} {
+		if payloads, _ := queued(test.sub); !reflect.DeepEqual(payloads, test.want) {
+			t.Errorf("%s: got %v, want %v", test.sub.key.destinationId, payloads, test.want)
+		}
+	}
+}
+
+func TestUnsubDuringPublish(t *testing.T) {
+	b := newMessageBroadcaster()
+	defer b.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		sub := b.Subscribe(testNamespace, "receiver-0", "sender-0", ChannelOptions{QueueSize: 1})
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b.Publish(routedMessage("receiver-0", "*", "m"))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			b.Unsub(sub)
+			b.Unsub(sub)
+		}()
+	}
+	wg.Wait()
+
+	if len(b.subs) != 0 || len(b.bySource) != 0 {
+		t.Errorf("left %d and %d routes", len(b.subs), len(b.bySource))
+	}
+
+	b.Close()
+	if _, closed := queued(b.Subscribe(testNamespace, "receiver-0", "sender-0", ChannelOptions{})); !closed {
+		t.Error("subscribed to a closed broadcaster")
+	}
+}
@@ -0,0 +1,220 @@
+package castest
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+type app struct {
+	appId       string
+	displayName string
+	namespaces  []string
+	sessionId   string
+	media       *mediaSession
+	mediaIds    int
+	itemIds     int
+}
+
+type namespace struct {
+	Name string `json:"name"`
+}
+
+type applicationStatus struct {
+	AppId        string      `json:"appId"`
+	DisplayName  string      `json:"displayName"`
+	IsIdleScreen bool        `json:"isIdleScreen"`
+	Namespaces   []namespace `json:"namespaces"`
+	SessionId    string      `json:"sessionId"`
+	StatusText   string      `json:"statusText"`
+	TransportId  string      `json:"transportId"`
+}
+
+type receiverStatus struct {
+	Applications []applicationStatus `json:"applications,omitempty"`
+	Volume       volume              `json:"volume"`
+}
+
+type receiverStatusResponse struct {
+	header
+	Status receiverStatus `json:"status"`
+}
+
+type errorResponse struct {
+	header
+	Reason string `json:"reason,omitempty"`
+}
+
+func (a *app) status() applicationStatus {
+	s := applicationStatus{
+		AppId:       a.appId,
+		DisplayName: a.displayName,
+		Namespaces:  make([]namespace, 0, len(a.namespaces)),
+		SessionId:   a.sessionId,
+		StatusText:  a.displayName,
+		TransportId: a.sessionId,
+	}
+	for _, ns := range a.namespaces {
+		s.Namespaces = append(s.Namespaces, namespace{Name: ns})
+	}
+	return s
+}
+
+func (r *Receiver) receiverStatus() receiverStatus {
+	status := receiverStatus{Volume: r.volume}
+	if len(r.apps) == 0 {
+		status.Applications = []applicationStatus{{
+			AppId:        BackdropAppId,
+			DisplayName:  "Backdrop",
+			IsIdleScreen: true,
+			Namespaces:   []namespace{},
+			SessionId:    "backdrop",
+			StatusText:   "",
+			TransportId:  "backdrop",
+		}}
+	}
+	for _, a := range r.apps {
+		status.Applications = append(status.Applications, a.status())
+	}
+	return status
+}
+
+func (r *Receiver) broadcastReceiverStatus(skip *request) outbox {
+	return r.broadcast(receiverNamespace, ReceiverId, skip, &receiverStatusResponse{
+		header: header{Type: "RECEIVER_STATUS"},
+		Status: r.receiverStatus(),
+	})
+}
+
+func (r *Receiver) replyReceiverStatus(req *request) outbox {
+	reply := req.reply(receiverNamespace, &receiverStatusResponse{
+		header: header{Type: "RECEIVER_STATUS", RequestId: req.RequestId},
+		Status: r.receiverStatus(),
+	})
+	return outbox{reply}
+}
+
+func (r *Receiver) findApp(sessionId string) *app {
+	for _, a := range r.apps {
+		if a.sessionId == sessionId {
+			return a
+		}
+	}
+	return nil
+}
+
+func (r *Receiver) findTransport(transportId string) *app {
+	return r.findApp(transportId)
+}
+
+func (r *Receiver) launch(appId string) (*app, outbox, error) {
+	if len(r.faults.launchErrors) > 0 {
+		reason := r.faults.launchErrors[0]
+		r.faults.launchErrors = r.faults.launchErrors[1:]
+		return nil, nil, errors.New(reason)
+	}
+
+	info, ok := r.registry[appId]
+	if !ok {
+		return nil, nil, errors.New("NOT_FOUND")
+	}
+
+	for _, a := range r.apps {
+		if a.appId == appId {
+			return a, nil, nil
+		}
+	}
+
+	// Only one app runs at a time, launching another one stops it.
+	out := r.stop("")
+
+	a := &app{
+		appId:       appId,
+		displayName: info.displayName,
+		namespaces:  info.namespaces,
+		sessionId:   r.nextSessionId(),
+	}
+	r.apps = append(r.apps, a)
+	return a, out, nil
+}
+
+// stop stops the app running the given session, or every app if sessionId is
+// empty.
+func (r *Receiver) stop(sessionId string) outbox {
+	var out outbox
+	apps := r.apps[:0]
+	for _, a := range r.apps {
+		if sessionId != "" && a.sessionId != sessionId {
+			apps = append(apps, a)
+			continue
+		}
+		out = append(out, r.closeVirtualConnections(a.sessionId)...)
+	}
+	r.apps = apps
+	return out
+}
+
+func (r *Receiver) handleReceiver(req *request) outbox {
+	switch req.Type {
+	case "GET_STATUS":
+		return r.replyReceiverStatus(req)
+	case "LAUNCH":
+		_, out, err := r.launch(req.AppId)
+		if err != nil {
+			return outbox{req.reply(receiverNamespace, &errorResponse{
+				header: header{Type: "LAUNCH_ERROR", RequestId: req.RequestId},
+				Reason: err.Error(),
+			})}
+		}
+		out = append(out, r.replyReceiverStatus(req)...)
+		return append(out, r.broadcastReceiverStatus(req)...)
+	case "STOP":
+		if req.SessionId != "" && r.findApp(req.SessionId) == nil {
+			return r.invalidRequest(receiverNamespace, req, "INVALID_SESSION_ID")
+		}
+		out := r.stop(req.SessionId)
+		out = append(out, r.replyReceiverStatus(req)...)
+		return append(out, r.broadcastReceiverStatus(req)...)
+	case "SET_VOLUME":
+		if !setVolume(&r.volume, req.Volume) {
+			return r.invalidRequest(receiverNamespace, req, "INVALID_PARAMS")
+		}
+		out := r.replyReceiverStatus(req)
+		return append(out, r.broadcastReceiverStatus(req)...)
+	default:
+		return r.invalidRequest(receiverNamespace, req, "INVALID_COMMAND")
+	}
+}
+
+func (r *Receiver) invalidRequest(namespace string, req *request, reason string) outbox {
+	return outbox{req.reply(namespace, &errorResponse{
+		header: header{Type: "INVALID_REQUEST", RequestId: req.RequestId},
+		Reason: reason,
+	})}
+}
+
+// setVolume applies the fields present in a SET_VOLUME request to v.
+func setVolume(v *volume, data json.RawMessage) bool {
+	change := &struct {
+		Level *float64 `json:"level"`
+		Muted *bool    `json:"muted"`
+	}{}
+	if len(data) == 0 || json.Unmarshal(data, change) != nil {
+		return false
+	}
+	if change.Level != nil {
+		v.Level = clamp(*change.Level, 0, 1)
+	}
+	if change.Muted != nil {
+		v.Muted = *change.Muted
+	}
+	return true
+}
+
+func clamp(v, min, max float64) float64 {
+	if v < min {
+		return min
+	} else if v > max {
+		return max
+	}
+	return v
+}
@@ -0,0 +1,158 @@
+package castest
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/ravishi/go-cast/pkg/cast"
+)
+
+const outgoingQueueSize = 64
+
+type route struct {
+	senderId   string
+	receiverId string
+}
+
+type conn struct {
+	r         *Receiver
+	rw        net.Conn
+	out       chan *cast.CastMessage
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// virtual is guarded by r.mu.
+	virtual map[route]bool
+}
+
+func newConn(r *Receiver, rw net.Conn) *conn {
+	return &conn{
+		r:       r,
+		rw:      rw,
+		out:     make(chan *cast.CastMessage, outgoingQueueSize),
+		done:    make(chan struct{}),
+		virtual: make(map[route]bool),
+	}
+}
+
+func (c *conn) readForever() {
+	defer c.close()
+	for {
+		m, err := cast.Read(c.rw)
+		if err == io.ErrNoProgress {
+			continue
+		} else if _, ok := err.(*cast.InvalidMessageError); ok {
+			continue
+		} else if err != nil {
+			return
+		}
+		c.r.handle(c, m)
+	}
+}
+
+func (c *conn) writeForever() {
+	for {
+		select {
+		case m := <-c.out:
+			if err := cast.Write(c.rw, m); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *conn) enqueue(m *cast.CastMessage) {
+	select {
+	case c.out <- m:
+	case <-c.done:
+	}
+}
+
+func (c *conn) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.rw.Close()
+		c.r.forget(c)
+	})
+}
+
+func (c *conn) message(namespace, sourceId, destinationId string, payload interface{}) envelope {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		panic(err)
+	}
+	text := string(data)
+	return envelope{
+		conn: c,
+		message: &cast.CastMessage{
+			ProtocolVersion: cast.CastMessage_CASTV2_1_0.Enum(),
+			SourceId:        &sourceId,
+			DestinationId:   &destinationId,
+			Namespace:       &namespace,
+			PayloadType:     cast.CastMessage_STRING.Enum(),
+			PayloadUtf8:     &text,
+		},
+	}
+}
+
+// envelope is a message waiting to be delivered. Messages are built while
+// holding the receiver lock and sent after releasing it.
+type envelope struct {
+	conn    *conn
+	message *cast.CastMessage
+}
+
+type outbox []envelope
+
+func (o outbox) send() {
+	for _, e := range o {
+		e.conn.enqueue(e.message)
+	}
+}
+
+type header struct {
+	Type      string `json:"type"`
+	RequestId int32  `json:"requestId"`
+}
+
+type request struct {
+	header
+	AppId          string          `json:"appId"`
+	SessionId      string          `json:"sessionId"`
+	MediaSessionId int             `json:"mediaSessionId"`
+	Volume         json.RawMessage `json:"volume"`
+	Media          json.RawMessage `json:"media"`
+	Autoplay       *bool           `json:"autoplay"`
+	CurrentTime    *float64        `json:"currentTime"`
+	ResumeState    string          `json:"resumeState"`
+	PlaybackRate   *float64        `json:"playbackRate"`
+	Items          []queueItem     `json:"items"`
+	StartIndex     int             `json:"startIndex"`
+	RepeatMode     string          `json:"repeatMode"`
+	ItemIds        []int           `json:"itemIds"`
+	InsertBefore   int             `json:"insertBefore"`
+	CurrentItemId  int             `json:"currentItemId"`
+	Jump           int             `json:"jump"`
+	Shuffle        bool            `json:"shuffle"`
+	ActiveTrackIds *[]int64        `json:"activeTrackIds"`
+	Language       string          `json:"language"`
+
+	conn    *conn
+	message *cast.CastMessage
+}
+
+func (req *request) route() route {
+	return route{
+		senderId:   req.message.GetSourceId(),
+		receiverId: req.message.GetDestinationId(),
+	}
+}
+
+func (req *request) reply(namespace string, payload interface{}) envelope {
+	return req.conn.message(namespace, req.message.GetDestinationId(), req.message.GetSourceId(), payload)
+}
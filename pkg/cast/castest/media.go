@@ -0,0 +1,249 @@
+package castest
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	// PAUSE | SEEK | STREAM_VOLUME | STREAM_MUTE | EDIT_TRACKS | PLAYBACK_RATE
+	supportedMediaCommands = 1 | 2 | 4 | 8 | 4096 | 8192
+)
+
+type mediaSession struct {
+	id          int
+	items       []*queueItem
+	current     int
+	repeatMode  string
+	playerState string
+	idleReason  string
+	rate        float64
+	volume      volume
+	position    float64
+	updated     time.Time
+	tracks      []int64
+}
+
+type mediaStatus struct {
+	MediaSessionId         int             `json:"mediaSessionId"`
+	PlaybackRate           float64         `json:"playbackRate"`
+	PlayerState            string          `json:"playerState"`
+	IdleReason             string          `json:"idleReason,omitempty"`
+	CurrentTime            float64         `json:"currentTime"`
+	SupportedMediaCommands int             `json:"supportedMediaCommands"`
+	Volume                 volume          `json:"volume"`
+	Media                  json.RawMessage `json:"media,omitempty"`
+	RepeatMode             string          `json:"repeatMode"`
+	CurrentItemId          int             `json:"currentItemId,omitempty"`
+	Items                  []*queueItem    `json:"items,omitempty"`
+	ActiveTrackIds         []int64         `json:"activeTrackIds,omitempty"`
+}
+
+type mediaStatusResponse struct {
+	header
+	Status []mediaStatus `json:"status"`
+}
+
+func (m *mediaSession) currentTime() float64 {
+	if m.playerState != "PLAYING" {
+		return m.position
+	}
+	return m.position + time.Since(m.updated).Seconds()*m.rate
+}
+
+func (m *mediaSession) setState(playerState string) {
+	m.seek(m.currentTime())
+	m.playerState = playerState
+}
+
+func (m *mediaSession) seek(position float64) {
+	m.position = position
+	m.updated = time.Now()
+}
+
+func (m *mediaSession) status() mediaStatus {
+	return mediaStatus{
+		MediaSessionId:         m.id,
+		PlaybackRate:           m.rate,
+		PlayerState:            m.playerState,
+		IdleReason:             m.idleReason,
+		CurrentTime:            m.currentTime(),
+		SupportedMediaCommands: supportedMediaCommands,
+		Volume:                 m.volume,
+		Media:                  m.currentItem().media(),
+		RepeatMode:             m.repeatMode,
+		CurrentItemId:          m.currentItem().ItemId,
+		Items:                  m.items,
+		ActiveTrackIds:         m.tracks,
+	}
+}
+
+func (a *app) mediaStatus() []mediaStatus {
+	if a.media == nil {
+		return []mediaStatus{}
+	}
+	return []mediaStatus{a.media.status()}
+}
+
+func (r *Receiver) broadcastMediaStatus(a *app, skip *request) outbox {
+	return r.broadcast(mediaNamespace, a.sessionId, skip, &mediaStatusResponse{
+		header: header{Type: "MEDIA_STATUS"},
+		Status: a.mediaStatus(),
+	})
+}
+
+func (r *Receiver) replyMediaStatus(a *app, req *request) outbox {
+	reply := req.reply(mediaNamespace, &mediaStatusResponse{
+		header: header{Type: "MEDIA_STATUS", RequestId: req.RequestId},
+		Status: a.mediaStatus(),
+	})
+	return outbox{reply}
+}
+
+// EndMedia puts the media session of the given app in the IDLE state with
+// the given idle reason (e.g. FINISHED or ERROR) and notifies every connected
+// sender.
+func (r *Receiver) EndMedia(sessionId, idleReason string) {
+	r.mu.Lock()
+	var out outbox
+	if a := r.findApp(sessionId); a != nil && a.media != nil {
+		out = r.endMedia(a, nil, idleReason)
+	}
+	r.mu.Unlock()
+	out.send()
+}
+
+// endMedia ends the media session of a, answering req if it isn't nil.
+func (r *Receiver) endMedia(a *app, req *request, idleReason string) outbox {
+	a.media.setState("IDLE")
+	a.media.idleReason = idleReason
+
+	var out outbox
+	if req != nil {
+		out = r.replyMediaStatus(a, req)
+	}
+	out = append(out, r.broadcastMediaStatus(a, req)...)
+	a.media = nil
+	return out
+}
+
+func (r *Receiver) handleMedia(a *app, req *request) outbox {
+	switch req.Type {
+	case "GET_STATUS":
+		return r.replyMediaStatus(a, req)
+	case "LOAD":
+		return r.load(a, req)
+	case "QUEUE_LOAD":
+		return r.queueLoad(a, req)
+	}
+
+	if a.media == nil || a.media.id != req.MediaSessionId {
+		return r.invalidRequest(mediaNamespace, req, "INVALID_MEDIA_SESSION_ID")
+	}
+
+	if a.media.playerState == "IDLE" {
+		return outbox{req.reply(mediaNamespace, &header{Type: "INVALID_PLAYER_STATE", RequestId: req.RequestId})}
+	}
+
+	switch req.Type {
+	case "PLAY":
+		a.media.setState("PLAYING")
+	case "PAUSE":
+		a.media.setState("PAUSED")
+	case "SEEK":
+		if req.CurrentTime != nil {
+			a.media.seek(*req.CurrentTime)
+		}
+		switch req.ResumeState {
+		case "PLAYBACK_START":
+			a.media.setState("PLAYING")
+		case "PLAYBACK_PAUSE":
+			a.media.setState("PAUSED")
+		}
+	case "SET_VOLUME":
+		if !setVolume(&a.media.volume, req.Volume) {
+			return r.invalidRequest(mediaNamespace, req, "INVALID_PARAMS")
+		}
+	case "SET_PLAYBACK_RATE":
+		if req.PlaybackRate == nil || *req.PlaybackRate <= 0 {
+			return r.invalidRequest(mediaNamespace, req, "INVALID_PARAMS")
+		}
+		a.media.setState(a.media.playerState)
+		a.media.rate = *req.PlaybackRate
+	case "EDIT_TRACKS_INFO":
+		if !a.media.editTracks(req) {
+			return r.invalidRequest(mediaNamespace, req, "INVALID_PARAMS")
+		}
+	case "QUEUE_INSERT", "QUEUE_REMOVE", "QUEUE_REORDER", "QUEUE_UPDATE":
+		ok, finished := a.media.updateQueue(a, req)
+		if !ok {
+			return r.invalidRequest(mediaNamespace, req, "INVALID_PARAMS")
+		} else if finished {
+			return r.endMedia(a, req, "FINISHED")
+		}
+	case "STOP":
+		return r.endMedia(a, req, "CANCELLED")
+	default:
+		return r.invalidRequest(mediaNamespace, req, "INVALID_COMMAND")
+	}
+
+	out := r.replyMediaStatus(a, req)
+	return append(out, r.broadcastMediaStatus(a, req)...)
+}
+
+func (r *Receiver) load(a *app, req *request) outbox {
+	if len(req.Media) == 0 {
+		return r.invalidRequest(mediaNamespace, req, "INVALID_PARAMS")
+	}
+
+	item := queueItem{fields: map[string]json.RawMessage{"media": req.Media}}
+	return r.startSession(a, req, []queueItem{item}, 0)
+}
+
+func (r *Receiver) queueLoad(a *app, req *request) outbox {
+	if len(req.Items) == 0 || req.StartIndex < 0 || req.StartIndex >= len(req.Items) {
+		return r.invalidRequest(mediaNamespace, req, "INVALID_PARAMS")
+	}
+	return r.startSession(a, req, req.Items, req.StartIndex)
+}
+
+func (r *Receiver) startSession(a *app, req *request, items []queueItem, start int) outbox {
+	if r.faults.loadFailures > 0 {
+		r.faults.loadFailures--
+		return outbox{req.reply(mediaNamespace, &header{Type: "LOAD_FAILED", RequestId: req.RequestId})}
+	}
+
+	// A request that fails uses up no ids.
+	if req.ActiveTrackIds != nil && !items[start].hasTracks(*req.ActiveTrackIds) {
+		return r.invalidRequest(mediaNamespace, req, "INVALID_PARAMS")
+	}
+
+	a.mediaIds++
+	m := &mediaSession{
+		id:          a.mediaIds,
+		repeatMode:  "REPEAT_OFF",
+		playerState: "PLAYING",
+		rate:        1,
+		volume:      volume{Level: 1},
+	}
+	if req.RepeatMode != "" {
+		m.repeatMode = req.RepeatMode
+	}
+	m.insert(a, items, 0)
+	m.current = start
+	if req.ActiveTrackIds != nil {
+		m.tracks = *req.ActiveTrackIds
+	}
+	if req.Autoplay != nil && !*req.Autoplay {
+		m.playerState = "PAUSED"
+	}
+	if req.CurrentTime != nil {
+		m.seek(*req.CurrentTime)
+	} else {
+		m.seek(m.currentItem().startTime())
+	}
+	a.media = m
+
+	out := r.replyMediaStatus(a, req)
+	return append(out, r.broadcastMediaStatus(a, req)...)
+}
@@ -0,0 +1,196 @@
+package castest
+
+import (
+	"encoding/json"
+	"math/rand"
+)
+
+// queueItem keeps every field a sender gave us, so they can be echoed back
+// in media statuses.
+type queueItem struct {
+	ItemId int
+	fields map[string]json.RawMessage
+}
+
+func (i *queueItem) UnmarshalJSON(data []byte) error {
+	i.fields = make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &i.fields); err != nil {
+		return err
+	}
+	if id, ok := i.fields["itemId"]; ok {
+		return json.Unmarshal(id, &i.ItemId)
+	}
+	return nil
+}
+
+func (i *queueItem) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(i.fields)+1)
+	for k, v := range i.fields {
+		fields[k] = v
+	}
+	fields["itemId"] = i.ItemId
+	return json.Marshal(fields)
+}
+
+func (i *queueItem) media() json.RawMessage {
+	return i.fields["media"]
+}
+
+func (i *queueItem) startTime() float64 {
+	var t float64
+	if raw, ok := i.fields["startTime"]; ok {
+		json.Unmarshal(raw, &t)
+	}
+	return t
+}
+
+// currentItem returns the item being played, or an empty one if the queue
+// ran out of items.
+func (m *mediaSession) currentItem() *queueItem {
+	if m.current < 0 || m.current >= len(m.items) {
+		return &queueItem{}
+	}
+	return m.items[m.current]
+}
+
+func (m *mediaSession) indexOf(itemId int) int {
+	for i, item := range m.items {
+		if item.ItemId == itemId {
+			return i
+		}
+	}
+	return -1
+}
+
+// insert adds items, with newly assigned ids, before the item with id
+// before, or at the end if before is zero.
+func (m *mediaSession) insert(a *app, items []queueItem, before int) {
+	at := len(m.items)
+	if before != 0 {
+		at = m.indexOf(before)
+	}
+
+	added := make([]*queueItem, len(items))
+	for i := range items {
+		a.itemIds++
+		item := items[i]
+		item.ItemId = a.itemIds
+		added[i] = &item
+	}
+
+	rest := append(added, m.items[at:]...)
+	m.items = append(m.items[:at], rest...)
+	if len(m.items) > len(added) && m.current >= at {
+		m.current += len(added)
+	}
+}
+
+// goTo makes the item at index the current one and starts it over, with
+// its tracks disabled.
+func (m *mediaSession) goTo(index int) {
+	m.current = index
+	m.tracks = nil
+	m.seek(m.currentItem().startTime())
+}
+
+// jump moves n items away from the current one, and reports false if that
+// goes past either end of the queue and the repeat mode doesn't wrap.
+func (m *mediaSession) jump(n int) bool {
+	index := m.current + n
+	if index < 0 || index >= len(m.items) {
+		if len(m.items) == 0 || m.repeatMode != "REPEAT_ALL" && m.repeatMode != "REPEAT_ALL_AND_SHUFFLE" {
+			return false
+		}
+		index = (index%len(m.items) + len(m.items)) % len(m.items)
+	}
+	m.goTo(index)
+	return true
+}
+
+// updateQueue applies a QUEUE_INSERT, QUEUE_REMOVE, QUEUE_REORDER or
+// QUEUE_UPDATE request. It reports whether the request was valid, and
+// whether it ended the queue.
+func (m *mediaSession) updateQueue(a *app, req *request) (ok bool, finished bool) {
+	current := m.currentItem()
+
+	switch req.Type {
+	case "QUEUE_INSERT":
+		if len(req.Items) == 0 || req.InsertBefore != 0 && m.indexOf(req.InsertBefore) < 0 {
+			return false, false
+		}
+		m.insert(a, req.Items, req.InsertBefore)
+	case "QUEUE_REMOVE":
+		if len(req.ItemIds) == 0 {
+			return false, false
+		}
+		for _, id := range req.ItemIds {
+			if i := m.indexOf(id); i >= 0 {
+				m.items = append(m.items[:i], m.items[i+1:]...)
+				if i < m.current {
+					m.current--
+				}
+			}
+		}
+		if i := m.indexOf(current.ItemId); i >= 0 {
+			m.current = i
+		} else if m.current < len(m.items) {
+			// The current item is gone, play the one that took its place.
+			m.goTo(m.current)
+		} else if len(m.items) == 0 || !m.jump(0) {
+			return true, true
+		}
+	case "QUEUE_REORDER":
+		moved := make([]*queueItem, 0, len(req.ItemIds))
+		for _, id := range req.ItemIds {
+			i := m.indexOf(id)
+			if i < 0 || id == req.InsertBefore {
+				return false, false
+			}
+			moved = append(moved, m.items[i])
+			m.items = append(m.items[:i], m.items[i+1:]...)
+		}
+		at := len(m.items)
+		if req.InsertBefore != 0 {
+			if at = m.indexOf(req.InsertBefore); at < 0 {
+				return false, false
+			}
+		}
+		rest := append(moved, m.items[at:]...)
+		m.items = append(m.items[:at], rest...)
+		m.current = m.indexOf(current.ItemId)
+	case "QUEUE_UPDATE":
+		for _, update := range req.Items {
+			i := m.indexOf(update.ItemId)
+			if i < 0 {
+				return false, false
+			}
+			for k, v := range update.fields {
+				m.items[i].fields[k] = v
+			}
+		}
+		if req.RepeatMode != "" {
+			m.repeatMode = req.RepeatMode
+		}
+		if req.Shuffle {
+			rand.Shuffle(len(m.items), func(i, j int) {
+				m.items[i], m.items[j] = m.items[j], m.items[i]
+			})
+			m.current = m.indexOf(current.ItemId)
+		}
+		if req.CurrentItemId != 0 {
+			i := m.indexOf(req.CurrentItemId)
+			if i < 0 {
+				return false, false
+			}
+			m.goTo(i)
+		}
+		if req.Jump != 0 && !m.jump(req.Jump) {
+			return true, true
+		}
+		if req.CurrentTime != nil {
+			m.seek(*req.CurrentTime)
+		}
+	}
+
+	return true, false
+}
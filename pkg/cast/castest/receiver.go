@@ -0,0 +1,296 @@
+// Package castest provides an in-process fake Chromecast receiver that
+// speaks the Cast V2 protocol, for use in tests.
+package castest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/ravishi/go-cast/pkg/cast"
+)
+
+const (
+	connectionNamespace = "urn:x-cast:com.google.cast.tp.connection"
+	heartbeatNamespace  = "urn:x-cast:com.google.cast.tp.heartbeat"
+	receiverNamespace   = "urn:x-cast:com.google.cast.receiver"
+	mediaNamespace      = "urn:x-cast:com.google.cast.media"
+
+	ReceiverId = "receiver-0"
+
+	DefaultMediaReceiverAppId = "CC1AD845"
+	BackdropAppId             = "E8C28D3C"
+)
+
+// Receiver is a fake Cast receiver. Its zero value is not usable, create one
+// with NewReceiver and connect to it with Pipe or ListenTLS.
+type Receiver struct {
+	mu        sync.Mutex
+	conns     map[*conn]struct{}
+	listeners []net.Listener
+	registry  map[string]appInfo
+	apps      []*app
+	volume    volume
+	sessionId int
+	faults    faults
+	closed    bool
+}
+
+type faults struct {
+	dropPongs    bool
+	launchErrors []string
+	loadFailures int
+}
+
+type appInfo struct {
+	displayName string
+	namespaces  []string
+}
+
+type volume struct {
+	Level float64 `json:"level"`
+	Muted bool    `json:"muted"`
+}
+
+// NewReceiver returns a receiver that is showing its idle screen and knows
+// how to launch the Default Media Receiver.
+func NewReceiver() *Receiver {
+	r := &Receiver{
+		conns:    make(map[*conn]struct{}),
+		registry: make(map[string]appInfo),
+		volume:   volume{Level: 1},
+	}
+	r.RegisterApp(DefaultMediaReceiverAppId, "Default Media Receiver", mediaNamespace)
+	return r
+}
+
+// RegisterApp makes appId launchable. Launching an unregistered app fails with
+// a LAUNCH_ERROR.
+func (r *Receiver) RegisterApp(appId, displayName string, namespaces ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registry[appId] = appInfo{displayName: displayName, namespaces: namespaces}
+}
+
+// Pipe starts serving a new connection over net.Pipe and returns the sender
+// end of it.
+func (r *Receiver) Pipe() net.Conn {
+	client, server := net.Pipe()
+	r.serve(server)
+	return client
+}
+
+// Close disconnects every sender and stops every listener.
+func (r *Receiver) Close() error {
+	r.mu.Lock()
+	r.closed = true
+	conns := make([]*conn, 0, len(r.conns))
+	for c := range r.conns {
+		conns = append(conns, c)
+	}
+	listeners := r.listeners
+	r.listeners = nil
+	r.mu.Unlock()
+
+	for _, l := range listeners {
+		l.Close()
+	}
+	for _, c := range conns {
+		c.close()
+	}
+	return nil
+}
+
+// DropPongs makes the receiver ignore (or stop ignoring) heartbeat PINGs.
+func (r *Receiver) DropPongs(drop bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.faults.dropPongs = drop
+}
+
+// FailLaunch makes the next LAUNCH request fail with a LAUNCH_ERROR carrying
+// the given reason. Calls accumulate, one failure per call.
+func (r *Receiver) FailLaunch(reason string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.faults.launchErrors = append(r.faults.launchErrors, reason)
+}
+
+// FailLoad makes the next LOAD request fail with LOAD_FAILED. Calls
+// accumulate, one failure per call.
+func (r *Receiver) FailLoad() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.faults.loadFailures++
+}
+
+// SendClose sends an unsolicited CLOSE from sourceId, which is either
+// ReceiverId or the transport id of a running app, to every sender that is
+// connected to it.
+func (r *Receiver) SendClose(sourceId string) {
+	r.mu.Lock()
+	out := r.closeVirtualConnections(sourceId)
+	r.mu.Unlock()
+	out.send()
+}
+
+// LaunchApp launches appId as if another sender had asked for it, notifies
+// every connected sender and returns the new session id.
+func (r *Receiver) LaunchApp(appId string) (string, error) {
+	r.mu.Lock()
+	a, out, err := r.launch(appId)
+	if err == nil {
+		out = append(out, r.broadcastReceiverStatus(nil)...)
+	}
+	r.mu.Unlock()
+	out.send()
+	if err != nil {
+		return "", err
+	}
+	return a.sessionId, nil
+}
+
+// StopApp stops a running session as if another sender had asked for it.
+func (r *Receiver) StopApp(sessionId string) {
+	r.mu.Lock()
+	out := r.stop(sessionId)
+	out = append(out, r.broadcastReceiverStatus(nil)...)
+	r.mu.Unlock()
+	out.send()
+}
+
+// SetVolume changes the device volume as if another sender (or the remote)
+// had done it, and notifies every connected sender.
+func (r *Receiver) SetVolume(level float64, muted bool) {
+	r.mu.Lock()
+	r.volume = volume{Level: level, Muted: muted}
+	out := r.broadcastReceiverStatus(nil)
+	r.mu.Unlock()
+	out.send()
+}
+
+// SetPlayerState changes the state of the media session of the given app as
+// if it was changed on the device itself, and notifies every connected
+// sender. It is a no-op if the app has no media loaded.
+func (r *Receiver) SetPlayerState(sessionId, playerState string) {
+	r.mu.Lock()
+	var out outbox
+	if a := r.findApp(sessionId); a != nil && a.media != nil {
+		a.media.setState(playerState)
+		out = r.broadcastMediaStatus(a, nil)
+	}
+	r.mu.Unlock()
+	out.send()
+}
+
+func (r *Receiver) serve(rw net.Conn) {
+	c := newConn(r, rw)
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		rw.Close()
+		return
+	}
+	r.conns[c] = struct{}{}
+	r.mu.Unlock()
+
+	go c.writeForever()
+	go c.readForever()
+}
+
+func (r *Receiver) forget(c *conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.conns, c)
+}
+
+func (r *Receiver) handle(c *conn, m *cast.CastMessage) {
+	if m.GetPayloadType() != cast.CastMessage_STRING {
+		return
+	}
+
+	req := &request{}
+	if err := json.Unmarshal([]byte(m.GetPayloadUtf8()), req); err != nil {
+		return
+	}
+	req.conn = c
+	req.message = m
+
+	r.mu.Lock()
+	var out outbox
+	switch m.GetNamespace() {
+	case connectionNamespace:
+		out = r.handleConnection(req)
+	case heartbeatNamespace:
+		out = r.handleHeartbeat(req)
+	case receiverNamespace:
+		if m.GetDestinationId() == ReceiverId {
+			out = r.handleReceiver(req)
+		}
+	case mediaNamespace:
+		if a := r.findTransport(m.GetDestinationId()); a != nil {
+			out = r.handleMedia(a, req)
+		}
+	}
+	r.mu.Unlock()
+
+	out.send()
+}
+
+func (r *Receiver) handleConnection(req *request) outbox {
+	switch req.Type {
+	case "CONNECT":
+		req.conn.virtual[req.route()] = true
+	case "CLOSE":
+		delete(req.conn.virtual, req.route())
+	}
+	return nil
+}
+
+func (r *Receiver) handleHeartbeat(req *request) outbox {
+	if req.Type != "PING" || r.faults.dropPongs {
+		return nil
+	}
+	return outbox{req.reply(heartbeatNamespace, &header{Type: "PONG"})}
+}
+
+// closeVirtualConnections sends a CLOSE from sourceId to every sender
+// connected to it, and forgets about those connections.
+func (r *Receiver) closeVirtualConnections(sourceId string) outbox {
+	var out outbox
+	for c := range r.conns {
+		for rt := range c.virtual {
+			if rt.receiverId != sourceId {
+				continue
+			}
+			delete(c.virtual, rt)
+			out = append(out, c.message(connectionNamespace, sourceId, rt.senderId, &header{Type: "CLOSE"}))
+		}
+	}
+	return out
+}
+
+// broadcast builds one message per sender connected to sourceId, except for
+// the one in skip, which usually gets a proper reply instead.
+func (r *Receiver) broadcast(namespace, sourceId string, skip *request, payload interface{}) outbox {
+	var out outbox
+	for c := range r.conns {
+		for rt := range c.virtual {
+			if rt.receiverId != sourceId {
+				continue
+			}
+			if skip != nil && skip.conn == c && skip.message.GetSourceId() == rt.senderId {
+				continue
+			}
+			out = append(out, c.message(namespace, sourceId, rt.senderId, payload))
+		}
+	}
+	return out
+}
+
+func (r *Receiver) nextSessionId() string {
+	r.sessionId++
+	return fmt.Sprintf("%08X-0000-4000-8000-%012X", r.sessionId, r.sessionId)
+}
@@ -0,0 +1,330 @@
+package castest_test
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast"
+	"github.com/ravishi/go-cast/pkg/cast/castest"
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+	"golang.org/x/net/context"
+)
+
+const senderId = "sender-0"
+
+func TestMain(m *testing.M) {
+	// Devices log every message.
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// connect runs a device over conn, connected to the receiver.
+func connect(t *testing.T, conn net.Conn) *cast.Device {
+	device := cast.NewDevice(conn)
+	go device.Run()
+	t.Cleanup(func() {
+		device.Close()
+		conn.Close()
+	})
+
+	if err := ctrl.NewConnectionController(device, senderId, castest.ReceiverId).Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return device
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// launch launches the Default Media Receiver and returns a controller for
+// its media.
+func launch(t *testing.T, ctx context.Context, device *cast.Device) *ctrl.MediaController {
+	receiver := ctrl.NewReceiverController(device, senderId, castest.ReceiverId)
+	defer receiver.Close()
+
+	status, err := receiver.Launch(ctx, castest.DefaultMediaReceiverAppId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := status.MediaApp(castest.DefaultMediaReceiverAppId)
+	if app == nil {
+		t.Fatalf("launched, but got %+v", status)
+	}
+	if err := ctrl.NewConnectionController(device, senderId, app.TransportId).Connect(); err != nil {
+		t.Fatal(err)
+	}
+	media := ctrl.NewMediaController(device, senderId, app.TransportId)
+	t.Cleanup(media.Close)
+	return media
+}
+
+var testMedia = ctrl.MediaInfo{
+	ContentID:      "http://example.com/movie.mp4",
+	ContentType:    "video/mp4",
+	StreamType:     ctrl.StreamTypeBuffered,
+	StreamDuration: ctrl.Seconds(600),
+}
+
+func TestSmoke(t *testing.T) {
+	dials := []struct {
+		name string
+		dial func(r *castest.Receiver) (net.Conn, error)
+	}{
+		{"pipe", func(r *castest.Receiver) (net.Conn, error) {
+			return r.Pipe(), nil
+		}},
+		{"tls", func(r *castest.Receiver) (net.Conn, error) {
+			l, err := r.ListenTLS()
+			if err != nil {
+				return nil, err
+			}
+			return tls.Dial("tcp", l.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+		}},
+	}
+
+	for _, d := range dials {
+		name := d.name
+		r := castest.NewReceiver()
+		defer r.Close()
+		conn, err := d.dial(r)
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		ctx := testContext(t)
+		device := connect(t, conn)
+
+		receiver := ctrl.NewReceiverController(device, senderId, castest.ReceiverId)
+		status, err := receiver.GetStatus(ctx)
+		receiver.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		} else if len(status.Applications) != 1 || status.Applications[0].AppID != castest.BackdropAppId {
+			t.Errorf("%s: the receiver isn't idle: %+v", name, status)
+		}
+
+		media := launch(t, ctx, device)
+		statuses, err := media.Load(ctx, testMedia, ctrl.LoadOptions{AutoPlay: true, PlayPosition: ctrl.Seconds(30)})
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		} else if len(statuses) != 1 || statuses[0].PlayerState != "PLAYING" {
+			t.Fatalf("%s: loaded, but got %+v", name, statuses)
+		}
+		sessionId := statuses[0].MediaSessionID
+		if statuses[0].CurrentTime < ctrl.Seconds(30) {
+			t.Errorf("%s: playing from %s", name, statuses[0].CurrentTime)
+		}
+
+		if statuses, err = media.Pause(ctx, sessionId); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		} else if statuses[0].PlayerState != "PAUSED" {
+			t.Errorf("%s: paused, but %s", name, statuses[0].PlayerState)
+		}
+
+		if statuses, err = media.Seek(ctx, sessionId, ctrl.Seconds(120), ctrl.ResumeStatePlay); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		} else if statuses[0].PlayerState != "PLAYING" || statuses[0].CurrentTime < ctrl.Seconds(120) {
+			t.Errorf("%s: seeked, but %s at %s", name, statuses[0].PlayerState, statuses[0].CurrentTime)
+		}
+
+		if statuses, err = media.Stop(ctx, sessionId); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		} else if statuses[0].PlayerState != "IDLE" || statuses[0].IdleReason != "CANCELLED" {
+			t.Errorf("%s: stopped, but %s (%s)", name, statuses[0].PlayerState, statuses[0].IdleReason)
+		}
+	}
+}
+
+func TestFailLaunch(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	r.FailLaunch("NOT_ALLOWED")
+	ctx := testContext(t)
+	receiver := ctrl.NewReceiverController(connect(t, r.Pipe()), senderId, castest.ReceiverId)
+	defer receiver.Close()
+
+	_, err := receiver.Launch(ctx, castest.DefaultMediaReceiverAppId)
+	if e, ok := err.(*ctrl.LaunchError); !ok || e.Reason != "NOT_ALLOWED" {
+		t.Fatalf("got %v, want a NOT_ALLOWED LaunchError", err)
+	}
+
+	// One failure per call.
+	if _, err := receiver.Launch(ctx, castest.DefaultMediaReceiverAppId); err != nil {
+		t.Fatal(err)
+	}
+
+	// Unknown apps fail anyway.
+	if _, err := receiver.Launch(ctx, "DEADBEEF"); err == nil {
+		t.Fatal("launched an unknown app")
+	} else if _, ok := err.(*ctrl.LaunchError); !ok {
+		t.Fatalf("got %v, want a LaunchError", err)
+	}
+}
+
+func TestFailLoad(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	r.FailLoad()
+	ctx := testContext(t)
+	media := launch(t, ctx, connect(t, r.Pipe()))
+
+	if _, err := media.Load(ctx, testMedia, ctrl.LoadOptions{}); err != ctrl.LoadFailed {
+		t.Fatalf("got %v, want %v", err, ctrl.LoadFailed)
+	}
+	if _, err := media.Load(ctx, testMedia, ctrl.LoadOptions{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDropPongs(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	device := connect(t, r.Pipe())
+
+	heartbeat := ctrl.NewHeartbeatController(device, senderId, castest.ReceiverId)
+	defer heartbeat.Close()
+
+	beat := make(chan error, 1)
+	go func() {
+		beat <- heartbeat.Beat(10*time.Millisecond, 2)
+	}()
+	select {
+	case err := <-beat:
+		t.Fatalf("stopped beating while getting PONGs: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	r.DropPongs(true)
+	select {
+	case err := <-beat:
+		if err != context.DeadlineExceeded {
+			t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("kept beating without PONGs")
+	}
+}
+
+// pending sends a request that the receiver never answers, and returns
+// where its error goes.
+func pending(device *cast.Device) <-chan error {
+	ch := device.NewChannel("urn:x-cast:com.example.unanswered", senderId, castest.ReceiverId)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := ch.Request(context.Background(), device.NextRequestId(), `{"type":"HELLO"}`)
+		errs <- err
+	}()
+	return errs
+}
+
+func TestDeviceClosed(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+
+	// Closing the device...
+	device := connect(t, r.Pipe())
+	errs := pending(device)
+	time.Sleep(10 * time.Millisecond)
+	device.Close()
+	select {
+	case err := <-errs:
+		if err != cast.DeviceClosed {
+			t.Errorf("got %v, want %v", err, cast.DeviceClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request outlived the device")
+	}
+
+	// ...or losing the connection to it.
+	device = connect(t, r.Pipe())
+	errs = pending(device)
+	time.Sleep(10 * time.Millisecond)
+	r.Close()
+	select {
+	case err := <-errs:
+		if err != cast.DeviceClosed {
+			t.Errorf("got %v, want %v", err, cast.DeviceClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the request outlived the connection")
+	}
+}
+
+func TestSendClose(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	ctx := testContext(t)
+	device := connect(t, r.Pipe())
+	receiver := ctrl.NewReceiverController(device, senderId, castest.ReceiverId)
+	defer receiver.Close()
+
+	status, err := receiver.Launch(ctx, castest.DefaultMediaReceiverAppId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := status.MediaApp(castest.DefaultMediaReceiverAppId)
+	conn := ctrl.NewConnectionController(device, senderId, app.TransportId)
+	defer conn.Close()
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	r.SendClose(app.TransportId)
+	select {
+	case <-conn.Closed():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the session's channel wasn't closed")
+	}
+
+	// The app, and the connection to the receiver itself, are still there.
+	status, err = receiver.GetStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if a := status.MediaApp(castest.DefaultMediaReceiverAppId); a == nil || a.SessionID != app.SessionID {
+		t.Errorf("got %+v", status)
+	}
+}
+
+func TestFailedLoadKeepsSessionIds(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	ctx := testContext(t)
+	device := connect(t, r.Pipe())
+	media := launch(t, ctx, device)
+
+	status, err := ctrl.NewReceiverController(device, senderId, castest.ReceiverId).GetStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ch := device.NewChannel(ctrl.MediaNamespace, senderId, status.MediaApp("").TransportId)
+	defer ch.Close()
+
+	// The controller checks tracks before loading, so ask for an unknown
+	// one by hand.
+	id := device.NextRequestId()
+	payload := fmt.Sprintf(`{"type":"LOAD","requestId":%d,"activeTrackIds":[2],`+
+		`"media":{"contentId":"http://example.com/a.mp4","streamType":"BUFFERED","tracks":[{"trackId":1,"type":"TEXT"}]}}`, id)
+	response, err := ch.Request(ctx, id, payload)
+	if err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(response.GetPayloadUtf8(), "INVALID_PARAMS") {
+		t.Fatalf("loaded an unknown track: %s", response.GetPayloadUtf8())
+	}
+
+	statuses, err := media.Load(ctx, testMedia, ctrl.LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	} else if id := statuses[0].MediaSessionID; id != 1 {
+		t.Errorf("the first session that loaded is %d", id)
+	}
+}
@@ -0,0 +1,75 @@
+package castest
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+type track struct {
+	TrackId  int64  `json:"trackId"`
+	Type     string `json:"type"`
+	Language string `json:"language"`
+}
+
+func (i *queueItem) tracks() []track {
+	media := &struct {
+		Tracks []track `json:"tracks"`
+	}{}
+	json.Unmarshal(i.media(), media)
+	return media.Tracks
+}
+
+func (i *queueItem) hasTracks(ids []int64) bool {
+	tracks := i.tracks()
+	for _, id := range ids {
+		found := false
+		for _, t := range tracks {
+			found = found || t.TrackId == id
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// editTracks applies an EDIT_TRACKS_INFO request, and reports whether it was
+// valid. A language replaces the active text tracks with the first text
+// track in that language.
+func (m *mediaSession) editTracks(req *request) bool {
+	item := m.currentItem()
+
+	if req.ActiveTrackIds != nil {
+		if !item.hasTracks(*req.ActiveTrackIds) {
+			return false
+		}
+		m.tracks = *req.ActiveTrackIds
+	}
+
+	if req.Language == "" {
+		return true
+	}
+
+	tracks := item.tracks()
+	var text *track
+	for i := range tracks {
+		if tracks[i].Type == "TEXT" && strings.EqualFold(tracks[i].Language, req.Language) {
+			text = &tracks[i]
+			break
+		}
+	}
+	if text == nil {
+		return false
+	}
+
+	active := []int64{text.TrackId}
+	for _, id := range m.tracks {
+		for _, t := range tracks {
+			if t.TrackId == id && t.Type != "TEXT" {
+				active = append(active, id)
+			}
+		}
+	}
+	m.tracks = active
+	return true
+}
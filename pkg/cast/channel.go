@@ -1,29 +1,31 @@
 package cast
 
+import (
+	"sync"
+	"sync/atomic"
+)
+
 type Channel struct {
 	device        *Device
-	ch            chan *CastMessage
+	sub           *subscription
+	done          chan struct{}
+	closeOnce     sync.Once
 	namespace     string
 	sourceId      string
 	destinationId string
 }
 
-func newChannel(device *Device, namespace, sourceId, destinationId string) *Channel {
-	c := &Channel{
-		ch:            make(chan *CastMessage),
+func newChannel(device *Device, namespace, sourceId, destinationId string, options ChannelOptions) *Channel {
+	return &Channel{
+		// We receive what the peer (our destination) sends to us (our
+		// source).
+		sub:           device.bc.Subscribe(namespace, destinationId, sourceId, options),
+		done:          make(chan struct{}),
 		device:        device,
 		namespace:     namespace,
 		sourceId:      sourceId,
 		destinationId: destinationId,
 	}
-	c.device.bc.FilteredSubscribe(c.ch, c.expects)
-	return c
-}
-
-func (c *Channel) expects(m *CastMessage) bool {
-	return *m.Namespace == c.namespace &&
-		*m.SourceId == c.destinationId &&
-		*m.DestinationId == "*" || *m.DestinationId == c.sourceId
 }
 
 func (c *Channel) Namespace() string {
@@ -38,8 +40,16 @@ func (c *Channel) DestinationId() string {
 	return c.destinationId
 }
 
+// Read returns the messages received on this channel that are not responses
+// to a pending Request, such as status broadcasts.
 func (c *Channel) Read() <-chan *CastMessage {
-	return c.ch
+	return c.sub.ch
+}
+
+// Dropped returns how many messages were dropped because the reader of this
+// channel couldn't keep up.
+func (c *Channel) Dropped() uint64 {
+	return atomic.LoadUint64(&c.sub.dropped)
 }
 
 func (c *Channel) Send(payload string) error {
@@ -55,6 +65,8 @@ func (c *Channel) Send(payload string) error {
 }
 
 func (c *Channel) Close() {
-	c.device.bc.Unsub(c.ch)
-	close(c.ch)
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.device.bc.Unsub(c.sub)
+	})
 }
@@ -28,7 +28,7 @@ type ConnectionController struct {
 }
 
 func NewConnectionController(device *cast.Device, sourceId, destinationId string) *ConnectionController {
-	ctx, close := context.WithCancel(context.Background())
+	ctx, close := context.WithCancel(device.Context())
 	c := &ConnectionController{
 		ch:     device.NewChannel(ConnectionNamespace, sourceId, destinationId),
 		ctx:    ctx,
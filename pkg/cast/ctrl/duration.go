@@ -0,0 +1,52 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// Duration is a time.Duration that is encoded as a number of seconds, the way
+// the media namespace expects times and positions.
+type Duration time.Duration
+
+// UnknownDuration is the duration of live streams and of media whose length
+// the receiver didn't tell us. It is encoded as null.
+const UnknownDuration Duration = -1
+
+// Seconds returns a Duration of s seconds.
+func Seconds(s float64) Duration {
+	return Duration(s * float64(time.Second))
+}
+
+func (d Duration) Seconds() float64 {
+	return time.Duration(d).Seconds()
+}
+
+func (d Duration) String() string {
+	if d == UnknownDuration {
+		return "unknown"
+	}
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	if d == UnknownDuration {
+		return []byte("null"), nil
+	}
+	return strconv.AppendFloat(nil, d.Seconds(), 'f', -1, 64), nil
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*d = UnknownDuration
+		return nil
+	}
+
+	var s float64
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*d = Seconds(s)
+	return nil
+}
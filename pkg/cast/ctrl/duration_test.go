@@ -0,0 +1,76 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDurationJSON(t *testing.T) {
+	tests := []struct {
+		d    Duration
+		json string
+	}{
+		{0, `0`},
+		{Seconds(1.5), `1.5`},
+		{Duration(90 * time.Minute), `5400`},
+		{Duration(time.Millisecond), `0.001`},
+		{UnknownDuration, `null`},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(test.d)
+		if err != nil {
+			t.Fatal(err)
+		} else if string(data) != test.json {
+			t.Errorf("%s encoded as %s, want %s", test.d, data, test.json)
+		}
+
+		var d Duration
+		if err := json.Unmarshal(data, &d); err != nil {
+			t.Fatal(err)
+		} else if d != test.d {
+			t.Errorf("%s decoded as %s", data, d)
+		}
+	}
+
+	var d Duration
+	if err := json.Unmarshal([]byte(`"1s"`), &d); err == nil {
+		t.Errorf("decoded a string as %s", d)
+	}
+}
+
+func TestMediaInfoDurationJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		d    Duration
+		json string
+		want Duration
+	}{
+		{"zero", 0, `null`, UnknownDuration},
+		{"unknown", UnknownDuration, `null`, UnknownDuration},
+		{"known", Seconds(42.5), `42.5`, Seconds(42.5)},
+	}
+	for _, test := range tests {
+		data, err := json.Marshal(MediaInfo{ContentID: "a.mp4", StreamDuration: test.d})
+		if err != nil {
+			t.Fatal(err)
+		} else if !strings.Contains(string(data), `"duration":`+test.json+`,`) {
+			t.Errorf("%s: encoded as %s", test.name, data)
+		}
+		var info MediaInfo
+		if err := json.Unmarshal(data, &info); err != nil {
+			t.Fatal(err)
+		} else if info.StreamDuration != test.want {
+			t.Errorf("%s: %s decoded as %s", test.name, data, info.StreamDuration)
+		}
+	}
+
+	var info MediaInfo
+	if err := json.Unmarshal([]byte(`{"contentId":"a.mp4"}`), &info); err != nil {
+		t.Fatal(err)
+	} else if info.StreamDuration != UnknownDuration {
+		t.Errorf("a missing duration decoded as %s", info.StreamDuration)
+	}
+
+}
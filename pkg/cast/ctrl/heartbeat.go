@@ -43,33 +43,39 @@ func (c *HeartbeatController) Close() {
 }
 
 func (c *HeartbeatController) Beat(interval time.Duration, timeoutFactor int) error {
-NEXT:
 	for {
-		ctx, _ := context.WithTimeout(c.ctx, interval*time.Duration(timeoutFactor))
-
-		for {
-			select {
-			case message, ok := <-c.ch.Read():
-				if !ok {
-					return nil
-				}
+		ctx, cancel := context.WithTimeout(c.ctx, interval*time.Duration(timeoutFactor))
+		pong, err := c.waitPong(ctx, interval)
+		cancel()
+		if err != nil || !pong {
+			return err
+		}
+	}
+}
 
-				payload := &PayloadHeaders{}
-				err := json.Unmarshal([]byte(*message.PayloadUtf8), payload)
-				if err != nil {
-					log.Println("Error while unmarshaling beat response:", err)
-				} else if payload.Type == pongCommand.Type {
-					continue NEXT
-				}
-			case <-ctx.Done():
-				return ctx.Err()
-			case <-time.After(interval):
+func (c *HeartbeatController) waitPong(ctx context.Context, interval time.Duration) (bool, error) {
+	for {
+		select {
+		case message, ok := <-c.ch.Read():
+			if !ok {
+				return false, nil
 			}
 
-			err := c.Ping()
+			payload := &PayloadHeaders{}
+			err := json.Unmarshal([]byte(*message.PayloadUtf8), payload)
 			if err != nil {
-				return err
+				log.Println("Error while unmarshaling beat response:", err)
+			} else if payload.Type == pongCommand.Type {
+				return true, nil
 			}
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(interval):
+		}
+
+		err := c.Ping()
+		if err != nil {
+			return false, err
 		}
 	}
 }
@@ -1,6 +1,7 @@
 package ctrl
 
 import (
+	"encoding/json"
 	"errors"
 	"time"
 
@@ -13,26 +14,42 @@ const (
 )
 
 type MediaController struct {
-	ch    *cast.Channel
-	ctx   context.Context
-	close context.CancelFunc
-	rm    *requestManager
-}
-
-type mediaRequest struct {
-	RequestHeader
-	MediaSessionID int `json:"mediaSessionId"`
+	ch       *cast.Channel
+	ctx      context.Context
+	close    context.CancelFunc
+	rm       *requestManager
+	watchers mediaWatchers
 }
 
 func NewMediaController(device *cast.Device, sourceId, destinationId string) *MediaController {
-	ctx, close := context.WithCancel(context.Background())
+	ctx, close := context.WithCancel(device.Context())
 	ch := device.NewChannel(MediaNamespace, sourceId, destinationId)
-	return &MediaController{
+	r := &MediaController{
 		ch:    ch,
 		ctx:   ctx,
 		close: close,
-		rm:    newRequestManager(ch),
+		rm:    newRequestManager(device, ch),
+		watchers: mediaWatchers{
+			subs: make(map[chan MediaStatusEvent]struct{}),
+			last: make(map[int]*MediaInfo),
+		},
 	}
+
+	go r.watchForever()
+
+	return r
+}
+
+// SetRequestTimeout changes how long requests wait for a response, unless
+// their context expires earlier. It defaults to DefaultRequestTimeout, and
+// zero disables it.
+func (r *MediaController) SetRequestTimeout(timeout time.Duration) {
+	r.rm.SetTimeout(timeout)
+}
+
+func (r *MediaController) Close() {
+	r.close()
+	r.ch.Close()
 }
 
 type mediaStatusResponse struct {
@@ -40,30 +57,16 @@ type mediaStatusResponse struct {
 	Status []MediaStatus `json:"status,omitempty"`
 }
 
-type MediaStatus struct {
-	MediaSessionID         int                    `json:"mediaSessionId"`
-	PlaybackRate           float64                `json:"playbackRate"`
-	PlayerState            string                 `json:"playerState"`
-	CurrentTime            float64                `json:"currentTime"`
-	SupportedMediaCommands int                    `json:"supportedMediaCommands"`
-	CustomData             map[string]interface{} `json:"customData"`
-	IdleReason             string                 `json:"idleReason"`
-}
-
-func (r *MediaController) GetStatus() ([]MediaStatus, error) {
-	return r.requestStatus(&RequestHeader{
+func (r *MediaController) GetStatus(ctx context.Context) ([]MediaStatus, error) {
+	return r.requestStatus(ctx, &RequestHeader{
 		PayloadHeaders: PayloadHeaders{"GET_STATUS"},
 	})
 }
 
 type StreamType string
 type MediaType int
-type TrackType int
-type TrackSubtype int
 
 const (
-	UnknownDuration time.Duration = -1
-
 	//StreamTypeInvalid  StreamType = -1
 	//StreamTypeNone     StreamType = 0
 	StreamTypeBuffered StreamType = "BUFFERED"
@@ -71,60 +74,78 @@ const (
 
 	MediaTypeGeneric    MediaType = 0
 	MediaTypeMovie      MediaType = 1
-	MediaTypeTVShow     MediaType = 1
+	MediaTypeTVShow     MediaType = 2
 	MediaTypeMusicTrack MediaType = 3
 	MediaTypePhoto      MediaType = 4
-	MediaTypeUser       MediaType = 4
-
-	TrackTypeUnknown TrackType = 0
-	TrackTypeText    TrackType = 1
-	TrackTypeAudio   TrackType = 2
-	TrackTypeVideo   TrackType = 3
-
-	TrackSubtypeUnknown      TrackSubtype = -1
-	TrackSubtypeNone         TrackSubtype = 0
-	TrackSubtypeCaptions     TrackSubtype = 2
-	TrackSubtypeChapters     TrackSubtype = 4
-	TrackSubtypeDescriptions TrackSubtype = 3
-	TrackSubtypeMetadata     TrackSubtype = 5
+	MediaTypeUser       MediaType = 100
+)
+
+// HLSSegmentFormat is the format of the audio segments of an HLS stream,
+// and HLSVideoSegmentFormat the one of its video segments. Receivers assume
+// MPEG-2 TS when they aren't given.
+type HLSSegmentFormat string
+type HLSVideoSegmentFormat string
+
+const (
+	HLSSegmentFormatAAC  HLSSegmentFormat = "aac"
+	HLSSegmentFormatAC3  HLSSegmentFormat = "ac3"
+	HLSSegmentFormatMP3  HLSSegmentFormat = "mp3"
+	HLSSegmentFormatTS   HLSSegmentFormat = "ts"
+	HLSSegmentFormatEAC3 HLSSegmentFormat = "e_ac3"
+	HLSSegmentFormatFMP4 HLSSegmentFormat = "fmp4"
+
+	HLSVideoSegmentFormatMPEG2TS HLSVideoSegmentFormat = "mpeg2_ts"
+	HLSVideoSegmentFormatFMP4    HLSVideoSegmentFormat = "fmp4"
 )
 
 var (
-	LoadFailed    = errors.New("Load failed")
-	LoadCancelled = errors.New("Load cancelled")
+	LoadFailed         = errors.New("Load failed")
+	LoadCancelled      = errors.New("Load cancelled")
+	InvalidPlayerState = errors.New("Invalid player state")
+)
+
+type ResumeState string
+
+const (
+	// ResumeStateUnchanged keeps playing or paused, whatever the media was
+	// doing before the seek.
+	ResumeStateUnchanged ResumeState = ""
+	ResumeStatePlay      ResumeState = "PLAYBACK_START"
+	ResumeStatePause     ResumeState = "PLAYBACK_PAUSE"
 )
 
 type MediaInfo struct {
-	ContentID      string                 `json:"contentId"`
-	ContentType    string                 `json:"contentType"`
-	CustomData     interface{}            `json:"customData,omitempty"`
-	MediaTracks    []MediaTrack           `json:"mediaTracks,omitempty"`
-	Metadata       map[string]interface{} `json:"mediaMetadata,omitempty"`
-	StreamDuration time.Duration          `json:"streamDuration,omitempty"`
-	StreamType     StreamType             `json:"streamType"`
-	// TODO TextTrackStyle
-}
-
-type MediaTrack struct {
-	ID          int64        `json:"id"`
-	ContentID   string       `json:"contentId"`
-	ContentType string       `json:"contentType"`
-	CustomData  interface{}  `json:"customData"`
-	Language    string       `json:"language"`
-	Name        string       `json:"name"`
-	Type        TrackType    `json:"type"`
-	Subtype     TrackSubtype `json:"subtype"`
+	ContentID   string        `json:"contentId"`
+	ContentType string        `json:"contentType"`
+	CustomData  interface{}   `json:"customData,omitempty"`
+	MediaTracks []MediaTrack  `json:"tracks,omitempty"`
+	Metadata    MediaMetadata `json:"metadata,omitempty"`
+	// StreamDuration is the length of the media, or UnknownDuration for
+	// live streams and when it isn't known, which is also what a missing
+	// duration decodes as. Zero is sent as unknown too.
+	StreamDuration Duration        `json:"duration"`
+	StreamType     StreamType      `json:"streamType"`
+	TextTrackStyle *TextTrackStyle `json:"textTrackStyle,omitempty"`
+
+	HLSSegmentFormat      HLSSegmentFormat      `json:"hlsSegmentFormat,omitempty"`
+	HLSVideoSegmentFormat HLSVideoSegmentFormat `json:"hlsVideoSegmentFormat,omitempty"`
+
+	// Extra holds the fields we don't know about, as in MediaStatus.
+	Extra map[string]json.RawMessage `json:"-"`
 }
 
 type LoadOptions struct {
-	AutoPlay       bool          `json:"autoplay,omitempty"`
-	PlayPosition   time.Duration `json:"currentTime,omitempty"`
-	ActiveTrackIDs []int64       `json:"activeTrackIds,omitempty"`
-	CustomData     interface{}   `json:"customData,omitempty"`
-	// TODO RepeatMode?
+	AutoPlay       bool        `json:"autoplay,omitempty"`
+	PlayPosition   Duration    `json:"currentTime,omitempty"`
+	ActiveTrackIDs []int64     `json:"activeTrackIds,omitempty"`
+	CustomData     interface{} `json:"customData,omitempty"`
 }
 
-func (r *MediaController) Load(media MediaInfo, options LoadOptions) ([]MediaStatus, error) {
+func (r *MediaController) Load(ctx context.Context, media MediaInfo, options LoadOptions) ([]MediaStatus, error) {
+	if err := checkActiveTracks(media.MediaTracks, options.ActiveTrackIDs); err != nil {
+		return nil, err
+	}
+
 	request := &struct {
 		LoadOptions
 		RequestHeader
@@ -137,29 +158,23 @@ func (r *MediaController) Load(media MediaInfo, options LoadOptions) ([]MediaSta
 		Media:       media,
 	}
 
-	response, err := r.rm.Request(request)
+	response, err := r.rm.Request(ctx, request)
 	if err != nil {
 		return nil, err
 	}
 
-	responseHeader := &ResponseHeader{}
-	err = response.Unmarshal(responseHeader)
-	if err != nil {
+	if err := mediaResponseError(response); err != nil {
 		return nil, err
 	}
 
-	if responseHeader.Type == "LOAD_FAILED" {
-		return nil, LoadFailed
-	} else if responseHeader.Type == "LOAD_CANCELLED" {
-		return nil, LoadCancelled
-	}
-
 	mediaStatusResponse := &mediaStatusResponse{}
 	err = response.Unmarshal(mediaStatusResponse)
 	if err != nil {
 		return nil, err
 	}
 
+	r.watchers.publish(mediaStatusResponse.Status)
+
 	return mediaStatusResponse.Status, nil
 }
 
@@ -168,47 +183,108 @@ type sessionRequest struct {
 	MediaSessionID int `json:"mediaSessionId"`
 }
 
-func (r *MediaController) Play(sessionId int) ([]MediaStatus, error) {
-	return r.sessionRequest(sessionId, "PLAY")
+func (r *MediaController) Play(ctx context.Context, sessionId int) ([]MediaStatus, error) {
+	return r.sessionRequest(ctx, sessionId, "PLAY")
 }
 
-func (r *MediaController) Seek(sessionId int, position float64) ([]MediaStatus, error) {
+func (r *MediaController) Pause(ctx context.Context, sessionId int) ([]MediaStatus, error) {
+	return r.sessionRequest(ctx, sessionId, "PAUSE")
+}
+
+// Stop stops playback and ends the media session. The app keeps running.
+func (r *MediaController) Stop(ctx context.Context, sessionId int) ([]MediaStatus, error) {
+	return r.sessionRequest(ctx, sessionId, "STOP")
+}
+
+func (r *MediaController) Seek(ctx context.Context, sessionId int, position Duration, resumeState ResumeState) ([]MediaStatus, error) {
 	request := &struct {
 		sessionRequest
-		CurrentTime float64 `json:"currentTime"`
+		CurrentTime Duration    `json:"currentTime"`
+		ResumeState ResumeState `json:"resumeState,omitempty"`
 	}{
-		sessionRequest: sessionRequest{
-			RequestHeader: RequestHeader{
-				PayloadHeaders: PayloadHeaders{Type: "SEEK"},
-			},
-			MediaSessionID: sessionId,
-		},
-		CurrentTime: position,
+		sessionRequest: newSessionRequest(sessionId, "SEEK"),
+		CurrentTime:    position,
+		ResumeState:    resumeState,
 	}
-	return r.requestStatus(request)
+	return r.requestStatus(ctx, request)
+}
+
+// SetVolume sets the volume of the media stream, which is relative to the
+// device volume.
+func (r *MediaController) SetVolume(ctx context.Context, sessionId int, level float64) ([]MediaStatus, error) {
+	return r.setVolume(ctx, sessionId, &volumeLevel{Level: level})
+}
+
+func (r *MediaController) SetMuted(ctx context.Context, sessionId int, muted bool) ([]MediaStatus, error) {
+	return r.setVolume(ctx, sessionId, &volumeMuted{Muted: muted})
 }
 
-func (r *MediaController) sessionRequest(sessionId int, typ string) ([]MediaStatus, error) {
-	request := &sessionRequest{
+func (r *MediaController) setVolume(ctx context.Context, sessionId int, volume interface{}) ([]MediaStatus, error) {
+	request := &struct {
+		sessionRequest
+		Volume interface{} `json:"volume"`
+	}{
+		sessionRequest: newSessionRequest(sessionId, "SET_VOLUME"),
+		Volume:         volume,
+	}
+	return r.requestStatus(ctx, request)
+}
+
+func (r *MediaController) SetPlaybackRate(ctx context.Context, sessionId int, rate float64) ([]MediaStatus, error) {
+	request := &struct {
+		sessionRequest
+		PlaybackRate float64 `json:"playbackRate"`
+	}{
+		sessionRequest: newSessionRequest(sessionId, "SET_PLAYBACK_RATE"),
+		PlaybackRate:   rate,
+	}
+	return r.requestStatus(ctx, request)
+}
+
+func newSessionRequest(sessionId int, typ string) sessionRequest {
+	return sessionRequest{
 		RequestHeader: RequestHeader{
 			PayloadHeaders: PayloadHeaders{Type: typ},
 		},
 		MediaSessionID: sessionId,
 	}
-	return r.requestStatus(request)
 }
 
-func (r *MediaController) requestStatus(request Request) ([]MediaStatus, error) {
-	response, err := r.rm.Request(request)
+func (r *MediaController) sessionRequest(ctx context.Context, sessionId int, typ string) ([]MediaStatus, error) {
+	request := newSessionRequest(sessionId, typ)
+	return r.requestStatus(ctx, &request)
+}
+
+// mediaResponseError returns the error a media response stands for, if any.
+func mediaResponseError(response *ResponseHeaders) error {
+	switch response.Type() {
+	case "LOAD_FAILED":
+		return LoadFailed
+	case "LOAD_CANCELLED":
+		return LoadCancelled
+	case "INVALID_PLAYER_STATE":
+		return InvalidPlayerState
+	}
+	return nil
+}
+
+func (r *MediaController) requestStatus(ctx context.Context, request Request) ([]MediaStatus, error) {
+	response, err := r.rm.Request(ctx, request)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := mediaResponseError(response); err != nil {
+		return nil, err
+	}
+
 	mediaStatusResponse := &mediaStatusResponse{}
 	err = response.Unmarshal(mediaStatusResponse)
 	if err != nil {
 		return nil, err
 	}
 
+	r.watchers.publish(mediaStatusResponse.Status)
+
 	return mediaStatusResponse.Status, nil
 }
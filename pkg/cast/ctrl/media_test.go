@@ -0,0 +1,103 @@
+package ctrl
+
+import (
+	"testing"
+
+	"github.com/ravishi/go-cast/pkg/cast/castest"
+	"golang.org/x/net/context"
+)
+
+var testMovie = MediaInfo{
+	ContentID:      "http://example.com/movie.mp4",
+	ContentType:    "video/mp4",
+	StreamType:     StreamTypeBuffered,
+	StreamDuration: Seconds(600),
+}
+
+func loadMovie(t *testing.T, ctx context.Context, media *MediaController) int {
+	statuses, err := media.Load(ctx, testMovie, LoadOptions{AutoPlay: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return statuses[0].MediaSessionID
+}
+
+// near tells whether position is want, give or take the time the fake
+// receiver played in between.
+func near(position, want Duration) bool {
+	return position >= want && position < want+Seconds(1)
+}
+
+func TestMediaCommands(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	ctx := testContext(t)
+	media := launchMedia(t, ctx, connect(t, r))
+	sessionId := loadMovie(t, ctx, media)
+
+	tests := []struct {
+		name    string
+		request func() ([]MediaStatus, error)
+		check   func(s MediaStatus) bool
+	}{
+		{"pause", func() ([]MediaStatus, error) { return media.Pause(ctx, sessionId) },
+			func(s MediaStatus) bool { return s.PlayerState == PlayerStatePaused }},
+		{"play", func() ([]MediaStatus, error) { return media.Play(ctx, sessionId) },
+			func(s MediaStatus) bool { return s.PlayerState == PlayerStatePlaying }},
+		{"seek paused", func() ([]MediaStatus, error) {
+			return media.Seek(ctx, sessionId, Seconds(90), ResumeStatePause)
+		}, func(s MediaStatus) bool {
+			return s.PlayerState == PlayerStatePaused && near(s.CurrentTime, Seconds(90))
+		}},
+		{"seek", func() ([]MediaStatus, error) { return media.Seek(ctx, sessionId, Seconds(30), ResumeStateUnchanged) },
+			func(s MediaStatus) bool {
+				return s.PlayerState == PlayerStatePaused && near(s.CurrentTime, Seconds(30))
+			}},
+		{"volume", func() ([]MediaStatus, error) { return media.SetVolume(ctx, sessionId, 0.5) },
+			func(s MediaStatus) bool { return s.Volume != nil && s.Volume.Level == 0.5 && !s.Volume.Muted }},
+		{"mute", func() ([]MediaStatus, error) { return media.SetMuted(ctx, sessionId, true) },
+			func(s MediaStatus) bool { return s.Volume != nil && s.Volume.Level == 0.5 && s.Volume.Muted }},
+		{"rate", func() ([]MediaStatus, error) { return media.SetPlaybackRate(ctx, sessionId, 1.5) },
+			func(s MediaStatus) bool { return s.PlaybackRate == 1.5 }},
+		{"stop", func() ([]MediaStatus, error) { return media.Stop(ctx, sessionId) },
+			func(s MediaStatus) bool {
+				return s.PlayerState == PlayerStateIdle && s.IdleReason == IdleReasonCancelled
+			}},
+	}
+	for _, test := range tests {
+		statuses, err := test.request()
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		} else if len(statuses) != 1 || !test.check(statuses[0]) {
+			t.Errorf("%s: got %+v", test.name, statuses)
+		}
+	}
+}
+
+func TestMediaCommandErrors(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	ctx := testContext(t)
+	device := connect(t, r)
+	media := launchMedia(t, ctx, device)
+	sessionId := loadMovie(t, ctx, media)
+
+	_, err := media.Play(ctx, sessionId+1)
+	if e, ok := err.(*InvalidRequestError); !ok || e.Reason != "INVALID_MEDIA_SESSION_ID" {
+		t.Errorf("playing another session: got %v", err)
+	}
+
+	_, err = media.SetPlaybackRate(ctx, sessionId, 0)
+	if e, ok := err.(*InvalidRequestError); !ok || e.Reason != "INVALID_PARAMS" {
+		t.Errorf("stopping the playback with its rate: got %v", err)
+	}
+
+	status, err := newTestReceiver(t, device).GetStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.SetPlayerState(status.MediaApp("").SessionID, "IDLE")
+	if _, err := media.Pause(ctx, sessionId); err != InvalidPlayerState {
+		t.Errorf("pausing idle media: got %v, want %v", err, InvalidPlayerState)
+	}
+}
@@ -0,0 +1,240 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+type PlayerState string
+
+const (
+	PlayerStateIdle      PlayerState = "IDLE"
+	PlayerStatePlaying   PlayerState = "PLAYING"
+	PlayerStatePaused    PlayerState = "PAUSED"
+	PlayerStateBuffering PlayerState = "BUFFERING"
+	PlayerStateLoading   PlayerState = "LOADING"
+)
+
+type IdleReason string
+
+const (
+	IdleReasonCancelled   IdleReason = "CANCELLED"
+	IdleReasonInterrupted IdleReason = "INTERRUPTED"
+	IdleReasonFinished    IdleReason = "FINISHED"
+	IdleReasonError       IdleReason = "ERROR"
+)
+
+// MediaCommands is the bitmask of commands a media session supports.
+type MediaCommands int
+
+const (
+	MediaCommandPause          MediaCommands = 1
+	MediaCommandSeek           MediaCommands = 2
+	MediaCommandStreamVolume   MediaCommands = 4
+	MediaCommandStreamMute     MediaCommands = 8
+	MediaCommandSkipForward    MediaCommands = 16
+	MediaCommandSkipBackward   MediaCommands = 32
+	MediaCommandQueueNext      MediaCommands = 64
+	MediaCommandQueuePrev      MediaCommands = 128
+	MediaCommandQueueShuffle   MediaCommands = 256
+	MediaCommandSkipAd         MediaCommands = 512
+	MediaCommandQueueRepeatAll MediaCommands = 1024
+	MediaCommandQueueRepeatOne MediaCommands = 2048
+	MediaCommandEditTracks     MediaCommands = 4096
+	MediaCommandPlaybackRate   MediaCommands = 8192
+	MediaCommandLike           MediaCommands = 16384
+	MediaCommandDislike        MediaCommands = 32768
+	MediaCommandFollow         MediaCommands = 65536
+	MediaCommandUnfollow       MediaCommands = 131072
+	MediaCommandStreamTransfer MediaCommands = 262144
+)
+
+var mediaCommandNames = []struct {
+	command MediaCommands
+	name    string
+}{
+	{MediaCommandPause, "PAUSE"},
+	{MediaCommandSeek, "SEEK"},
+	{MediaCommandStreamVolume, "STREAM_VOLUME"},
+	{MediaCommandStreamMute, "STREAM_MUTE"},
+	{MediaCommandSkipForward, "SKIP_FORWARD"},
+	{MediaCommandSkipBackward, "SKIP_BACKWARD"},
+	{MediaCommandQueueNext, "QUEUE_NEXT"},
+	{MediaCommandQueuePrev, "QUEUE_PREV"},
+	{MediaCommandQueueShuffle, "QUEUE_SHUFFLE"},
+	{MediaCommandSkipAd, "SKIP_AD"},
+	{MediaCommandQueueRepeatAll, "QUEUE_REPEAT_ALL"},
+	{MediaCommandQueueRepeatOne, "QUEUE_REPEAT_ONE"},
+	{MediaCommandEditTracks, "EDIT_TRACKS"},
+	{MediaCommandPlaybackRate, "PLAYBACK_RATE"},
+	{MediaCommandLike, "LIKE"},
+	{MediaCommandDislike, "DISLIKE"},
+	{MediaCommandFollow, "FOLLOW"},
+	{MediaCommandUnfollow, "UNFOLLOW"},
+	{MediaCommandStreamTransfer, "STREAM_TRANSFER"},
+}
+
+// Has reports whether every command in c is supported.
+func (m MediaCommands) Has(c MediaCommands) bool {
+	return m&c == c
+}
+
+func (m MediaCommands) String() string {
+	var names []string
+	for _, c := range mediaCommandNames {
+		if m.Has(c.command) {
+			names = append(names, c.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+type VideoInfo struct {
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	HdrType string `json:"hdrType,omitempty"`
+}
+
+// ExtendedMediaStatus is sent while the receiver is busy with a new media
+// before it replaces the current one, e.g. while it is being loaded.
+type ExtendedMediaStatus struct {
+	PlayerState    PlayerState `json:"playerState"`
+	Media          *MediaInfo  `json:"media,omitempty"`
+	MediaSessionID int         `json:"mediaSessionId,omitempty"`
+}
+
+type LiveSeekableRange struct {
+	Start          Duration `json:"start"`
+	End            Duration `json:"end"`
+	IsMovingWindow bool     `json:"isMovingWindow"`
+	IsLiveDone     bool     `json:"isLiveDone"`
+}
+
+type MediaStatus struct {
+	MediaSessionID int `json:"mediaSessionId"`
+	// Media is only sent when it changes, so it is usually nil except in
+	// the first status after a load and in replies to GET_STATUS.
+	Media                  *MediaInfo             `json:"media,omitempty"`
+	PlaybackRate           float64                `json:"playbackRate"`
+	PlayerState            PlayerState            `json:"playerState"`
+	IdleReason             IdleReason             `json:"idleReason,omitempty"`
+	CurrentTime            Duration               `json:"currentTime"`
+	SupportedMediaCommands MediaCommands          `json:"supportedMediaCommands"`
+	Volume                 *Volume                `json:"volume,omitempty"`
+	ActiveTrackIDs         []int64                `json:"activeTrackIds,omitempty"`
+	RepeatMode             RepeatMode             `json:"repeatMode,omitempty"`
+	CurrentItemID          int                    `json:"currentItemId,omitempty"`
+	LoadingItemID          int                    `json:"loadingItemId,omitempty"`
+	PreloadedItemID        int                    `json:"preloadedItemId,omitempty"`
+	Items                  []QueueItem            `json:"items,omitempty"`
+	VideoInfo              *VideoInfo             `json:"videoInfo,omitempty"`
+	LiveSeekableRange      *LiveSeekableRange     `json:"liveSeekableRange,omitempty"`
+	ExtendedStatus         *ExtendedMediaStatus   `json:"extendedStatus,omitempty"`
+	CustomData             map[string]interface{} `json:"customData,omitempty"`
+
+	// Extra holds the fields we don't know about, so that they survive if
+	// the status is encoded again. Its media, items, tracks and metadata
+	// keep theirs too.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// mediaStatusFields has the fields of MediaStatus but none of its methods,
+// so it can be (un)marshaled without recursion.
+type mediaStatusFields MediaStatus
+
+var knownMediaStatusFields = jsonFieldNames(reflect.TypeOf(MediaStatus{}))
+
+func (s *MediaStatus) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*mediaStatusFields)(s)); err != nil {
+		return err
+	}
+	extra, err := extraFields(data, knownMediaStatusFields)
+	s.Extra = extra
+	return err
+}
+
+func (s MediaStatus) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(mediaStatusFields(s))
+	if err != nil {
+		return nil, err
+	}
+	return addExtraFields(data, s.Extra)
+}
+
+func (s *MediaStatus) CanPause() bool {
+	return s.SupportedMediaCommands.Has(MediaCommandPause)
+}
+
+func (s *MediaStatus) CanSeek() bool {
+	return s.SupportedMediaCommands.Has(MediaCommandSeek)
+}
+
+func (s *MediaStatus) CanSetVolume() bool {
+	return s.SupportedMediaCommands.Has(MediaCommandStreamVolume)
+}
+
+func (s *MediaStatus) CanMute() bool {
+	return s.SupportedMediaCommands.Has(MediaCommandStreamMute)
+}
+
+// Duration returns the length of the media, or UnknownDuration for live
+// streams and when the status doesn't include the media.
+func (s *MediaStatus) Duration() Duration {
+	if s.Media == nil || s.Media.StreamDuration <= 0 {
+		return UnknownDuration
+	}
+	return s.Media.StreamDuration
+}
+
+// jsonFieldNames returns the names of the fields of a struct type in their
+// JSON form.
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" || f.PkgPath != "" {
+			continue
+		} else if name == "" {
+			name = f.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// extraFields returns the fields of the JSON object data that aren't known,
+// or nil if there are none.
+func extraFields(data []byte, known map[string]bool) (map[string]json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name := range known {
+		delete(fields, name)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// addExtraFields adds extra to the JSON object data, short of the fields it
+// has already.
+func addExtraFields(data []byte, extra map[string]json.RawMessage) ([]byte, error) {
+	if len(extra) == 0 {
+		return data, nil
+	}
+
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for name, value := range extra {
+		if _, ok := fields[name]; !ok {
+			fields[name] = value
+		}
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,99 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestMediaStatusExtra(t *testing.T) {
+	in := `{
+		"mediaSessionId": 1,
+		"playerState": "PLAYING",
+		"currentTime": 12.5,
+		"supportedMediaCommands": 15,
+		"playbackRate": 1,
+		"breakStatus": {"currentBreakTime": 3},
+		"queueData": {"name": "Mix"},
+		"media": {
+			"contentId": "http://example.com/a.mp4",
+			"contentType": "video/mp4",
+			"streamType": "BUFFERED",
+			"duration": 60,
+			"breakClips": [{"id": "ad"}],
+			"tracks": [{"trackId": 1, "type": "TEXT", "isInband": true}],
+			"metadata": {"metadataType": 1, "title": "Movie", "rating": "PG"}
+		},
+		"items": [{"itemId": 3, "autoplay": true, "orderId": 0}]
+	}`
+
+	var s MediaStatus
+	if err := json.Unmarshal([]byte(in), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.MediaSessionID != 1 || s.PlayerState != PlayerStatePlaying || s.CurrentTime != Seconds(12.5) {
+		t.Errorf("decoded %+v", s)
+	}
+	if !s.CanPause() || !s.CanSeek() || s.Duration() != Seconds(60) {
+		t.Errorf("decoded commands %s and duration %s", s.SupportedMediaCommands, s.Duration())
+	}
+
+	var names []string
+	for name := range s.Extra {
+		names = append(names, name)
+	}
+	if len(names) != 2 || s.Extra["breakStatus"] == nil || s.Extra["queueData"] == nil {
+		t.Errorf("kept %v", names)
+	}
+
+	out, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(out, &fields); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"currentBreakTime": float64(3)}
+	if !reflect.DeepEqual(fields["breakStatus"], want) {
+		t.Errorf("encoded breakStatus as %v", fields["breakStatus"])
+	}
+
+	// So do the unknown fields of the media, its tracks and metadata, and
+	// of the items.
+	if e := s.Media.Extra; len(e) != 1 || string(e["breakClips"]) != `[{"id": "ad"}]` {
+		t.Errorf("kept %s of the media", e)
+	}
+	if e := s.Media.MediaTracks[0].Extra; len(e) != 1 || string(e["isInband"]) != "true" {
+		t.Errorf("kept %s of the track", e)
+	}
+	if m, ok := s.Media.Metadata.(MovieMediaMetadata); !ok || m.Title != "Movie" || len(m.Extra) != 1 || string(m.Extra["rating"]) != `"PG"` {
+		t.Errorf("decoded metadata %#v", s.Media.Metadata)
+	}
+	if e := s.Items[0].Extra; len(e) != 1 || string(e["orderId"]) != "0" {
+		t.Errorf("kept %s of the item", e)
+	}
+	media := fields["media"].(map[string]interface{})
+	if !reflect.DeepEqual(media["breakClips"], []interface{}{map[string]interface{}{"id": "ad"}}) {
+		t.Errorf("encoded breakClips as %v", media["breakClips"])
+	}
+	if track := media["tracks"].([]interface{})[0].(map[string]interface{}); track["isInband"] != true {
+		t.Errorf("encoded the track as %v", track)
+	}
+	if metadata := media["metadata"].(map[string]interface{}); metadata["rating"] != "PG" || metadata["metadataType"] != float64(1) {
+		t.Errorf("encoded the metadata as %v", metadata)
+	}
+	if item := fields["items"].([]interface{})[0].(map[string]interface{}); item["orderId"] != float64(0) {
+		t.Errorf("encoded the item as %v", item)
+	}
+
+	// Known fields win over extras of the same name.
+	s.Extra["mediaSessionId"] = json.RawMessage("7")
+	out, _ = json.Marshal(s)
+	var again MediaStatus
+	if err := json.Unmarshal(out, &again); err != nil {
+		t.Fatal(err)
+	} else if again.MediaSessionID != 1 {
+		t.Errorf("an extra overrode mediaSessionId: %d", again.MediaSessionID)
+	}
+}
@@ -0,0 +1,175 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// MediaStatusEvent is a media status as reported by the device, either in a
+// broadcast or in response to one of our own requests.
+type MediaStatusEvent struct {
+	Status   MediaStatus
+	Received time.Time
+}
+
+// EstimatedPosition extrapolates the playback position at the given time
+// from the last reported position, player state and playback rate. It only
+// moves while the media is PLAYING.
+func (e *MediaStatusEvent) EstimatedPosition(at time.Time) Duration {
+	position := e.Status.CurrentTime
+	if e.Status.PlayerState == PlayerStatePlaying && at.After(e.Received) {
+		position += Duration(float64(at.Sub(e.Received)) * e.Status.PlaybackRate)
+	}
+	if position < 0 {
+		position = 0
+	}
+	return position
+}
+
+type mediaWatchers struct {
+	mu     sync.Mutex
+	subs   map[chan MediaStatusEvent]struct{}
+	closed bool
+
+	// last has the latest media seen for each live media session.
+	last map[int]*MediaInfo
+	// current is the media session that plays, or 0 if there's none.
+	current int
+}
+
+// Watch returns a channel that gets every media status the controller
+// sees, including changes made by other senders or on the device itself,
+// until ctx is done or the controller is closed. A reader that falls behind
+// loses the oldest statuses first, which newer ones supersede anyway.
+func (r *MediaController) Watch(ctx context.Context) <-chan MediaStatusEvent {
+	ch := make(chan MediaStatusEvent, watchQueueSize)
+
+	w := &r.watchers
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		close(ch)
+		return ch
+	}
+	w.subs[ch] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-r.ctx.Done():
+		}
+		w.remove(ch)
+	}()
+
+	return ch
+}
+
+func (w *mediaWatchers) remove(ch chan MediaStatusEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.subs[ch]; ok {
+		delete(w.subs, ch)
+		close(ch)
+	}
+}
+
+func (w *mediaWatchers) publish(status []MediaStatus) {
+	received := time.Now()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(status) == 0 {
+		w.current = 0
+	}
+	for _, s := range status {
+		if s.PlayerState == PlayerStateIdle && s.IdleReason != "" {
+			delete(w.last, s.MediaSessionID)
+			// Queues go on to their next item under the same session.
+			if s.MediaSessionID == w.current && s.LoadingItemID == 0 {
+				w.current = 0
+			}
+		} else {
+			if s.Media != nil {
+				w.last[s.MediaSessionID] = s.Media
+			}
+			if s.MediaSessionID != 0 {
+				w.current = s.MediaSessionID
+			}
+		}
+
+		e := MediaStatusEvent{Status: s, Received: received}
+		for ch := range w.subs {
+			offerMediaStatusEvent(ch, e)
+		}
+	}
+}
+
+// media returns the media of the given session, or nil if we haven't seen
+// it yet.
+func (w *mediaWatchers) media(sessionId int) *MediaInfo {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.last[sessionId]
+}
+
+// SessionID returns the media session that plays, as last reported by the
+// device, or 0 if there's none.
+func (r *MediaController) SessionID() int {
+	w := &r.watchers
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.current
+}
+
+func (w *mediaWatchers) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	for ch := range w.subs {
+		delete(w.subs, ch)
+		close(ch)
+	}
+}
+
+// offerMediaStatusEvent queues e for a Watch reader without blocking the
+// controller.
+func offerMediaStatusEvent(ch chan MediaStatusEvent, e MediaStatusEvent) {
+	dropOldest(func() bool {
+		select {
+		case ch <- e:
+			return true
+		default:
+			return false
+		}
+	}, func() {
+		select {
+		case <-ch:
+		default:
+		}
+	})
+}
+
+// watchForever publishes the statuses the device sends without being asked
+// until the channel is closed.
+func (r *MediaController) watchForever() {
+	defer r.watchers.close()
+
+	for message := range r.ch.Read() {
+		response := &mediaStatusResponse{}
+		err := json.Unmarshal([]byte(message.GetPayloadUtf8()), response)
+		if err != nil {
+			log.Println("Error while unmarshaling media status:", err)
+			continue
+		}
+
+		if response.Type == "MEDIA_STATUS" {
+			r.watchers.publish(response.Status)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package ctrl
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast/castest"
+)
+
+func TestEstimatedPosition(t *testing.T) {
+	received := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		state    PlayerState
+		rate     float64
+		position Duration
+		at       time.Duration
+		want     Duration
+	}{
+		{PlayerStatePlaying, 1, Seconds(10), 5 * time.Second, Seconds(15)},
+		{PlayerStatePlaying, 2, Seconds(10), 5 * time.Second, Seconds(20)},
+		{PlayerStatePlaying, 1, Seconds(10), -5 * time.Second, Seconds(10)},
+		{PlayerStatePlaying, -1, Seconds(2), 5 * time.Second, 0},
+		{PlayerStatePaused, 1, Seconds(10), 5 * time.Second, Seconds(10)},
+		{PlayerStateBuffering, 1, Seconds(10), 5 * time.Second, Seconds(10)},
+	}
+	for _, test := range tests {
+		e := MediaStatusEvent{
+			Status: MediaStatus{
+				PlayerState:  test.state,
+				PlaybackRate: test.rate,
+				CurrentTime:  test.position,
+			},
+			Received: received,
+		}
+		if got := e.EstimatedPosition(received.Add(test.at)); got != test.want {
+			t.Errorf("%s at %v from %s after %s: got %s, want %s",
+				test.state, test.rate, test.position, test.at, got, test.want)
+		}
+	}
+}
+
+func TestOfferMediaStatusEvent(t *testing.T) {
+	ch := make(chan MediaStatusEvent, 2)
+	for id := 1; id <= 3; id++ {
+		offerMediaStatusEvent(ch, MediaStatusEvent{Status: MediaStatus{MediaSessionID: id}})
+	}
+	for _, want := range []int{2, 3} {
+		if e := <-ch; e.Status.MediaSessionID != want {
+			t.Errorf("got %d, want %d", e.Status.MediaSessionID, want)
+		}
+	}
+}
+
+// TestMediaWatch controls the media from one sender and watches it from
+// another.
+func TestMediaWatch(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	ctx := testContext(t)
+
+	media := launchMedia(t, ctx, connect(t, r))
+	statuses, err := media.Load(ctx, MediaInfo{ContentID: "http://example.com/a.mp4", ContentType: "video/mp4"}, LoadOptions{AutoPlay: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionId := statuses[0].MediaSessionID
+	if media.SessionID() != sessionId {
+		t.Errorf("loaded session %d, but SessionID is %d", sessionId, media.SessionID())
+	}
+
+	other, err := newTestReceiver(t, connect(t, r)).JoinMedia(ctx, testSenderId, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	events := other.Media.Watch(ctx)
+
+	next := func() MediaStatus {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatal("the watch stopped")
+			} else if e.Received.IsZero() {
+				t.Error("the event has no time")
+			}
+			return e.Status
+		case <-time.After(5 * time.Second):
+			t.Fatal("no event")
+		}
+		return MediaStatus{}
+	}
+
+	if _, err := media.Pause(ctx, sessionId); err != nil {
+		t.Fatal(err)
+	}
+	if s := next(); s.MediaSessionID != sessionId || s.PlayerState != PlayerStatePaused {
+		t.Errorf("got %d %s, want %d PAUSED", s.MediaSessionID, s.PlayerState, sessionId)
+	}
+
+	if _, err := media.Stop(ctx, sessionId); err != nil {
+		t.Fatal(err)
+	}
+	if s := next(); s.PlayerState != PlayerStateIdle || s.IdleReason != "CANCELLED" {
+		t.Errorf("got %s (%s), want IDLE (CANCELLED)", s.PlayerState, s.IdleReason)
+	}
+	if id := other.SessionID(); id != 0 {
+		t.Errorf("still following session %d after it stopped", id)
+	}
+
+	other.Media.Close()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return
+			}
+		case <-timeout:
+			t.Fatal("the watch outlived the controller")
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// MediaMetadata describes a media. It is one of GenericMediaMetadata,
+// MovieMediaMetadata, TvShowMediaMetadata, MusicTrackMediaMetadata,
+// PhotoMediaMetadata or, for any other type, RawMediaMetadata.
+type MediaMetadata interface {
+	MetadataType() MediaType
+}
+
+type Image struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width,omitempty"`
+	Height int    `json:"height,omitempty"`
+}
+
+// Dates are ISO 8601 strings, e.g. "2006-01-02" or "2006-01-02T15:04:05Z".
+// Extra holds the fields we don't know about, as in MediaStatus.
+
+type GenericMediaMetadata struct {
+	Title       string  `json:"title,omitempty"`
+	Subtitle    string  `json:"subtitle,omitempty"`
+	Images      []Image `json:"images,omitempty"`
+	ReleaseDate string  `json:"releaseDate,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type MovieMediaMetadata struct {
+	Title       string  `json:"title,omitempty"`
+	Subtitle    string  `json:"subtitle,omitempty"`
+	Studio      string  `json:"studio,omitempty"`
+	Images      []Image `json:"images,omitempty"`
+	ReleaseDate string  `json:"releaseDate,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type TvShowMediaMetadata struct {
+	SeriesTitle     string  `json:"seriesTitle,omitempty"`
+	Title           string  `json:"title,omitempty"`
+	Season          int     `json:"season,omitempty"`
+	Episode         int     `json:"episode,omitempty"`
+	Images          []Image `json:"images,omitempty"`
+	OriginalAirDate string  `json:"originalAirdate,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type MusicTrackMediaMetadata struct {
+	Title       string  `json:"title,omitempty"`
+	AlbumName   string  `json:"albumName,omitempty"`
+	AlbumArtist string  `json:"albumArtist,omitempty"`
+	Artist      string  `json:"artist,omitempty"`
+	Composer    string  `json:"composer,omitempty"`
+	TrackNumber int     `json:"trackNumber,omitempty"`
+	DiscNumber  int     `json:"discNumber,omitempty"`
+	Images      []Image `json:"images,omitempty"`
+	ReleaseDate string  `json:"releaseDate,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+type PhotoMediaMetadata struct {
+	Title            string   `json:"title,omitempty"`
+	Artist           string   `json:"artist,omitempty"`
+	Location         string   `json:"location,omitempty"`
+	Latitude         *float64 `json:"latitude,omitempty"`
+	Longitude        *float64 `json:"longitude,omitempty"`
+	Width            int      `json:"width,omitempty"`
+	Height           int      `json:"height,omitempty"`
+	Images           []Image  `json:"images,omitempty"`
+	CreationDateTime string   `json:"creationDateTime,omitempty"`
+
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// RawMediaMetadata holds metadata of a type we don't know, e.g.
+// MediaTypeUser, as it was sent.
+type RawMediaMetadata struct {
+	Type   MediaType
+	Fields map[string]interface{}
+}
+
+func (GenericMediaMetadata) MetadataType() MediaType    { return MediaTypeGeneric }
+func (MovieMediaMetadata) MetadataType() MediaType      { return MediaTypeMovie }
+func (TvShowMediaMetadata) MetadataType() MediaType     { return MediaTypeTVShow }
+func (MusicTrackMediaMetadata) MetadataType() MediaType { return MediaTypeMusicTrack }
+func (PhotoMediaMetadata) MetadataType() MediaType      { return MediaTypePhoto }
+func (m RawMediaMetadata) MetadataType() MediaType      { return m.Type }
+
+// The aliases below have the fields of each metadata type but none of its
+// methods, so they can be marshaled without recursion.
+type (
+	genericMediaMetadata    GenericMediaMetadata
+	movieMediaMetadata      MovieMediaMetadata
+	tvShowMediaMetadata     TvShowMediaMetadata
+	musicTrackMediaMetadata MusicTrackMediaMetadata
+	photoMediaMetadata      PhotoMediaMetadata
+)
+
+func (m GenericMediaMetadata) MarshalJSON() ([]byte, error) {
+	return marshalMetadata(m, (*genericMediaMetadata)(&m), m.Extra)
+}
+
+func (m MovieMediaMetadata) MarshalJSON() ([]byte, error) {
+	return marshalMetadata(m, (*movieMediaMetadata)(&m), m.Extra)
+}
+
+func (m TvShowMediaMetadata) MarshalJSON() ([]byte, error) {
+	return marshalMetadata(m, (*tvShowMediaMetadata)(&m), m.Extra)
+}
+
+func (m MusicTrackMediaMetadata) MarshalJSON() ([]byte, error) {
+	return marshalMetadata(m, (*musicTrackMediaMetadata)(&m), m.Extra)
+}
+
+func (m PhotoMediaMetadata) MarshalJSON() ([]byte, error) {
+	return marshalMetadata(m, (*photoMediaMetadata)(&m), m.Extra)
+}
+
+func (m RawMediaMetadata) MarshalJSON() ([]byte, error) {
+	fields := make(map[string]interface{}, len(m.Fields)+1)
+	for k, v := range m.Fields {
+		fields[k] = v
+	}
+	fields["metadataType"] = m.Type
+	return json.Marshal(fields)
+}
+
+// marshalMetadata encodes fields, which must be a pointer to a struct, along
+// with the metadataType of m and the extra fields.
+func marshalMetadata(m MediaMetadata, fields interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	typ, err := json.Marshal(m.MetadataType())
+	if err != nil {
+		return nil, err
+	}
+
+	out := append([]byte(`{"metadataType":`), typ...)
+	if len(data) > 2 {
+		out = append(out, ',')
+	}
+	return addExtraFields(append(out, data[1:]...), extra)
+}
+
+// decodeMetadata decodes metadata into the type given by its metadataType.
+func decodeMetadata(data []byte) (MediaMetadata, error) {
+	header := &struct {
+		MetadataType MediaType `json:"metadataType"`
+	}{}
+	if err := json.Unmarshal(data, header); err != nil {
+		return nil, err
+	}
+
+	switch header.MetadataType {
+	case MediaTypeGeneric:
+		var m GenericMediaMetadata
+		err := decodeMetadataFields(data, &m, &m.Extra)
+		return m, err
+	case MediaTypeMovie:
+		var m MovieMediaMetadata
+		err := decodeMetadataFields(data, &m, &m.Extra)
+		return m, err
+	case MediaTypeTVShow:
+		var m TvShowMediaMetadata
+		err := decodeMetadataFields(data, &m, &m.Extra)
+		return m, err
+	case MediaTypeMusicTrack:
+		var m MusicTrackMediaMetadata
+		err := decodeMetadataFields(data, &m, &m.Extra)
+		return m, err
+	case MediaTypePhoto:
+		var m PhotoMediaMetadata
+		err := decodeMetadataFields(data, &m, &m.Extra)
+		return m, err
+	}
+
+	m := RawMediaMetadata{Type: header.MetadataType}
+	if err := json.Unmarshal(data, &m.Fields); err != nil {
+		return nil, err
+	}
+	delete(m.Fields, "metadataType")
+	return m, nil
+}
+
+// decodeMetadataFields decodes data into m, a pointer to a metadata struct,
+// and what m doesn't know into extra.
+func decodeMetadataFields(data []byte, m interface{}, extra *map[string]json.RawMessage) error {
+	if err := json.Unmarshal(data, m); err != nil {
+		return err
+	}
+	known := jsonFieldNames(reflect.TypeOf(m).Elem())
+	known["metadataType"] = true
+	fields, err := extraFields(data, known)
+	*extra = fields
+	return err
+}
+
+// mediaInfoFields has the fields of MediaInfo but none of its methods.
+type mediaInfoFields MediaInfo
+
+var knownMediaInfoFields = jsonFieldNames(reflect.TypeOf(MediaInfo{}))
+
+func (m *MediaInfo) UnmarshalJSON(data []byte) error {
+	aux := &struct {
+		*mediaInfoFields
+		Metadata json.RawMessage `json:"metadata"`
+	}{
+		mediaInfoFields: (*mediaInfoFields)(m),
+	}
+	// A missing duration is an unknown one, not a zero one.
+	m.StreamDuration = UnknownDuration
+	if err := json.Unmarshal(data, aux); err != nil {
+		return err
+	}
+
+	m.Metadata = nil
+	if len(aux.Metadata) > 0 && string(aux.Metadata) != "null" {
+		metadata, err := decodeMetadata(aux.Metadata)
+		if err != nil {
+			return err
+		}
+		m.Metadata = metadata
+	}
+
+	extra, err := extraFields(data, knownMediaInfoFields)
+	m.Extra = extra
+	return err
+}
+
+func (m MediaInfo) MarshalJSON() ([]byte, error) {
+	// Receivers take a zero duration for an empty stream, and callers that
+	// leave it unset mean they don't know it.
+	if m.StreamDuration == 0 {
+		m.StreamDuration = UnknownDuration
+	}
+	data, err := json.Marshal((*mediaInfoFields)(&m))
+	if err != nil {
+		return nil, err
+	}
+	return addExtraFields(data, m.Extra)
+}
@@ -0,0 +1,77 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeMetadata(t *testing.T) {
+	latitude, longitude := 48.8584, 2.2945
+	tests := []struct {
+		name string
+		json string
+		want MediaMetadata
+	}{
+		{"generic", `{"metadataType":0,"title":"Clip","subtitle":"Sub","images":[{"url":"http://example.com/a.jpg","width":320}],"releaseDate":"2020-01-02"}`,
+			GenericMediaMetadata{Title: "Clip", Subtitle: "Sub", Images: []Image{{URL: "http://example.com/a.jpg", Width: 320}}, ReleaseDate: "2020-01-02"}},
+		{"no type", `{"title":"Clip"}`, GenericMediaMetadata{Title: "Clip"}},
+		{"movie", `{"metadataType":1,"title":"Movie","studio":"Studio"}`,
+			MovieMediaMetadata{Title: "Movie", Studio: "Studio"}},
+		{"tv show", `{"metadataType":2,"seriesTitle":"Show","title":"Pilot","season":1,"episode":2,"originalAirdate":"2001-02-03"}`,
+			TvShowMediaMetadata{SeriesTitle: "Show", Title: "Pilot", Season: 1, Episode: 2, OriginalAirDate: "2001-02-03"}},
+		{"music track", `{"metadataType":3,"title":"Song","albumName":"Album","albumArtist":"Band","artist":"Singer","composer":"Writer","trackNumber":4,"discNumber":1}`,
+			MusicTrackMediaMetadata{Title: "Song", AlbumName: "Album", AlbumArtist: "Band", Artist: "Singer", Composer: "Writer", TrackNumber: 4, DiscNumber: 1}},
+		{"photo", `{"metadataType":4,"title":"Tower","location":"Paris","latitude":48.8584,"longitude":2.2945,"width":4000,"height":3000,"creationDateTime":"2020-01-02T03:04:05Z"}`,
+			PhotoMediaMetadata{Title: "Tower", Location: "Paris", Latitude: &latitude, Longitude: &longitude, Width: 4000, Height: 3000, CreationDateTime: "2020-01-02T03:04:05Z"}},
+		{"user", `{"metadataType":100,"title":"Mine","custom":{"a":1}}`,
+			RawMediaMetadata{Type: MediaTypeUser, Fields: map[string]interface{}{"title": "Mine", "custom": map[string]interface{}{"a": float64(1)}}}},
+		{"unknown", `{"metadataType":7}`, RawMediaMetadata{Type: 7, Fields: map[string]interface{}{}}},
+	}
+	for _, test := range tests {
+		m, err := decodeMetadata([]byte(test.json))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(m, test.want) {
+			t.Errorf("%s: got %#v, want %#v", test.name, m, test.want)
+		}
+
+		// Encoding it gives back the same metadata.
+		data, err := json.Marshal(m)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		again, err := decodeMetadata(data)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if !reflect.DeepEqual(again, m) {
+			t.Errorf("%s: encoded as %s, which decodes as %#v", test.name, data, again)
+		}
+	}
+
+	for _, bad := range []string{`[]`, `{"metadataType":"movie"}`, `{"metadataType":2,"season":"one"}`} {
+		if _, err := decodeMetadata([]byte(bad)); err == nil {
+			t.Errorf("decoded %s", bad)
+		}
+	}
+}
+
+func TestMediaInfoMetadata(t *testing.T) {
+	var info MediaInfo
+	err := json.Unmarshal([]byte(`{"contentId":"a.mp3","metadata":{"metadataType":3,"title":"Song"}}`), &info)
+	if err != nil {
+		t.Fatal(err)
+	} else if m, ok := info.Metadata.(MusicTrackMediaMetadata); !ok || m.Title != "Song" {
+		t.Errorf("decoded %#v", info.Metadata)
+	}
+
+	info.Metadata = MovieMediaMetadata{}
+	if err := json.Unmarshal([]byte(`{"contentId":"a.mp3","metadata":null}`), &info); err != nil {
+		t.Fatal(err)
+	} else if info.Metadata != nil {
+		t.Errorf("kept %#v", info.Metadata)
+	}
+}
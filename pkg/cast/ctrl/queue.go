@@ -0,0 +1,204 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"golang.org/x/net/context"
+)
+
+type RepeatMode string
+
+const (
+	RepeatOff           RepeatMode = "REPEAT_OFF"
+	RepeatAll           RepeatMode = "REPEAT_ALL"
+	RepeatSingle        RepeatMode = "REPEAT_SINGLE"
+	RepeatAllAndShuffle RepeatMode = "REPEAT_ALL_AND_SHUFFLE"
+)
+
+// QueueItem is an entry of the media queue. ItemID is assigned by the
+// receiver, leave it empty when adding items.
+type QueueItem struct {
+	ItemID int        `json:"itemId,omitempty"`
+	Media  *MediaInfo `json:"media,omitempty"`
+	// AutoPlay tells the receiver to start playing the item as soon as the
+	// previous one ends. The receiver stops at items without it.
+	AutoPlay bool `json:"autoplay"`
+	// PreloadTime is how long before the end of the previous item the
+	// receiver should start loading this one.
+	PreloadTime Duration `json:"preloadTime,omitempty"`
+	// StartTime is where playback of this item starts.
+	StartTime      Duration    `json:"startTime,omitempty"`
+	ActiveTrackIDs []int64     `json:"activeTrackIds,omitempty"`
+	CustomData     interface{} `json:"customData,omitempty"`
+
+	// Extra holds the fields we don't know about, as in MediaStatus.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// queueItemFields has the fields of QueueItem but none of its methods.
+type queueItemFields QueueItem
+
+var knownQueueItemFields = jsonFieldNames(reflect.TypeOf(QueueItem{}))
+
+func (i *QueueItem) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*queueItemFields)(i)); err != nil {
+		return err
+	}
+	extra, err := extraFields(data, knownQueueItemFields)
+	i.Extra = extra
+	return err
+}
+
+func (i QueueItem) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(queueItemFields(i))
+	if err != nil {
+		return nil, err
+	}
+	return addExtraFields(data, i.Extra)
+}
+
+// NewQueueItem returns an item for media that plays automatically.
+func NewQueueItem(media MediaInfo) QueueItem {
+	return QueueItem{
+		Media:    &media,
+		AutoPlay: true,
+	}
+}
+
+type QueueLoadOptions struct {
+	// StartIndex is the index in the loaded items of the first one to play.
+	StartIndex int        `json:"startIndex"`
+	RepeatMode RepeatMode `json:"repeatMode,omitempty"`
+	// PlayPosition is where playback of the first item starts.
+	PlayPosition Duration    `json:"currentTime,omitempty"`
+	CustomData   interface{} `json:"customData,omitempty"`
+}
+
+// QueueUpdateOptions describes a QUEUE_UPDATE request. Zero fields are left
+// unchanged.
+type QueueUpdateOptions struct {
+	// Items are updated in place, matching them by ItemID.
+	Items []QueueItem `json:"items,omitempty"`
+	// CurrentItemID makes that item the current one.
+	CurrentItemID int `json:"currentItemId,omitempty"`
+	// Jump moves the current item that many positions forward (or backward,
+	// if negative) in the queue.
+	Jump       int        `json:"jump,omitempty"`
+	RepeatMode RepeatMode `json:"repeatMode,omitempty"`
+	Shuffle    bool       `json:"shuffle,omitempty"`
+	// PlayPosition is where playback of the current item continues.
+	PlayPosition Duration    `json:"currentTime,omitempty"`
+	CustomData   interface{} `json:"customData,omitempty"`
+}
+
+// checkItemTracks makes sure the active tracks of each item are tracks of
+// its media.
+func checkItemTracks(items []QueueItem) error {
+	for _, item := range items {
+		if item.Media == nil {
+			continue
+		}
+		if err := checkActiveTracks(item.Media.MediaTracks, item.ActiveTrackIDs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueueLoad replaces whatever is playing with a queue of items.
+func (r *MediaController) QueueLoad(ctx context.Context, items []QueueItem, options QueueLoadOptions) ([]MediaStatus, error) {
+	if err := checkItemTracks(items); err != nil {
+		return nil, err
+	}
+
+	request := &struct {
+		QueueLoadOptions
+		RequestHeader
+		Items []QueueItem `json:"items"`
+	}{
+		RequestHeader: RequestHeader{
+			PayloadHeaders: PayloadHeaders{Type: "QUEUE_LOAD"},
+		},
+		QueueLoadOptions: options,
+		Items:            items,
+	}
+	return r.requestStatus(ctx, request)
+}
+
+// QueueInsert inserts items before the item insertBefore, or at the end of
+// the queue if insertBefore is zero.
+func (r *MediaController) QueueInsert(ctx context.Context, sessionId int, items []QueueItem, insertBefore int) ([]MediaStatus, error) {
+	if err := checkItemTracks(items); err != nil {
+		return nil, err
+	}
+
+	request := &struct {
+		sessionRequest
+		Items        []QueueItem `json:"items"`
+		InsertBefore int         `json:"insertBefore,omitempty"`
+	}{
+		sessionRequest: newSessionRequest(sessionId, "QUEUE_INSERT"),
+		Items:          items,
+		InsertBefore:   insertBefore,
+	}
+	return r.requestStatus(ctx, request)
+}
+
+func (r *MediaController) QueueRemove(ctx context.Context, sessionId int, itemIds ...int) ([]MediaStatus, error) {
+	request := &struct {
+		sessionRequest
+		ItemIDs []int `json:"itemIds"`
+	}{
+		sessionRequest: newSessionRequest(sessionId, "QUEUE_REMOVE"),
+		ItemIDs:        itemIds,
+	}
+	return r.requestStatus(ctx, request)
+}
+
+// QueueReorder moves the given items, in that order, before the item
+// insertBefore, or to the end of the queue if insertBefore is zero.
+func (r *MediaController) QueueReorder(ctx context.Context, sessionId int, itemIds []int, insertBefore int) ([]MediaStatus, error) {
+	request := &struct {
+		sessionRequest
+		ItemIDs      []int `json:"itemIds"`
+		InsertBefore int   `json:"insertBefore,omitempty"`
+	}{
+		sessionRequest: newSessionRequest(sessionId, "QUEUE_REORDER"),
+		ItemIDs:        itemIds,
+		InsertBefore:   insertBefore,
+	}
+	return r.requestStatus(ctx, request)
+}
+
+func (r *MediaController) QueueUpdate(ctx context.Context, sessionId int, options QueueUpdateOptions) ([]MediaStatus, error) {
+	request := &struct {
+		QueueUpdateOptions
+		sessionRequest
+	}{
+		sessionRequest:     newSessionRequest(sessionId, "QUEUE_UPDATE"),
+		QueueUpdateOptions: options,
+	}
+	return r.requestStatus(ctx, request)
+}
+
+func (r *MediaController) QueueNext(ctx context.Context, sessionId int) ([]MediaStatus, error) {
+	return r.QueueUpdate(ctx, sessionId, QueueUpdateOptions{Jump: 1})
+}
+
+func (r *MediaController) QueuePrev(ctx context.Context, sessionId int) ([]MediaStatus, error) {
+	return r.QueueUpdate(ctx, sessionId, QueueUpdateOptions{Jump: -1})
+}
+
+// QueueJump makes itemId the current item.
+func (r *MediaController) QueueJump(ctx context.Context, sessionId int, itemId int) ([]MediaStatus, error) {
+	return r.QueueUpdate(ctx, sessionId, QueueUpdateOptions{CurrentItemID: itemId})
+}
+
+func (r *MediaController) QueueShuffle(ctx context.Context, sessionId int) ([]MediaStatus, error) {
+	return r.QueueUpdate(ctx, sessionId, QueueUpdateOptions{Shuffle: true})
+}
+
+func (r *MediaController) QueueSetRepeatMode(ctx context.Context, sessionId int, mode RepeatMode) ([]MediaStatus, error) {
+	return r.QueueUpdate(ctx, sessionId, QueueUpdateOptions{RepeatMode: mode})
+}
@@ -0,0 +1,129 @@
+package ctrl
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/ravishi/go-cast/pkg/cast/castest"
+)
+
+func testItems(n int) []QueueItem {
+	items := make([]QueueItem, n)
+	for i := range items {
+		items[i] = NewQueueItem(MediaInfo{
+			ContentID:   fmt.Sprintf("http://example.com/%d.mp3", i+1),
+			ContentType: "audio/mpeg",
+			StreamType:  StreamTypeBuffered,
+		})
+	}
+	return items
+}
+
+func itemIDs(s MediaStatus) []int {
+	ids := make([]int, len(s.Items))
+	for i, item := range s.Items {
+		ids[i] = item.ItemID
+	}
+	return ids
+}
+
+func TestQueue(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	ctx := testContext(t)
+	media := launchMedia(t, ctx, connect(t, r))
+
+	statuses, err := media.QueueLoad(ctx, testItems(3), QueueLoadOptions{StartIndex: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionId := statuses[0].MediaSessionID
+
+	tests := []struct {
+		name    string
+		request func() ([]MediaStatus, error)
+		items   []int
+		current int
+	}{
+		{"insert", func() ([]MediaStatus, error) {
+			return media.QueueInsert(ctx, sessionId, testItems(1), 1)
+		}, []int{4, 1, 2, 3}, 2},
+		{"append", func() ([]MediaStatus, error) {
+			return media.QueueInsert(ctx, sessionId, testItems(1), 0)
+		}, []int{4, 1, 2, 3, 5}, 2},
+		{"next", func() ([]MediaStatus, error) { return media.QueueNext(ctx, sessionId) }, []int{4, 1, 2, 3, 5}, 3},
+		{"prev", func() ([]MediaStatus, error) { return media.QueuePrev(ctx, sessionId) }, []int{4, 1, 2, 3, 5}, 2},
+		{"jump", func() ([]MediaStatus, error) { return media.QueueJump(ctx, sessionId, 4) }, []int{4, 1, 2, 3, 5}, 4},
+		{"reorder", func() ([]MediaStatus, error) {
+			return media.QueueReorder(ctx, sessionId, []int{4}, 0)
+		}, []int{1, 2, 3, 5, 4}, 4},
+		{"reorder before", func() ([]MediaStatus, error) {
+			return media.QueueReorder(ctx, sessionId, []int{3, 2}, 1)
+		}, []int{3, 2, 1, 5, 4}, 4},
+		{"remove", func() ([]MediaStatus, error) { return media.QueueRemove(ctx, sessionId, 1, 5) }, []int{3, 2, 4}, 4},
+		{"repeat", func() ([]MediaStatus, error) { return media.QueueSetRepeatMode(ctx, sessionId, RepeatAll) }, []int{3, 2, 4}, 4},
+		{"wrap", func() ([]MediaStatus, error) { return media.QueueNext(ctx, sessionId) }, []int{3, 2, 4}, 3},
+	}
+	for _, test := range tests {
+		statuses, err := test.request()
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		s := statuses[0]
+		if ids := itemIDs(s); !reflect.DeepEqual(ids, test.items) || s.CurrentItemID != test.current {
+			t.Errorf("%s: got %v playing %d, want %v playing %d", test.name, ids, s.CurrentItemID, test.items, test.current)
+		}
+	}
+
+	statuses, err = media.QueueShuffle(ctx, sessionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := itemIDs(statuses[0])
+	sort.Ints(ids)
+	if !reflect.DeepEqual(ids, []int{2, 3, 4}) || statuses[0].CurrentItemID != 3 {
+		t.Errorf("shuffled into %v playing %d", itemIDs(statuses[0]), statuses[0].CurrentItemID)
+	}
+	if statuses[0].RepeatMode != RepeatAll {
+		t.Errorf("repeat mode is %s", statuses[0].RepeatMode)
+	}
+}
+
+func TestQueueEnds(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	ctx := testContext(t)
+	media := launchMedia(t, ctx, connect(t, r))
+
+	statuses, err := media.QueueLoad(ctx, testItems(2), QueueLoadOptions{StartIndex: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses, err = media.QueueNext(ctx, statuses[0].MediaSessionID)
+	if err != nil {
+		t.Fatal(err)
+	} else if s := statuses[0]; s.PlayerState != PlayerStateIdle || s.IdleReason != IdleReasonFinished {
+		t.Errorf("went past the end, and got %s (%s)", s.PlayerState, s.IdleReason)
+	}
+	if id := media.SessionID(); id != 0 {
+		t.Errorf("still following session %d after the queue ended", id)
+	}
+}
+
+func TestQueueChecksTracks(t *testing.T) {
+	media := &MediaController{}
+	items := testItems(1)
+	items[0].Media.MediaTracks = []MediaTrack{NewTextTrack(1, "http://example.com/1.vtt", "en", "English")}
+	items[0].ActiveTrackIDs = []int64{2}
+
+	_, err := media.QueueLoad(testContext(t), items, QueueLoadOptions{})
+	if e, ok := err.(*UnknownTrackError); !ok || e.TrackID != 2 {
+		t.Errorf("loaded an unknown track: got %v", err)
+	}
+	_, err = media.QueueInsert(testContext(t), 1, items, 0)
+	if _, ok := err.(*UnknownTrackError); !ok {
+		t.Errorf("inserted an unknown track: got %v", err)
+	}
+}
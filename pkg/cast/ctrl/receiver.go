@@ -1,7 +1,7 @@
 package ctrl
 
 import (
-	"fmt"
+	"time"
 
 	"github.com/ravishi/go-cast/pkg/cast"
 	"golang.org/x/net/context"
@@ -12,10 +12,11 @@ const (
 )
 
 type ReceiverController struct {
-	rm    *requestManager
-	ch    *cast.Channel
-	ctx   context.Context
-	close context.CancelFunc
+	rm       *requestManager
+	ch       *cast.Channel
+	ctx      context.Context
+	close    context.CancelFunc
+	watchers receiverWatchers
 }
 
 type ReceiverStatus struct {
@@ -24,17 +25,18 @@ type ReceiverStatus struct {
 }
 
 type Volume struct {
-	Level float64 `json:"level,omitempty"`
-	Muted bool    `json:"muted,omitempty"`
+	Level float64 `json:"level"`
+	Muted bool    `json:"muted"`
 }
 
 type ApplicationSession struct {
-	AppID       string      `json:"appId,omitempty"`
-	DisplayName string      `json:"displayName,omitempty"`
-	Namespaces  []Namespace `json:"namespaces"`
-	SessionID   string      `json:"sessionId,omitempty"`
-	StatusText  string      `json:"statusText,omitempty"`
-	TransportId string      `json:"transportId,omitempty"`
+	AppID        string      `json:"appId,omitempty"`
+	DisplayName  string      `json:"displayName,omitempty"`
+	IsIdleScreen bool        `json:"isIdleScreen,omitempty"`
+	Namespaces   []Namespace `json:"namespaces"`
+	SessionID    string      `json:"sessionId,omitempty"`
+	StatusText   string      `json:"statusText,omitempty"`
+	TransportId  string      `json:"transportId,omitempty"`
 }
 
 type Namespace struct {
@@ -42,28 +44,53 @@ type Namespace struct {
 }
 
 func NewReceiverController(device *cast.Device, sourceId, destinationId string) *ReceiverController {
-	ctx, close := context.WithCancel(context.Background())
+	ctx, close := context.WithCancel(device.Context())
 	ch := device.NewChannel(ReceiverNamespace, sourceId, destinationId)
-	return &ReceiverController{
+	r := &ReceiverController{
 		ch:    ch,
 		ctx:   ctx,
 		close: close,
-		rm:    newRequestManager(ch),
+		rm:    newRequestManager(device, ch),
+		watchers: receiverWatchers{
+			subs: make(map[chan ReceiverEvent]struct{}),
+		},
 	}
+
+	go r.watchForever()
+
+	return r
+}
+
+// SetRequestTimeout changes how long requests wait for a response, unless
+// their context expires earlier. It defaults to DefaultRequestTimeout, and
+// zero disables it.
+func (r *ReceiverController) SetRequestTimeout(timeout time.Duration) {
+	r.rm.SetTimeout(timeout)
 }
 
-func (r *ReceiverController) GetStatus() (*ReceiverStatus, error) {
-	return r.requestStatus(&RequestHeader{
+func (r *ReceiverController) GetStatus(ctx context.Context) (*ReceiverStatus, error) {
+	return r.requestStatus(ctx, &RequestHeader{
 		PayloadHeaders: PayloadHeaders{
 			Type: "GET_STATUS",
 		},
 	})
 }
 
-func newSetVolumeRequest(volume *Volume) Request {
+// The receiver leaves out of a volume change whatever isn't in the request,
+// so the level and the mute state are set separately, and can't be omitted
+// when they are zero.
+type volumeLevel struct {
+	Level float64 `json:"level"`
+}
+
+type volumeMuted struct {
+	Muted bool `json:"muted"`
+}
+
+func newSetVolumeRequest(volume interface{}) Request {
 	return &struct {
 		RequestHeader
-		Volume *Volume `json:"volume"`
+		Volume interface{} `json:"volume"`
 	}{
 		RequestHeader: RequestHeader{
 			PayloadHeaders: PayloadHeaders{
@@ -74,23 +101,33 @@ func newSetVolumeRequest(volume *Volume) Request {
 	}
 }
 
-func (r *ReceiverController) SetVolume(level float64) (*ReceiverStatus, error) {
-	request := newSetVolumeRequest(&Volume{
+func (r *ReceiverController) SetVolume(ctx context.Context, level float64) (*ReceiverStatus, error) {
+	request := newSetVolumeRequest(&volumeLevel{
 		Level: level,
 	})
 
-	return r.requestStatus(request)
+	return r.requestStatus(ctx, request)
 }
 
-func (r *ReceiverController) SetMuted(muted bool) (*ReceiverStatus, error) {
-	request := newSetVolumeRequest(&Volume{
+func (r *ReceiverController) SetMuted(ctx context.Context, muted bool) (*ReceiverStatus, error) {
+	request := newSetVolumeRequest(&volumeMuted{
 		Muted: muted,
 	})
 
-	return r.requestStatus(request)
+	return r.requestStatus(ctx, request)
+}
+
+// LaunchError is returned when the device answers a launch with
+// LAUNCH_ERROR. Reason is the one given by the device, such as NOT_FOUND.
+type LaunchError struct {
+	Reason string `json:"reason"`
+}
+
+func (e *LaunchError) Error() string {
+	return "Launch error: " + e.Reason
 }
 
-func (r *ReceiverController) Launch(appId string) (*ReceiverStatus, error) {
+func (r *ReceiverController) Launch(ctx context.Context, appId string) (*ReceiverStatus, error) {
 	request := &struct {
 		RequestHeader
 		AppID string `json:"appId"`
@@ -101,7 +138,7 @@ func (r *ReceiverController) Launch(appId string) (*ReceiverStatus, error) {
 		AppID: appId,
 	}
 
-	response, err := r.rm.Request(request)
+	response, err := r.rm.Request(ctx, request)
 	if err != nil {
 		return nil, err
 	}
@@ -113,13 +150,11 @@ func (r *ReceiverController) Launch(appId string) (*ReceiverStatus, error) {
 	}
 
 	if responseHeader.Type == "LAUNCH_ERROR" {
-		errorReponse := &struct {
-			RequestHeader
-			Reason interface{}
-		}{}
-		err = response.Unmarshal(errorReponse)
-		err = fmt.Errorf("Launch error: %s", errorReponse.Reason)
-		return nil, err
+		launchError := &LaunchError{}
+		if err := response.Unmarshal(launchError); err != nil {
+			return nil, err
+		}
+		return nil, launchError
 	}
 
 	statusResponse := &statusResponse{}
@@ -128,13 +163,15 @@ func (r *ReceiverController) Launch(appId string) (*ReceiverStatus, error) {
 		return nil, err
 	}
 
+	r.watchers.publish(statusResponse.Status)
+
 	return statusResponse.Status, nil
 }
 
-func (r *ReceiverController) Stop(sessionId string) (*ReceiverStatus, error) {
+func (r *ReceiverController) Stop(ctx context.Context, sessionId string) (*ReceiverStatus, error) {
 	request := &struct {
 		RequestHeader
-		SessionID string `json:"sesssionId"`
+		SessionID string `json:"sessionId"`
 	}{
 		RequestHeader: RequestHeader{
 			PayloadHeaders: PayloadHeaders{Type: "STOP"},
@@ -142,12 +179,12 @@ func (r *ReceiverController) Stop(sessionId string) (*ReceiverStatus, error) {
 		SessionID: sessionId,
 	}
 
-	return r.requestStatus(request)
+	return r.requestStatus(ctx, request)
 }
 
 func (r *ReceiverController) Close() {
-	r.rm.Close()
 	r.close()
+	r.ch.Close()
 }
 
 type statusResponse struct {
@@ -155,8 +192,8 @@ type statusResponse struct {
 	Status *ReceiverStatus `json:"status,omitempty"`
 }
 
-func (r *ReceiverController) requestStatus(request Request) (*ReceiverStatus, error) {
-	response, err := r.rm.Request(request)
+func (r *ReceiverController) requestStatus(ctx context.Context, request Request) (*ReceiverStatus, error) {
+	response, err := r.rm.Request(ctx, request)
 	if err != nil {
 		return nil, err
 	}
@@ -167,5 +204,7 @@ func (r *ReceiverController) requestStatus(request Request) (*ReceiverStatus, er
 		return nil, err
 	}
 
+	r.watchers.publish(statusResponse.Status)
+
 	return statusResponse.Status, nil
 }
@@ -0,0 +1,223 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+type ReceiverEventType int
+
+const (
+	// AppLaunched means an app that wasn't running before is running now.
+	AppLaunched ReceiverEventType = iota
+	// AppStopped means an app that was running is gone.
+	AppStopped
+	// SessionChanged means an app is still running but under a new session,
+	// usually because another sender relaunched it.
+	SessionChanged
+	// VolumeChanged means the device volume level or mute state changed.
+	VolumeChanged
+	// IdleScreen means the device went back to its idle screen (backdrop).
+	IdleScreen
+)
+
+func (t ReceiverEventType) String() string {
+	switch t {
+	case AppLaunched:
+		return "APP_LAUNCHED"
+	case AppStopped:
+		return "APP_STOPPED"
+	case SessionChanged:
+		return "SESSION_CHANGED"
+	case VolumeChanged:
+		return "VOLUME_CHANGED"
+	case IdleScreen:
+		return "IDLE_SCREEN"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// ReceiverEvent describes one change between two receiver statuses.
+type ReceiverEvent struct {
+	Type ReceiverEventType
+	// App is the app the event is about. For SessionChanged it holds the
+	// new session, and PreviousSessionID the old one.
+	App               *ApplicationSession
+	PreviousSessionID string
+	// Volume is the new volume, for VolumeChanged.
+	Volume *Volume
+	// Status is the whole receiver status after the change.
+	Status   *ReceiverStatus
+	Received time.Time
+}
+
+type receiverWatchers struct {
+	mu     sync.Mutex
+	subs   map[chan ReceiverEvent]struct{}
+	last   *ReceiverStatus
+	closed bool
+}
+
+// Watch returns a channel of the changes in the receiver status, whoever
+// caused them, until ctx is done or the controller is closed. If a status is
+// already known, the channel starts with the events describing it (the
+// running apps and the volume). A reader that falls behind loses the oldest
+// changes, but the Status of every event it gets is complete.
+func (r *ReceiverController) Watch(ctx context.Context) <-chan ReceiverEvent {
+	ch := make(chan ReceiverEvent, watchQueueSize)
+
+	w := &r.watchers
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.closed {
+		close(ch)
+		return ch
+	}
+	w.subs[ch] = struct{}{}
+
+	if w.last != nil {
+		for _, e := range diffReceiverStatus(nil, w.last, time.Now()) {
+			offerReceiverEvent(ch, e)
+		}
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-r.ctx.Done():
+		}
+		w.remove(ch)
+	}()
+
+	return ch
+}
+
+func (w *receiverWatchers) remove(ch chan ReceiverEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if _, ok := w.subs[ch]; ok {
+		delete(w.subs, ch)
+		close(ch)
+	}
+}
+
+func (w *receiverWatchers) publish(status *ReceiverStatus) {
+	if status == nil {
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	events := diffReceiverStatus(w.last, status, time.Now())
+	w.last = status
+
+	for _, e := range events {
+		for ch := range w.subs {
+			offerReceiverEvent(ch, e)
+		}
+	}
+}
+
+func (w *receiverWatchers) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	for ch := range w.subs {
+		delete(w.subs, ch)
+		close(ch)
+	}
+}
+
+// offerReceiverEvent queues e for a Watch reader without blocking the
+// status updates.
+func offerReceiverEvent(ch chan ReceiverEvent, e ReceiverEvent) {
+	dropOldest(func() bool {
+		select {
+		case ch <- e:
+			return true
+		default:
+			return false
+		}
+	}, func() {
+		select {
+		case <-ch:
+		default:
+		}
+	})
+}
+
+// diffReceiverStatus lists the events that lead from prev to next. A nil
+// prev is treated as a device with nothing running and an unknown volume.
+func diffReceiverStatus(prev, next *ReceiverStatus, received time.Time) []ReceiverEvent {
+	if prev == nil {
+		prev = &ReceiverStatus{}
+	}
+
+	var events []ReceiverEvent
+	event := func(typ ReceiverEventType) *ReceiverEvent {
+		events = append(events, ReceiverEvent{Type: typ, Status: next, Received: received})
+		return &events[len(events)-1]
+	}
+
+	before := make(map[string]*ApplicationSession, len(prev.Applications))
+	for i := range prev.Applications {
+		before[prev.Applications[i].AppID] = &prev.Applications[i]
+	}
+
+	after := make(map[string]bool, len(next.Applications))
+	for i := range next.Applications {
+		app := &next.Applications[i]
+		after[app.AppID] = true
+
+		old, ok := before[app.AppID]
+		switch {
+		case !ok && app.IsIdleScreen:
+			event(IdleScreen).App = app
+		case !ok:
+			event(AppLaunched).App = app
+		case old.SessionID != app.SessionID:
+			e := event(SessionChanged)
+			e.App = app
+			e.PreviousSessionID = old.SessionID
+		}
+	}
+
+	for i := range prev.Applications {
+		app := &prev.Applications[i]
+		if !after[app.AppID] && !app.IsIdleScreen {
+			event(AppStopped).App = app
+		}
+	}
+
+	if next.Volume != nil && (prev.Volume == nil || *prev.Volume != *next.Volume) {
+		event(VolumeChanged).Volume = next.Volume
+	}
+
+	return events
+}
+
+// watchForever publishes the statuses the device sends without being asked
+// until the channel is closed.
+func (r *ReceiverController) watchForever() {
+	defer r.watchers.close()
+
+	for message := range r.ch.Read() {
+		response := &statusResponse{}
+		err := json.Unmarshal([]byte(message.GetPayloadUtf8()), response)
+		if err != nil {
+			log.Println("Error while unmarshaling receiver status:", err)
+			continue
+		}
+
+		if response.Type == "RECEIVER_STATUS" {
+			r.watchers.publish(response.Status)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package ctrl
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast/castest"
+)
+
+var (
+	backdrop = ApplicationSession{AppID: castest.BackdropAppId, IsIdleScreen: true, SessionID: "b"}
+	movie    = ApplicationSession{AppID: castest.DefaultMediaReceiverAppId, SessionID: "1"}
+	relaunch = ApplicationSession{AppID: castest.DefaultMediaReceiverAppId, SessionID: "2"}
+)
+
+func TestDiffReceiverStatus(t *testing.T) {
+	loud, quiet := &Volume{Level: 1}, &Volume{Level: 0.5}
+	tests := []struct {
+		name       string
+		prev, next *ReceiverStatus
+		want       []ReceiverEventType
+	}{
+		{"first", nil, &ReceiverStatus{Applications: []ApplicationSession{backdrop}, Volume: loud},
+			[]ReceiverEventType{IdleScreen, VolumeChanged}},
+		{"unchanged", &ReceiverStatus{Applications: []ApplicationSession{movie}, Volume: loud},
+			&ReceiverStatus{Applications: []ApplicationSession{movie}, Volume: &Volume{Level: 1}}, nil},
+		{"launched", &ReceiverStatus{Applications: []ApplicationSession{backdrop}},
+			&ReceiverStatus{Applications: []ApplicationSession{movie}}, []ReceiverEventType{AppLaunched}},
+		{"stopped", &ReceiverStatus{Applications: []ApplicationSession{movie}},
+			&ReceiverStatus{Applications: []ApplicationSession{backdrop}}, []ReceiverEventType{IdleScreen, AppStopped}},
+		{"relaunched", &ReceiverStatus{Applications: []ApplicationSession{movie}},
+			&ReceiverStatus{Applications: []ApplicationSession{relaunch}}, []ReceiverEventType{SessionChanged}},
+		{"volume", &ReceiverStatus{Volume: loud}, &ReceiverStatus{Volume: quiet}, []ReceiverEventType{VolumeChanged}},
+		{"muted", &ReceiverStatus{Volume: loud}, &ReceiverStatus{Volume: &Volume{Level: 1, Muted: true}},
+			[]ReceiverEventType{VolumeChanged}},
+		{"volume unknown", &ReceiverStatus{Volume: loud}, &ReceiverStatus{}, nil},
+	}
+	for _, test := range tests {
+		events := diffReceiverStatus(test.prev, test.next, time.Now())
+		var types []ReceiverEventType
+		for _, e := range events {
+			types = append(types, e.Type)
+			if e.Status != test.next {
+				t.Errorf("%s: %s has another status", test.name, e.Type)
+			}
+		}
+		if !reflect.DeepEqual(types, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, types, test.want)
+		}
+	}
+
+	events := diffReceiverStatus(
+		&ReceiverStatus{Applications: []ApplicationSession{movie}},
+		&ReceiverStatus{Applications: []ApplicationSession{relaunch}},
+		time.Now())
+	if e := events[0]; e.App.SessionID != "2" || e.PreviousSessionID != "1" {
+		t.Errorf("changed from %s to %s", e.PreviousSessionID, e.App.SessionID)
+	}
+}
+
+// TestReceiverWatch makes changes on the device, as another sender would,
+// and watches them.
+func TestReceiverWatch(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	ctx := testContext(t)
+	receiver := newTestReceiver(t, connect(t, r))
+
+	if _, err := receiver.GetStatus(ctx); err != nil {
+		t.Fatal(err)
+	}
+	events := receiver.Watch(ctx)
+
+	next := func(want ReceiverEventType) ReceiverEvent {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				t.Fatal("the watch stopped")
+			} else if e.Type != want {
+				t.Fatalf("got %s, want %s", e.Type, want)
+			}
+			return e
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no %s", want)
+		}
+		return ReceiverEvent{}
+	}
+
+	// The watch starts with what's known already.
+	if e := next(IdleScreen); e.App.AppID != castest.BackdropAppId {
+		t.Errorf("idle with %s", e.App.AppID)
+	}
+	next(VolumeChanged)
+
+	sessionId, err := r.LaunchApp(castest.DefaultMediaReceiverAppId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := next(AppLaunched); e.App.SessionID != sessionId {
+		t.Errorf("launched %s, want %s", e.App.SessionID, sessionId)
+	}
+
+	r.SetVolume(0.25, true)
+	if e := next(VolumeChanged); *e.Volume != (Volume{Level: 0.25, Muted: true}) {
+		t.Errorf("volume is %+v", *e.Volume)
+	}
+
+	r.StopApp(sessionId)
+	next(IdleScreen)
+	if e := next(AppStopped); e.App.SessionID != sessionId {
+		t.Errorf("stopped %s, want %s", e.App.SessionID, sessionId)
+	}
+}
+
+func TestOfferReceiverEvent(t *testing.T) {
+	ch := make(chan ReceiverEvent, 2)
+	for _, typ := range []ReceiverEventType{AppLaunched, VolumeChanged, AppStopped} {
+		offerReceiverEvent(ch, ReceiverEvent{Type: typ})
+	}
+	for _, want := range []ReceiverEventType{VolumeChanged, AppStopped} {
+		if e := <-ch; e.Type != want {
+			t.Errorf("got %s, want %s", e.Type, want)
+		}
+	}
+}
@@ -2,13 +2,20 @@ package ctrl
 
 import (
 	"encoding/json"
-	"fmt"
-	"sync/atomic"
+	"errors"
+	"sync"
+	"time"
 
 	"github.com/ravishi/go-cast/pkg/cast"
 	"golang.org/x/net/context"
 )
 
+// DefaultRequestTimeout is how long controllers wait for a response when the
+// context given to a request has no earlier deadline.
+const DefaultRequestTimeout = 10 * time.Second
+
+var ControllerClosed = errors.New("Controller closed")
+
 type Request interface {
 	setRequestId(int32)
 }
@@ -31,11 +38,11 @@ func (r *ResponseHeaders) Unmarshal(v interface{}) error {
 }
 
 type requestManager struct {
-	ch              *cast.Channel
-	ctx             context.Context
-	close           context.CancelFunc
-	requestId       int32
-	requestHandlers map[int32]chan<- *ResponseHeaders
+	ch     *cast.Channel
+	device *cast.Device
+
+	mu      sync.Mutex
+	timeout time.Duration
 }
 
 type RequestHeader struct {
@@ -49,118 +56,74 @@ func (h *RequestHeader) setRequestId(requestId int32) {
 	h.RequestId = requestId
 }
 
-func newRequestManager(ch *cast.Channel) *requestManager {
-	ctx, close := context.WithCancel(context.Background())
-	m := &requestManager{
-		ch:              ch,
-		ctx:             ctx,
-		close:           close,
-		requestId:       0,
-		requestHandlers: make(map[int32]chan<- *ResponseHeaders),
+func newRequestManager(device *cast.Device, ch *cast.Channel) *requestManager {
+	return &requestManager{
+		ch:      ch,
+		device:  device,
+		timeout: DefaultRequestTimeout,
 	}
+}
 
-	go m.handleForever()
-
-	return m
+// InvalidRequestError is returned when the device answers a request with
+// INVALID_REQUEST. Reason is the one given by the device, such as
+// INVALID_MEDIA_SESSION_ID or INVALID_COMMAND.
+type InvalidRequestError struct {
+	Reason string `json:"reason"`
 }
 
-func (m *requestManager) handleForever() {
-	for {
-		select {
-		case message, ok := <-m.ch.Read():
-			if !ok {
-				return
-			} else {
-				// TODO Notify errors.
-				rawMessage := &json.RawMessage{}
-				err := json.Unmarshal([]byte(*message.PayloadUtf8), rawMessage)
-				if err != nil {
-					continue
-				}
-
-				header := &RequestHeader{}
-				err = json.Unmarshal(*rawMessage, header)
-				if err != nil {
-					continue
-				}
-
-				ch := m.requestHandlers[header.RequestId]
-				if ch == nil {
-					continue
-				}
-
-				ch <- &ResponseHeaders{header: header, message: rawMessage}
-			}
-		case <-m.ctx.Done():
-			return
-		}
-	}
+func (e *InvalidRequestError) Error() string {
+	return e.Reason
 }
 
-func (m *requestManager) unregister(ch chan<- *ResponseHeaders) {
-	for i, c := range m.requestHandlers {
-		if ch == c {
-			delete(m.requestHandlers, i)
-			return
-		}
-	}
+func (m *requestManager) SetTimeout(timeout time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeout = timeout
 }
 
-func (m *requestManager) Send(payload Request, response chan<- *ResponseHeaders) error {
-	requestId := atomic.AddInt32(&m.requestId, 1)
+// Request sends payload and waits for its response. It gives up when ctx is
+// done, after the manager timeout, or as soon as the device or the
+// controller are closed, in which case it fails with cast.DeviceClosed or
+// ControllerClosed respectively.
+func (m *requestManager) Request(ctx context.Context, payload Request) (*ResponseHeaders, error) {
+	m.mu.Lock()
+	timeout := m.timeout
+	m.mu.Unlock()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
+	requestId := m.device.NextRequestId()
 	payload.setRequestId(requestId)
 
-	m.requestHandlers[requestId] = response
-
-	err := send(m.ch, payload)
+	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
-}
-
-type responseError struct {
-	ResponseHeaders
-	reason string `json:"reason"`
-}
-
-func (e *responseError) Error() string {
-	return e.reason
-}
-
-func (m *requestManager) Request(payload Request /*, timeout time.Duration */) (*ResponseHeaders, error) {
-	responseCh := make(chan *ResponseHeaders)
-	defer close(responseCh)
-
-	err := m.Send(payload, responseCh)
-	defer m.unregister(responseCh)
-	if err != nil {
+	message, err := m.ch.Request(ctx, requestId, string(jsonData))
+	if err == cast.ChannelClosed {
+		return nil, ControllerClosed
+	} else if err != nil {
 		return nil, err
 	}
 
-	select {
-	case <-m.ctx.Done():
-		return nil, m.ctx.Err()
-	case response, ok := <-responseCh:
-		if !ok {
-			return nil, fmt.Errorf("Response channel unexpectedly closed")
-		}
+	rawMessage := json.RawMessage(message.GetPayloadUtf8())
+	header := &RequestHeader{}
+	if err := json.Unmarshal(rawMessage, header); err != nil {
+		return nil, err
+	}
 
-		if response.Type() == "INVALID_REQUEST" {
-			responseErr := &responseError{}
-			if err := response.Unmarshal(responseErr); err != nil {
-				return nil, err
-			}
-			return nil, responseErr
+	response := &ResponseHeaders{header: header, message: &rawMessage}
+	if response.Type() == "INVALID_REQUEST" {
+		responseErr := &InvalidRequestError{}
+		if err := response.Unmarshal(responseErr); err != nil {
+			return nil, err
 		}
-
-		return response, nil
+		return nil, responseErr
 	}
 
-}
-
-func (m *requestManager) Close() {
-	m.close()
+	return response, nil
 }
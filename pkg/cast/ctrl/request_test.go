@@ -0,0 +1,156 @@
+package ctrl
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast"
+	"github.com/ravishi/go-cast/pkg/cast/castest"
+	"golang.org/x/net/context"
+)
+
+const testSenderId = "sender-0"
+
+func TestMain(m *testing.M) {
+	// Devices log every message.
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+// connect runs a device connected to r.
+func connect(t *testing.T, r *castest.Receiver) *cast.Device {
+	conn := r.Pipe()
+	device := cast.NewDevice(conn)
+	go device.Run()
+	t.Cleanup(func() {
+		device.Close()
+		conn.Close()
+	})
+
+	if err := NewConnectionController(device, testSenderId, castest.ReceiverId).Connect(); err != nil {
+		t.Fatal(err)
+	}
+	return device
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func newTestReceiver(t *testing.T, device *cast.Device) *ReceiverController {
+	receiver := NewReceiverController(device, testSenderId, castest.ReceiverId)
+	t.Cleanup(receiver.Close)
+	return receiver
+}
+
+// launchMedia launches the Default Media Receiver and returns a controller
+// for its media.
+func launchMedia(t *testing.T, ctx context.Context, device *cast.Device) *MediaController {
+	status, err := newTestReceiver(t, device).Launch(ctx, castest.DefaultMediaReceiverAppId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app := status.MediaApp(castest.DefaultMediaReceiverAppId)
+	if app == nil {
+		t.Fatalf("launched, but got %+v", status)
+	}
+	if err := NewConnectionController(device, testSenderId, app.TransportId).Connect(); err != nil {
+		t.Fatal(err)
+	}
+	media := NewMediaController(device, testSenderId, app.TransportId)
+	t.Cleanup(media.Close)
+	return media
+}
+
+// unanswered returns a media controller for an app that isn't there, whose
+// requests never get a response.
+func unanswered(t *testing.T, device *cast.Device) *MediaController {
+	media := NewMediaController(device, testSenderId, "nobody")
+	t.Cleanup(media.Close)
+	return media
+}
+
+func TestRequestTimeout(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	media := unanswered(t, connect(t, r))
+
+	media.SetRequestTimeout(20 * time.Millisecond)
+	if _, err := media.GetStatus(context.Background()); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The context may give up earlier.
+	media.SetRequestTimeout(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := media.GetStatus(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := media.GetStatus(ctx); err != context.Canceled {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestRequestClosed(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	device := connect(t, r)
+
+	tests := []struct {
+		name  string
+		close func(*MediaController)
+		err   error
+	}{
+		{"controller", (*MediaController).Close, ControllerClosed},
+		{"device", func(*MediaController) { device.Close() }, cast.DeviceClosed},
+	}
+	for _, test := range tests {
+		media := unanswered(t, device)
+		errs := make(chan error, 1)
+		go func() {
+			_, err := media.GetStatus(context.Background())
+			errs <- err
+		}()
+		time.Sleep(10 * time.Millisecond)
+		test.close(media)
+
+		select {
+		case err := <-errs:
+			if err != test.err {
+				t.Errorf("closing the %s: got %v, want %v", test.name, err, test.err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("closing the %s: the request never gave up", test.name)
+		}
+	}
+}
+
+func TestSetRequestTimeoutWhileRequesting(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	ctx := testContext(t)
+	receiver := newTestReceiver(t, connect(t, r))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			receiver.SetRequestTimeout(time.Duration(i+1) * time.Second)
+			if _, err := receiver.GetStatus(ctx); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
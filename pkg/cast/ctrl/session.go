@@ -0,0 +1,139 @@
+package ctrl
+
+import (
+	"errors"
+
+	"golang.org/x/net/context"
+)
+
+var (
+	AppNotRunning  = errors.New("App not running")
+	NoMediaSession = errors.New("No media session")
+)
+
+// HasNamespace tells whether the app speaks the namespace.
+func (a *ApplicationSession) HasNamespace(name string) bool {
+	for _, ns := range a.Namespaces {
+		if ns.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// MediaApp returns the running app appId if it plays media, or any app that
+// does if appId is empty, or nil if there's none.
+func (s *ReceiverStatus) MediaApp(appId string) *ApplicationSession {
+	for i := range s.Applications {
+		app := &s.Applications[i]
+		if (appId == "" || app.AppID == appId) && app.HasNamespace(MediaNamespace) {
+			return app
+		}
+	}
+	return nil
+}
+
+// MediaSession is the media of an app that runs on the device. Its methods
+// act on whichever media session plays, as media come and go, and fail with
+// NoMediaSession when none does. Media is there for everything else, such as
+// loading media.
+type MediaSession struct {
+	App        ApplicationSession
+	Media      *MediaController
+	connection *ConnectionController
+}
+
+// JoinMedia joins the media of the running app appId, or of any app that
+// plays media if appId is empty, instead of launching it again, which would
+// stop whatever plays. It fails with AppNotRunning if there's no such app.
+// The session is ready once it returns, bound to the media session that
+// plays, if any.
+func (r *ReceiverController) JoinMedia(ctx context.Context, sourceId, appId string) (*MediaSession, error) {
+	status, err := r.GetStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	app := status.MediaApp(appId)
+	if app == nil {
+		return nil, AppNotRunning
+	}
+
+	device := r.rm.device
+	s := &MediaSession{
+		App:        *app,
+		connection: NewConnectionController(device, sourceId, app.TransportId),
+	}
+	if err := s.connection.Connect(); err != nil {
+		s.connection.Close()
+		return nil, err
+	}
+	s.Media = NewMediaController(device, sourceId, app.TransportId)
+
+	if _, err := s.Media.GetStatus(ctx); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// SessionID returns the media session that plays, or 0 if there's none.
+func (s *MediaSession) SessionID() int {
+	return s.Media.SessionID()
+}
+
+// Status asks for the status of the media session that plays.
+func (s *MediaSession) Status(ctx context.Context) (*MediaStatus, error) {
+	statuses, err := s.Media.GetStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range statuses {
+		if statuses[i].MediaSessionID == s.SessionID() && statuses[i].MediaSessionID != 0 {
+			return &statuses[i], nil
+		}
+	}
+	return nil, NoMediaSession
+}
+
+func (s *MediaSession) Play(ctx context.Context) ([]MediaStatus, error) {
+	return s.request(ctx, s.Media.Play)
+}
+
+func (s *MediaSession) Pause(ctx context.Context) ([]MediaStatus, error) {
+	return s.request(ctx, s.Media.Pause)
+}
+
+func (s *MediaSession) Stop(ctx context.Context) ([]MediaStatus, error) {
+	return s.request(ctx, s.Media.Stop)
+}
+
+func (s *MediaSession) Seek(ctx context.Context, position Duration, resumeState ResumeState) ([]MediaStatus, error) {
+	return s.request(ctx, func(ctx context.Context, sessionId int) ([]MediaStatus, error) {
+		return s.Media.Seek(ctx, sessionId, position, resumeState)
+	})
+}
+
+func (s *MediaSession) SetVolume(ctx context.Context, level float64) ([]MediaStatus, error) {
+	return s.request(ctx, func(ctx context.Context, sessionId int) ([]MediaStatus, error) {
+		return s.Media.SetVolume(ctx, sessionId, level)
+	})
+}
+
+func (s *MediaSession) SetMuted(ctx context.Context, muted bool) ([]MediaStatus, error) {
+	return s.request(ctx, func(ctx context.Context, sessionId int) ([]MediaStatus, error) {
+		return s.Media.SetMuted(ctx, sessionId, muted)
+	})
+}
+
+func (s *MediaSession) request(ctx context.Context, request func(context.Context, int) ([]MediaStatus, error)) ([]MediaStatus, error) {
+	sessionId := s.SessionID()
+	if sessionId == 0 {
+		return nil, NoMediaSession
+	}
+	return request(ctx, sessionId)
+}
+
+func (s *MediaSession) Close() {
+	s.Media.Close()
+	s.connection.Close()
+}
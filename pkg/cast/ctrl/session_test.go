@@ -0,0 +1,110 @@
+package ctrl
+
+import (
+	"testing"
+
+	"github.com/ravishi/go-cast/pkg/cast/castest"
+)
+
+func TestMediaApp(t *testing.T) {
+	media := []Namespace{{Name: MediaNamespace}}
+	status := &ReceiverStatus{Applications: []ApplicationSession{
+		{AppID: "other"},
+		{AppID: "player", Namespaces: media},
+		{AppID: castest.DefaultMediaReceiverAppId, Namespaces: media},
+	}}
+	tests := []struct {
+		appId, want string
+	}{
+		{"", "player"},
+		{castest.DefaultMediaReceiverAppId, castest.DefaultMediaReceiverAppId},
+		{"other", ""},
+		{"missing", ""},
+	}
+	for _, test := range tests {
+		got := ""
+		if app := status.MediaApp(test.appId); app != nil {
+			got = app.AppID
+		}
+		if got != test.want {
+			t.Errorf("%q: got %q, want %q", test.appId, got, test.want)
+		}
+	}
+}
+
+func TestJoinMediaNotRunning(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	ctx := testContext(t)
+
+	_, err := newTestReceiver(t, connect(t, r)).JoinMedia(ctx, "sender-1", "")
+	if err != AppNotRunning {
+		t.Errorf("got %v, want %v", err, AppNotRunning)
+	}
+}
+
+// TestJoinMedia joins the media that another sender plays.
+func TestJoinMedia(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	ctx := testContext(t)
+	device := connect(t, r)
+	sessionId := loadMovie(t, ctx, launchMedia(t, ctx, device))
+
+	s, err := newTestReceiver(t, device).JoinMedia(ctx, "sender-1", castest.DefaultMediaReceiverAppId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if s.App.AppID != castest.DefaultMediaReceiverAppId {
+		t.Errorf("joined %s", s.App.AppID)
+	}
+	if id := s.SessionID(); id != sessionId {
+		t.Fatalf("joined session %d, want %d", id, sessionId)
+	}
+
+	tests := []struct {
+		name    string
+		request func() ([]MediaStatus, error)
+		check   func(s MediaStatus) bool
+	}{
+		{"pause", func() ([]MediaStatus, error) { return s.Pause(ctx) },
+			func(s MediaStatus) bool { return s.PlayerState == PlayerStatePaused }},
+		{"seek", func() ([]MediaStatus, error) { return s.Seek(ctx, Seconds(60), ResumeStateUnchanged) },
+			func(s MediaStatus) bool { return near(s.CurrentTime, Seconds(60)) }},
+		{"volume", func() ([]MediaStatus, error) { return s.SetVolume(ctx, 0.5) },
+			func(s MediaStatus) bool { return s.Volume != nil && s.Volume.Level == 0.5 }},
+		{"mute", func() ([]MediaStatus, error) { return s.SetMuted(ctx, true) },
+			func(s MediaStatus) bool { return s.Volume != nil && s.Volume.Muted }},
+		{"play", func() ([]MediaStatus, error) { return s.Play(ctx) },
+			func(s MediaStatus) bool { return s.PlayerState == PlayerStatePlaying }},
+	}
+	for _, test := range tests {
+		statuses, err := test.request()
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		} else if len(statuses) != 1 || !test.check(statuses[0]) {
+			t.Errorf("%s: got %+v", test.name, statuses)
+		}
+	}
+
+	status, err := s.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	} else if status.MediaSessionID != sessionId || status.PlayerState != PlayerStatePlaying {
+		t.Errorf("got session %d %s", status.MediaSessionID, status.PlayerState)
+	}
+
+	if _, err := s.Stop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if id := s.SessionID(); id != 0 {
+		t.Errorf("still following session %d after it stopped", id)
+	}
+	if _, err := s.Play(ctx); err != NoMediaSession {
+		t.Errorf("playing: got %v, want %v", err, NoMediaSession)
+	}
+	if _, err := s.Status(ctx); err != NoMediaSession {
+		t.Errorf("status: got %v, want %v", err, NoMediaSession)
+	}
+}
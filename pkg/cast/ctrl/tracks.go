@@ -0,0 +1,220 @@
+package ctrl
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"golang.org/x/net/context"
+)
+
+type TrackType string
+type TrackSubtype string
+
+const (
+	TrackTypeText  TrackType = "TEXT"
+	TrackTypeAudio TrackType = "AUDIO"
+	TrackTypeVideo TrackType = "VIDEO"
+
+	// Subtypes only apply to text tracks.
+	TrackSubtypeNone         TrackSubtype = ""
+	TrackSubtypeSubtitles    TrackSubtype = "SUBTITLES"
+	TrackSubtypeCaptions     TrackSubtype = "CAPTIONS"
+	TrackSubtypeDescriptions TrackSubtype = "DESCRIPTIONS"
+	TrackSubtypeChapters     TrackSubtype = "CHAPTERS"
+	TrackSubtypeMetadata     TrackSubtype = "METADATA"
+)
+
+// MediaTrack is a track of a media. Text tracks can be side-loaded by
+// setting ContentID to the URL of a WebVTT file.
+type MediaTrack struct {
+	ID          int64        `json:"trackId"`
+	Type        TrackType    `json:"type"`
+	Subtype     TrackSubtype `json:"subtype,omitempty"`
+	ContentID   string       `json:"trackContentId,omitempty"`
+	ContentType string       `json:"trackContentType,omitempty"`
+	// Language is an RFC 5646 tag, e.g. "en-US".
+	Language   string      `json:"language,omitempty"`
+	Name       string      `json:"name,omitempty"`
+	CustomData interface{} `json:"customData,omitempty"`
+
+	// Extra holds the fields we don't know about, as in MediaStatus.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// mediaTrackFields has the fields of MediaTrack but none of its methods.
+type mediaTrackFields MediaTrack
+
+var knownMediaTrackFields = jsonFieldNames(reflect.TypeOf(MediaTrack{}))
+
+func (t *MediaTrack) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*mediaTrackFields)(t)); err != nil {
+		return err
+	}
+	extra, err := extraFields(data, knownMediaTrackFields)
+	t.Extra = extra
+	return err
+}
+
+func (t MediaTrack) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(mediaTrackFields(t))
+	if err != nil {
+		return nil, err
+	}
+	return addExtraFields(data, t.Extra)
+}
+
+// NewTextTrack returns a subtitles track read from the WebVTT file at url.
+func NewTextTrack(id int64, url, language, name string) MediaTrack {
+	return MediaTrack{
+		ID:          id,
+		Type:        TrackTypeText,
+		Subtype:     TrackSubtypeSubtitles,
+		ContentID:   url,
+		ContentType: "text/vtt",
+		Language:    language,
+		Name:        name,
+	}
+}
+
+type EdgeType string
+
+const (
+	EdgeTypeNone       EdgeType = "NONE"
+	EdgeTypeOutline    EdgeType = "OUTLINE"
+	EdgeTypeDropShadow EdgeType = "DROP_SHADOW"
+	EdgeTypeRaised     EdgeType = "RAISED"
+	EdgeTypeDepressed  EdgeType = "DEPRESSED"
+)
+
+type FontFamily string
+
+const (
+	FontSansSerif           FontFamily = "SANS_SERIF"
+	FontMonospacedSansSerif FontFamily = "MONOSPACED_SANS_SERIF"
+	FontSerif               FontFamily = "SERIF"
+	FontMonospacedSerif     FontFamily = "MONOSPACED_SERIF"
+	FontCasual              FontFamily = "CASUAL"
+	FontCursive             FontFamily = "CURSIVE"
+	FontSmallCapitals       FontFamily = "SMALL_CAPITALS"
+)
+
+type FontStyle string
+
+const (
+	FontStyleNormal     FontStyle = "NORMAL"
+	FontStyleBold       FontStyle = "BOLD"
+	FontStyleItalic     FontStyle = "ITALIC"
+	FontStyleBoldItalic FontStyle = "BOLD_ITALIC"
+)
+
+type WindowType string
+
+const (
+	WindowTypeNone           WindowType = "NONE"
+	WindowTypeNormal         WindowType = "NORMAL"
+	WindowTypeRoundedCorners WindowType = "ROUNDED_CORNERS"
+)
+
+// Color is a color in the #RRGGBBAA form.
+type Color string
+
+// RGBA returns the Color with the given components.
+func RGBA(r, g, b, a uint8) Color {
+	return Color(fmt.Sprintf("#%02X%02X%02X%02X", r, g, b, a))
+}
+
+// TextTrackStyle tells the receiver how to render text tracks. Empty fields
+// are left to the receiver.
+type TextTrackStyle struct {
+	ForegroundColor Color    `json:"foregroundColor,omitempty"`
+	BackgroundColor Color    `json:"backgroundColor,omitempty"`
+	EdgeType        EdgeType `json:"edgeType,omitempty"`
+	EdgeColor       Color    `json:"edgeColor,omitempty"`
+	// FontFamily is the name of a font, used if the receiver has it, and
+	// FontGenericFamily the kind of font to use otherwise.
+	FontFamily        string     `json:"fontFamily,omitempty"`
+	FontGenericFamily FontFamily `json:"fontGenericFamily,omitempty"`
+	// FontScale scales the default font size, 1 keeps it unchanged.
+	FontScale                 float64     `json:"fontScale,omitempty"`
+	FontStyle                 FontStyle   `json:"fontStyle,omitempty"`
+	WindowType                WindowType  `json:"windowType,omitempty"`
+	WindowColor               Color       `json:"windowColor,omitempty"`
+	WindowRoundedCornerRadius int         `json:"windowRoundedCornerRadius,omitempty"`
+	CustomData                interface{} `json:"customData,omitempty"`
+}
+
+// UnknownTrackError is returned when enabling a track the media doesn't
+// have.
+type UnknownTrackError struct {
+	TrackID int64
+}
+
+func (e *UnknownTrackError) Error() string {
+	return fmt.Sprintf("Unknown track %d", e.TrackID)
+}
+
+// checkActiveTracks makes sure every track in active is one of tracks.
+func checkActiveTracks(tracks []MediaTrack, active []int64) error {
+	for _, id := range active {
+		found := false
+		for _, t := range tracks {
+			if t.ID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return &UnknownTrackError{TrackID: id}
+		}
+	}
+	return nil
+}
+
+type EditTracksOptions struct {
+	// ActiveTrackIDs replaces the enabled tracks. Leave it nil to keep them,
+	// or make it empty to disable every track.
+	ActiveTrackIDs []int64
+	// Language enables the text track in that language.
+	Language       string
+	TextTrackStyle *TextTrackStyle
+}
+
+// EditTracks changes which tracks are enabled and how text tracks look.
+// Track ids are checked against the media of the session, if the controller
+// has seen it.
+func (r *MediaController) EditTracks(ctx context.Context, sessionId int, options EditTracksOptions) ([]MediaStatus, error) {
+	if media := r.watchers.media(sessionId); media != nil {
+		if err := checkActiveTracks(media.MediaTracks, options.ActiveTrackIDs); err != nil {
+			return nil, err
+		}
+	}
+
+	request := &struct {
+		sessionRequest
+		// An interface, so that an empty list isn't omitted like a nil one.
+		ActiveTrackIDs interface{}     `json:"activeTrackIds,omitempty"`
+		Language       string          `json:"language,omitempty"`
+		TextTrackStyle *TextTrackStyle `json:"textTrackStyle,omitempty"`
+	}{
+		sessionRequest: newSessionRequest(sessionId, "EDIT_TRACKS_INFO"),
+		Language:       options.Language,
+		TextTrackStyle: options.TextTrackStyle,
+	}
+	if options.ActiveTrackIDs != nil {
+		request.ActiveTrackIDs = options.ActiveTrackIDs
+	}
+	return r.requestStatus(ctx, request)
+}
+
+// SetActiveTracks enables the given tracks and disables every other one.
+func (r *MediaController) SetActiveTracks(ctx context.Context, sessionId int, trackIds ...int64) ([]MediaStatus, error) {
+	if trackIds == nil {
+		trackIds = []int64{}
+	}
+	return r.EditTracks(ctx, sessionId, EditTracksOptions{ActiveTrackIDs: trackIds})
+}
+
+func (r *MediaController) SetTextTrackStyle(ctx context.Context, sessionId int, style TextTrackStyle) ([]MediaStatus, error) {
+	return r.EditTracks(ctx, sessionId, EditTracksOptions{TextTrackStyle: &style})
+}
@@ -0,0 +1,84 @@
+package ctrl
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ravishi/go-cast/pkg/cast/castest"
+)
+
+func TestRGBA(t *testing.T) {
+	if c := RGBA(255, 0, 10, 128); c != "#FF000A80" {
+		t.Errorf("got %s", c)
+	}
+}
+
+func TestCheckActiveTracks(t *testing.T) {
+	tracks := []MediaTrack{{ID: 1}, {ID: 3}}
+	if err := checkActiveTracks(tracks, []int64{3, 1}); err != nil {
+		t.Error(err)
+	}
+	if err := checkActiveTracks(tracks, nil); err != nil {
+		t.Error(err)
+	}
+	err := checkActiveTracks(tracks, []int64{1, 2})
+	if e, ok := err.(*UnknownTrackError); !ok || e.TrackID != 2 {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestEditTracks(t *testing.T) {
+	r := castest.NewReceiver()
+	defer r.Close()
+	ctx := testContext(t)
+	media := launchMedia(t, ctx, connect(t, r))
+
+	info := testMovie
+	info.MediaTracks = []MediaTrack{
+		NewTextTrack(1, "http://example.com/en.vtt", "en", "English"),
+		NewTextTrack(2, "http://example.com/fr.vtt", "fr", "Français"),
+		{ID: 3, Type: TrackTypeAudio, Language: "en"},
+	}
+	statuses, err := media.Load(ctx, info, LoadOptions{AutoPlay: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sessionId := statuses[0].MediaSessionID
+
+	tests := []struct {
+		name    string
+		request func() ([]MediaStatus, error)
+		active  []int64
+	}{
+		{"set", func() ([]MediaStatus, error) { return media.SetActiveTracks(ctx, sessionId, 1, 3) }, []int64{1, 3}},
+		{"language", func() ([]MediaStatus, error) {
+			return media.EditTracks(ctx, sessionId, EditTracksOptions{Language: "fr"})
+		}, []int64{2, 3}},
+		{"style", func() ([]MediaStatus, error) {
+			return media.SetTextTrackStyle(ctx, sessionId, TextTrackStyle{
+				ForegroundColor: RGBA(255, 255, 0, 255),
+				EdgeType:        EdgeTypeOutline,
+				FontScale:       1.5,
+			})
+		}, []int64{2, 3}},
+		{"none", func() ([]MediaStatus, error) { return media.SetActiveTracks(ctx, sessionId) }, nil},
+	}
+	for _, test := range tests {
+		statuses, err := test.request()
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if active := statuses[0].ActiveTrackIDs; !reflect.DeepEqual(active, test.active) {
+			t.Errorf("%s: got %v, want %v", test.name, active, test.active)
+		}
+	}
+
+	_, err = media.SetActiveTracks(ctx, sessionId, 1, 4)
+	if e, ok := err.(*UnknownTrackError); !ok || e.TrackID != 4 {
+		t.Errorf("enabled an unknown track: got %v", err)
+	}
+	_, err = media.EditTracks(ctx, sessionId, EditTracksOptions{Language: "de"})
+	if e, ok := err.(*InvalidRequestError); !ok || e.Reason != "INVALID_PARAMS" {
+		t.Errorf("enabled a missing language: got %v", err)
+	}
+}
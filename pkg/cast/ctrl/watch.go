@@ -0,0 +1,15 @@
+package ctrl
+
+// watchQueueSize is how many events the Watch channels of controllers hold
+// for their readers.
+const watchQueueSize = 16
+
+// dropOldest queues an event with send, which tells whether there was room
+// for it without blocking, and calls drop to throw the oldest queued event
+// away until there is. Publishers hold the watchers lock, so the only other
+// party is the reader, which only ever makes more room.
+func dropOldest(send func() bool, drop func()) {
+	for !send() {
+		drop()
+	}
+}
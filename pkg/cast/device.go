@@ -2,13 +2,19 @@ package cast
 
 import (
 	"context"
+	"errors"
 	"io"
 	"log"
 )
 
+// DeviceClosed is returned by operations that were still waiting on the
+// device when it was closed or its connection was lost.
+var DeviceClosed = errors.New("Device closed")
+
 type (
 	Device struct {
 		bc        *broadcaster
+		requests  *requests
 		ctx       context.Context
 		conn      io.ReadWriter
 		cancel    context.CancelFunc
@@ -22,6 +28,7 @@ func NewDevice(connection io.ReadWriter) *Device {
 	ctx, cancel := context.WithCancel(context.Background())
 	return &Device{
 		bc:        newMessageBroadcaster(),
+		requests:  newRequests(),
 		ctx:       ctx,
 		conn:      connection,
 		cancel:    cancel,
@@ -34,7 +41,17 @@ func (d *Device) Context() context.Context {
 }
 
 func (d *Device) NewChannel(namespace, sourceId, destinationId string) *Channel {
-	return newChannel(d, namespace, sourceId, destinationId)
+	return newChannel(d, namespace, sourceId, destinationId, ChannelOptions{})
+}
+
+func (d *Device) NewChannelWithOptions(namespace, sourceId, destinationId string, options ChannelOptions) *Channel {
+	return newChannel(d, namespace, sourceId, destinationId, options)
+}
+
+// NextRequestId returns a request id that isn't in use by any pending
+// request on this device.
+func (d *Device) NextRequestId() int32 {
+	return d.requests.next()
 }
 
 func (d *Device) Send(message *CastMessage) error {
@@ -42,7 +59,12 @@ func (d *Device) Send(message *CastMessage) error {
 	return Write(d.conn, message)
 }
 
+// Run reads messages from the connection and dispatches them until the
+// device is closed or the connection fails. The device context is cancelled
+// when Run returns.
 func (d *Device) Run() error {
+	defer d.cancel()
+
 	for {
 		select {
 		case <-d.ctx.Done():
@@ -53,15 +75,17 @@ func (d *Device) Run() error {
 		message, err := Read(d.conn)
 		if err == io.ErrNoProgress {
 			continue
-		} else if message != nil {
-			// if err == io.EOF, message can still be not null
-			// and that's why we have this weird branching here.
-			log.Println("<-", message)
-			d.bc.Publish(message)
+		} else if _, ok := err.(*InvalidMessageError); ok {
+			// The frame was consumed, so we're still in sync.
+			log.Println("Skipping message:", err)
+			continue
+		} else if err != nil {
+			return err
 		}
 
-		if err != nil {
-			return err
+		log.Println("<-", message)
+		if !d.requests.deliver(message) {
+			d.bc.Publish(message)
 		}
 	}
 }
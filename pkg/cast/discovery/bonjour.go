@@ -0,0 +1,97 @@
+package discovery
+
+import (
+	"net"
+	"strings"
+
+	"github.com/oleksandr/bonjour"
+)
+
+// Bonjour is a multicast DNS discoverer on the oleksandr/bonjour resolver.
+// The resolver tells about each device once, so its devices never change
+// or go away. Browser keeps up with them.
+type Bonjour struct {
+	registry
+	resolver *bonjour.Resolver
+	entries  chan *bonjour.ServiceEntry
+}
+
+// NewBonjour starts browsing for devices on the network of iface, or on
+// every network if iface is nil.
+func NewBonjour(iface *net.Interface) (*Bonjour, error) {
+	resolver, err := bonjour.NewResolver(iface)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bonjour{
+		registry: newRegistry(),
+		resolver: resolver,
+		entries:  make(chan *bonjour.ServiceEntry),
+	}
+	go b.run()
+
+	// A failed Browse stops the resolver, which can only happen once run
+	// takes the entry it may be handing over.
+	if err := resolver.Browse(Service, mdnsDomain, b.entries); err != nil {
+		b.close()
+		return nil, err
+	}
+
+	return b, nil
+}
+
+func (b *Bonjour) run() {
+	devices := make(map[string]Device)
+	for {
+		select {
+		case e := <-b.entries:
+			d, ok := bonjourDevice(e)
+			if !ok {
+				continue
+			}
+			next := make(map[string]Device, len(devices)+1)
+			for key, d := range devices {
+				next[key] = d
+			}
+			next[strings.ToLower(d.Instance)] = d
+			devices = next
+			b.set(devices)
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// bonjourDevice makes a device out of an entry, unless the entry lacks
+// what it takes to connect to it.
+func bonjourDevice(e *bonjour.ServiceEntry) (Device, bool) {
+	d := Device{
+		Instance: instanceName(e.Instance, mdnsDomain),
+		Host:     strings.TrimSuffix(e.HostName, "."),
+		Port:     e.Port,
+	}
+	d.setTXT(ParseTXT(e.Text))
+	if e.AddrIPv4 != nil {
+		d.IPv4 = []net.IP{e.AddrIPv4}
+	}
+	if e.AddrIPv6 != nil {
+		d.IPv6 = []net.IP{e.AddrIPv6}
+	}
+	return d, d.Port != 0 && d.Addr() != ""
+}
+
+func (b *Bonjour) Close() error {
+	if !b.close() {
+		return nil
+	}
+	// The resolver may be handing over an entry, so take it while asking
+	// the resolver to stop.
+	for {
+		select {
+		case b.resolver.Exit <- true:
+			return nil
+		case <-b.entries:
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package discovery
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/oleksandr/bonjour"
+)
+
+func TestBonjourDevice(t *testing.T) {
+	e := bonjour.NewServiceEntry(`Living\ Room`, Service, mdnsDomain)
+	e.HostName = "abc.local."
+	e.Port = 8009
+	e.Text = []string{"id=abc-1", "fn=Living Room", "ca=4"}
+	e.AddrIPv4 = net.ParseIP("10.0.0.2")
+
+	d, ok := bonjourDevice(e)
+	want := Device{
+		ID:           "abc1",
+		Name:         "Living Room",
+		Capabilities: CapabilityAudioOut,
+		Instance:     "Living Room",
+		Host:         "abc.local",
+		Port:         8009,
+		IPv4:         []net.IP{net.ParseIP("10.0.0.2")},
+		TXT:          map[string]string{"id": "abc-1", "fn": "Living Room", "ca": "4"},
+	}
+	if !ok || !reflect.DeepEqual(d, want) {
+		t.Errorf("got %+v, want %+v", d, want)
+	}
+
+	// Entries made of a PTR record alone can't be connected to.
+	if d, ok := bonjourDevice(bonjour.NewServiceEntry("Kitchen", Service, mdnsDomain)); ok {
+		t.Errorf("got %+v", d)
+	}
+}
@@ -0,0 +1,294 @@
+// Package discovery finds Cast devices on the network, and keeps track of
+// them as they come and go. Devices are found over multicast DNS, unicast
+// DNS-SD or a static list, or any mix of those.
+package discovery
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// maxQueryInterval is how often we ask for devices once the first
+	// queries, which come quicker, are done.
+	maxQueryInterval = time.Minute
+	// goodbyeTTL is how long records that are said to be gone live on, as
+	// RFC 6762 asks, in case another response says otherwise.
+	goodbyeTTL = time.Second
+	// cacheFlushBit marks records that replace every other record with
+	// their name and type.
+	cacheFlushBit = 1 << 15
+)
+
+type recordKey struct {
+	name   string
+	rrtype uint16
+	data   string
+}
+
+type cachedRecord struct {
+	rr       dns.RR
+	received time.Time
+	expires  time.Time
+	// refreshed tells whether we asked for the record again as it got close
+	// to expiring.
+	refreshed bool
+}
+
+// Browser is the multicast DNS discoverer. It keeps the set of devices on
+// the local network up to date, from the records it reads in responses and
+// announcements.
+type Browser struct {
+	registry
+	conn *mdnsConn
+
+	mu      sync.Mutex
+	records map[recordKey]*cachedRecord
+	asked   map[dns.Question]time.Time
+}
+
+// NewBrowser starts browsing for devices on the network of iface, or on
+// the default one if iface is nil.
+func NewBrowser(iface *net.Interface) (*Browser, error) {
+	conn, err := listenMDNS(iface)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Browser{
+		registry: newRegistry(),
+		conn:     conn,
+		records:  make(map[recordKey]*cachedRecord),
+		asked:    make(map[dns.Question]time.Time),
+	}
+
+	go conn.read(b.handle)
+	go b.run()
+
+	return b, nil
+}
+
+// Find browses for the device named, or with the UUID, query, for up to
+// timeout.
+func Find(query string, timeout time.Duration) (*Device, error) {
+	b, err := NewBrowser(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	d, err := b.Lookup(ctx, query)
+	if err == context.DeadlineExceeded {
+		return nil, DeviceNotFound
+	}
+	return d, err
+}
+
+// run queries for devices, quickly at first, and sweeps expired records.
+func (b *Browser) run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	interval := time.Second
+	nextQuery := time.Now()
+	for {
+		now := time.Now()
+		if !now.Before(nextQuery) {
+			b.conn.query([]dns.Question{question(serviceName(mdnsDomain), dns.TypePTR)})
+			nextQuery = now.Add(interval)
+			if interval *= 2; interval > maxQueryInterval {
+				interval = maxQueryInterval
+			}
+		}
+		b.sweep(now)
+
+		select {
+		case <-ticker.C:
+		case <-b.done:
+			return
+		}
+	}
+}
+
+// sweep drops expired records, and asks again for the ones about to
+// expire, at 80% of their lifetime.
+func (b *Browser) sweep(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var refresh []dns.Question
+	changed := false
+	for key, r := range b.records {
+		if !now.Before(r.expires) {
+			delete(b.records, key)
+			changed = true
+			continue
+		}
+		lifetime := r.expires.Sub(r.received)
+		if !r.refreshed && now.Sub(r.received) > lifetime*8/10 {
+			r.refreshed = true
+			refresh = append(refresh, question(key.name, key.rrtype))
+		}
+	}
+	if changed {
+		b.update()
+	}
+	b.ask(now, refresh...)
+}
+
+// handle caches the records of a response that are about Cast devices.
+func (b *Browser) handle(msg *dns.Msg) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	rrs := append(append(append([]dns.RR(nil), msg.Answer...), msg.Ns...), msg.Extra...)
+
+	// Addresses come in the same responses as the services, or on their
+	// own once we ask for them.
+	hosts := b.hosts()
+	for _, rr := range rrs {
+		if srv, ok := rr.(*dns.SRV); ok && isInstance(srv.Hdr.Name) {
+			hosts[strings.ToLower(srv.Target)] = true
+		}
+	}
+
+	changed := false
+	for _, rr := range rrs {
+		name := strings.ToLower(rr.Header().Name)
+		switch rr.(type) {
+		case *dns.PTR:
+			if name != serviceName(mdnsDomain) {
+				continue
+			}
+		case *dns.SRV, *dns.TXT:
+			if !isInstance(name) {
+				continue
+			}
+		case *dns.A, *dns.AAAA:
+			if !hosts[name] {
+				continue
+			}
+		default:
+			continue
+		}
+		b.cache(now, rr)
+		changed = true
+	}
+
+	if changed {
+		b.update()
+	}
+}
+
+func isInstance(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), "."+serviceName(mdnsDomain))
+}
+
+// hosts returns the hosts of the devices we know of.
+func (b *Browser) hosts() map[string]bool {
+	hosts := make(map[string]bool)
+	for _, r := range b.records {
+		if srv, ok := r.rr.(*dns.SRV); ok {
+			hosts[strings.ToLower(srv.Target)] = true
+		}
+	}
+	return hosts
+}
+
+func (b *Browser) cache(now time.Time, rr dns.RR) {
+	hdr := rr.Header()
+	flush := hdr.Class&cacheFlushBit != 0
+	hdr.Class &^= cacheFlushBit
+
+	key := recordKey{
+		name:   strings.ToLower(hdr.Name),
+		rrtype: hdr.Rrtype,
+		data:   strings.TrimPrefix(rr.String(), hdr.String()),
+	}
+
+	ttl := time.Duration(hdr.Ttl) * time.Second
+	if hdr.Ttl == 0 {
+		ttl = goodbyeTTL
+	}
+
+	if flush {
+		// Older records with the same name and type are stale, but those
+		// that came within the last second are part of the same answer.
+		for k, r := range b.records {
+			if k.name == key.name && k.rrtype == key.rrtype && k != key && now.Sub(r.received) > time.Second {
+				r.expires = now.Add(goodbyeTTL)
+			}
+		}
+	}
+
+	b.records[key] = &cachedRecord{
+		rr:       rr,
+		received: now,
+		expires:  now.Add(ttl),
+	}
+}
+
+// lookup returns the records with the given name and type, the latest
+// first.
+func (b *Browser) lookup(name string, rrtype uint16) []dns.RR {
+	name = strings.ToLower(name)
+	var found []*cachedRecord
+	for k, r := range b.records {
+		if k.name == name && k.rrtype == rrtype {
+			found = append(found, r)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].received.After(found[j].received)
+	})
+
+	rrs := make([]dns.RR, len(found))
+	for i, r := range found {
+		rrs[i] = r.rr
+	}
+	return rrs
+}
+
+// update rebuilds the devices from the cache, and asks for the records
+// that devices lack.
+func (b *Browser) update() {
+	devices, missing := buildDevices(mdnsDomain, b.lookup)
+	b.set(devices)
+	b.ask(time.Now(), missing...)
+}
+
+// ask queries questions, but not those asked within the last second.
+func (b *Browser) ask(now time.Time, questions ...dns.Question) {
+	var fresh []dns.Question
+	for _, q := range questions {
+		if last, ok := b.asked[q]; ok && now.Sub(last) < time.Second {
+			continue
+		}
+		b.asked[q] = now
+		fresh = append(fresh, q)
+	}
+	for q, last := range b.asked {
+		if now.Sub(last) > time.Minute {
+			delete(b.asked, q)
+		}
+	}
+	if len(fresh) > 0 {
+		go b.conn.query(fresh)
+	}
+}
+
+func (b *Browser) Close() error {
+	if !b.close() {
+		return nil
+	}
+	return b.conn.Close()
+}
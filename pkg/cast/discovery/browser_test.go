@@ -0,0 +1,88 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// newTestBrowser returns a browser that isn't on the network, and that
+// sends its queries nowhere.
+func newTestBrowser() *Browser {
+	return &Browser{
+		registry: newRegistry(),
+		conn:     &mdnsConn{},
+		records:  make(map[recordKey]*cachedRecord),
+		asked:    make(map[dns.Question]time.Time),
+	}
+}
+
+func response(rrs ...dns.RR) *dns.Msg {
+	msg := new(dns.Msg)
+	msg.Response = true
+	msg.Answer = rrs
+	return msg
+}
+
+func TestBrowserExpiry(t *testing.T) {
+	b := newTestBrowser()
+	defer b.Close()
+
+	b.handle(response(testRecords(120)...))
+	if devices := b.Devices(); len(devices) != 1 || devices[0].Name != "Living Room" {
+		t.Fatalf("got %+v", devices)
+	}
+
+	now := time.Now()
+	b.sweep(now.Add(100 * time.Second))
+	if len(b.Devices()) != 1 {
+		t.Error("the device expired early")
+	}
+	b.sweep(now.Add(121 * time.Second))
+	if devices := b.Devices(); len(devices) != 0 {
+		t.Errorf("got %+v after its records expired", devices)
+	}
+}
+
+func TestBrowserGoodbye(t *testing.T) {
+	b := newTestBrowser()
+	defer b.Close()
+
+	b.handle(response(testRecords(120)...))
+	b.handle(response(testRecords(0)[0]))
+	now := time.Now()
+	b.sweep(now)
+	if len(b.Devices()) != 1 {
+		t.Error("the device went away before the goodbye took effect")
+	}
+	b.sweep(now.Add(goodbyeTTL))
+	if devices := b.Devices(); len(devices) != 0 {
+		t.Errorf("got %+v after it said goodbye", devices)
+	}
+}
+
+func TestBrowserCacheFlush(t *testing.T) {
+	b := newTestBrowser()
+	defer b.Close()
+
+	rrs := testRecords(120)
+	b.handle(response(rrs...))
+	for key, r := range b.records {
+		r.received = r.received.Add(-time.Minute)
+		b.records[key] = r
+	}
+
+	// The device says its TXT record is now this one, and no other.
+	txt := &dns.TXT{Hdr: *rrs[2].Header(), Txt: []string{"id=abc-1", "fn=Kitchen"}}
+	txt.Hdr.Class |= cacheFlushBit
+	b.handle(response(txt))
+	if devices := b.Devices(); len(devices) != 1 || devices[0].Name != "Kitchen" {
+		t.Fatalf("got %+v", devices)
+	}
+
+	b.sweep(time.Now().Add(goodbyeTTL))
+	if n := len(b.lookup(testInstance, dns.TypeTXT)); n != 1 {
+		t.Errorf("kept %d TXT records", n)
+	}
+}
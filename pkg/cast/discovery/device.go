@@ -0,0 +1,129 @@
+package discovery
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Capabilities are the capability bits a device advertises in the ca field
+// of its TXT record.
+type Capabilities uint
+
+const (
+	CapabilityVideoOut Capabilities = 1 << iota
+	CapabilityVideoIn
+	CapabilityAudioOut
+	CapabilityAudioIn
+	CapabilityDevMode
+	CapabilityMultizoneGroup
+)
+
+var capabilityNames = []string{
+	"VIDEO_OUT",
+	"VIDEO_IN",
+	"AUDIO_OUT",
+	"AUDIO_IN",
+	"DEV_MODE",
+	"MULTIZONE_GROUP",
+}
+
+func (c Capabilities) Has(capability Capabilities) bool {
+	return c&capability == capability
+}
+
+func (c Capabilities) String() string {
+	var names []string
+	for i, name := range capabilityNames {
+		if c.Has(1 << uint(i)) {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// Device is a Cast device, as advertised over DNS-SD.
+type Device struct {
+	// ID is the UUID of the device, without dashes.
+	ID string
+	// Name is the friendly name the user gave the device.
+	Name         string
+	Model        string
+	Capabilities Capabilities
+	// Status is what the device is doing, such as the name of the app it
+	// runs, if anything.
+	Status string
+
+	// Instance is the name of the DNS-SD service instance, and Host the
+	// name of the host it runs on.
+	Instance string
+	Host     string
+	Port     int
+	IPv4     []net.IP
+	IPv6     []net.IP
+
+	// TXT has every field of the TXT record.
+	TXT map[string]string
+}
+
+// Addr returns the address to connect to the device at, preferring IPv4,
+// then IPv6, then its host name, or an empty string if it has none.
+func (d *Device) Addr() string {
+	var host string
+	if len(d.IPv4) > 0 {
+		host = d.IPv4[0].String()
+	} else if len(d.IPv6) > 0 {
+		host = d.IPv6[0].String()
+	} else if d.Host != "" {
+		host = d.Host
+	} else {
+		return ""
+	}
+	return net.JoinHostPort(host, strconv.Itoa(d.Port))
+}
+
+// IsGroup tells whether the device is a speaker group rather than an actual
+// device.
+func (d *Device) IsGroup() bool {
+	return d.Capabilities.Has(CapabilityMultizoneGroup)
+}
+
+// Matches tells whether query is the name, UUID or instance name of the
+// device. Names are compared regardless of case, and UUIDs regardless of
+// dashes.
+func (d *Device) Matches(query string) bool {
+	if strings.EqualFold(query, d.Name) || strings.EqualFold(query, d.Instance) {
+		return true
+	}
+	id := strings.Replace(query, "-", "", -1)
+	return d.ID != "" && strings.EqualFold(id, d.ID)
+}
+
+// ParseTXT parses the key=value strings of a TXT record. Keys are case
+// insensitive, and only their first occurrence counts.
+func ParseTXT(txt []string) map[string]string {
+	fields := make(map[string]string, len(txt))
+	for _, s := range txt {
+		key, value := s, ""
+		if i := strings.IndexByte(s, '='); i >= 0 {
+			key, value = s[:i], s[i+1:]
+		}
+		key = strings.ToLower(key)
+		if _, ok := fields[key]; key != "" && !ok {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+// setTXT fills in the device from the fields of its TXT record.
+func (d *Device) setTXT(fields map[string]string) {
+	d.TXT = fields
+	d.ID = strings.Replace(fields["id"], "-", "", -1)
+	d.Name = fields["fn"]
+	d.Model = fields["md"]
+	d.Status = fields["rs"]
+	if ca, err := strconv.ParseUint(fields["ca"], 10, 32); err == nil {
+		d.Capabilities = Capabilities(ca)
+	}
+}
@@ -0,0 +1,202 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+var DeviceNotFound = errors.New("Device not found")
+
+// watchQueueSize is how many changes a watcher can have pending on top of
+// the devices it was told about when it started watching.
+const watchQueueSize = 16
+
+// Discoverer finds devices, one way or another, and keeps track of them.
+type Discoverer interface {
+	// Devices returns the devices known right now, by name.
+	Devices() []Device
+	// Watch returns a channel that gets an added event for each device
+	// already known, and then every change, until ctx is done or the
+	// discoverer is closed. A watcher that falls behind misses the oldest
+	// changes, so it should go by Devices if it needs the full picture.
+	Watch(ctx context.Context) <-chan Event
+	// Lookup waits for the device named, or with the UUID, query. It fails
+	// with ctx.Err() once ctx is done, and with DeviceNotFound if the
+	// discoverer is closed first.
+	Lookup(ctx context.Context, query string) (*Device, error)
+	Close() error
+}
+
+type EventType int
+
+const (
+	DeviceAdded EventType = iota
+	DeviceUpdated
+	DeviceRemoved
+)
+
+func (t EventType) String() string {
+	switch t {
+	case DeviceAdded:
+		return "added"
+	case DeviceUpdated:
+		return "updated"
+	case DeviceRemoved:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Event tells that a device came, changed, such as when its status or
+// address does, or went away. Removed devices are as they were last seen.
+type Event struct {
+	Type   EventType
+	Device Device
+}
+
+// registry holds the devices a discoverer found, and tells watchers about
+// them. Discoverers embed it, and hand it every new set of devices.
+type registry struct {
+	mu       sync.Mutex
+	devices  map[string]Device
+	watchers map[chan Event]struct{}
+	done     chan struct{}
+	closed   bool
+}
+
+func newRegistry() registry {
+	return registry{
+		devices:  make(map[string]Device),
+		watchers: make(map[chan Event]struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// set replaces the devices, keyed by something that doesn't change while
+// they're around, and tells watchers what changed.
+func (r *registry) set(devices map[string]Device) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return
+	}
+
+	for key, d := range devices {
+		if old, ok := r.devices[key]; !ok {
+			r.publish(Event{Type: DeviceAdded, Device: d})
+		} else if !reflect.DeepEqual(old, d) {
+			r.publish(Event{Type: DeviceUpdated, Device: d})
+		}
+	}
+	for key, d := range r.devices {
+		if _, ok := devices[key]; !ok {
+			r.publish(Event{Type: DeviceRemoved, Device: d})
+		}
+	}
+	r.devices = devices
+}
+
+func (r *registry) Devices() []Device {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return sortDevices(r.devices)
+}
+
+func sortDevices(m map[string]Device) []Device {
+	devices := make([]Device, 0, len(m))
+	for _, d := range m {
+		devices = append(devices, d)
+	}
+	sort.Slice(devices, func(i, j int) bool {
+		if devices[i].Name != devices[j].Name {
+			return devices[i].Name < devices[j].Name
+		}
+		if devices[i].Instance != devices[j].Instance {
+			return devices[i].Instance < devices[j].Instance
+		}
+		return devices[i].Addr() < devices[j].Addr()
+	})
+	return devices
+}
+
+func (r *registry) Watch(ctx context.Context) <-chan Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	devices := sortDevices(r.devices)
+	ch := make(chan Event, watchQueueSize+len(devices))
+	if r.closed {
+		close(ch)
+		return ch
+	}
+	for _, d := range devices {
+		ch <- Event{Type: DeviceAdded, Device: d}
+	}
+	r.watchers[ch] = struct{}{}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-r.done:
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if _, ok := r.watchers[ch]; ok {
+			delete(r.watchers, ch)
+			close(ch)
+		}
+	}()
+
+	return ch
+}
+
+// publish hands e to every watcher, making room in a full queue by throwing
+// away its oldest change rather than holding up the discoverer.
+func (r *registry) publish(e Event) {
+	for ch := range r.watchers {
+		select {
+		case ch <- e:
+			continue
+		default:
+		}
+		select {
+		case <-ch:
+		default:
+		}
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
+
+func (r *registry) Lookup(ctx context.Context, query string) (*Device, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for e := range r.Watch(ctx) {
+		if e.Type != DeviceRemoved && e.Device.Matches(query) {
+			d := e.Device
+			return &d, nil
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, DeviceNotFound
+}
+
+// close stops the watchers, and tells whether the registry was open.
+func (r *registry) close() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return false
+	}
+	r.closed = true
+	close(r.done)
+	return true
+}
@@ -0,0 +1,47 @@
+package discovery
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLookup(t *testing.T) {
+	s := NewStatic(Device{Name: "Living Room", ID: "abc", Host: "10.0.0.2", Port: DefaultPort})
+	defer s.Close()
+
+	d, err := s.Lookup(context.Background(), "living room")
+	if err != nil {
+		t.Fatal(err)
+	} else if d.ID != "abc" {
+		t.Errorf("found %+v", d)
+	}
+}
+
+func TestLookupTimeout(t *testing.T) {
+	s := NewStatic(Device{Name: "Living Room", Host: "10.0.0.2", Port: DefaultPort})
+	defer s.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := s.Lookup(ctx, "Kitchen"); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.Lookup(ctx, "Kitchen"); err != context.Canceled {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestLookupClosed(t *testing.T) {
+	s := NewStatic()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		s.Close()
+	}()
+	if _, err := s.Lookup(context.Background(), "Kitchen"); err != DeviceNotFound {
+		t.Errorf("got %v, want %v", err, DeviceNotFound)
+	}
+}
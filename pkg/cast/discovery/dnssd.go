@@ -0,0 +1,105 @@
+package discovery
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// Service is the DNS-SD service Cast devices advertise.
+const Service = "_googlecast._tcp"
+
+func serviceName(domain string) string {
+	return Service + "." + strings.ToLower(dns.Fqdn(domain))
+}
+
+func question(name string, rrtype uint16) dns.Question {
+	return dns.Question{Name: name, Qtype: rrtype, Qclass: dns.ClassINET}
+}
+
+// buildDevices puts together the devices of domain out of the records
+// lookup finds, the latest first, keyed by instance. It also returns the
+// questions whose answers devices lack.
+func buildDevices(domain string, lookup func(name string, rrtype uint16) []dns.RR) (map[string]Device, []dns.Question) {
+	devices := make(map[string]Device)
+	var missing []dns.Question
+
+	for _, rr := range lookup(serviceName(domain), dns.TypePTR) {
+		instance := rr.(*dns.PTR).Ptr
+
+		srvs := lookup(instance, dns.TypeSRV)
+		txts := lookup(instance, dns.TypeTXT)
+		if len(srvs) == 0 || len(txts) == 0 {
+			missing = append(missing, question(instance, dns.TypeSRV), question(instance, dns.TypeTXT))
+			if len(srvs) == 0 {
+				continue
+			}
+		}
+		srv := srvs[0].(*dns.SRV)
+
+		d := Device{
+			Instance: instanceName(instance, domain),
+			Host:     strings.TrimSuffix(srv.Target, "."),
+			Port:     int(srv.Port),
+			TXT:      map[string]string{},
+		}
+		if len(txts) > 0 {
+			d.setTXT(ParseTXT(txts[0].(*dns.TXT).Txt))
+		}
+		for _, rr := range lookup(srv.Target, dns.TypeA) {
+			d.IPv4 = append(d.IPv4, rr.(*dns.A).A)
+		}
+		for _, rr := range lookup(srv.Target, dns.TypeAAAA) {
+			d.IPv6 = append(d.IPv6, rr.(*dns.AAAA).AAAA)
+		}
+		sortIPs(d.IPv4)
+		sortIPs(d.IPv6)
+		if len(d.IPv4) == 0 && len(d.IPv6) == 0 {
+			missing = append(missing, question(srv.Target, dns.TypeA), question(srv.Target, dns.TypeAAAA))
+			continue
+		}
+
+		devices[strings.ToLower(instance)] = d
+	}
+
+	return devices, missing
+}
+
+func sortIPs(ips []net.IP) {
+	sort.Slice(ips, func(i, j int) bool {
+		return ips[i].String() < ips[j].String()
+	})
+}
+
+// instanceName returns the instance part of a service instance name of
+// domain, unescaped.
+func instanceName(name, domain string) string {
+	name = dns.Fqdn(name)
+	if suffix := "." + serviceName(domain); len(name) > len(suffix) && strings.EqualFold(name[len(name)-len(suffix):], suffix) {
+		name = name[:len(name)-len(suffix)]
+	} else {
+		name = strings.TrimSuffix(name, ".")
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c != '\\' || i+1 >= len(name) {
+			out.WriteByte(c)
+			continue
+		}
+		if i+3 < len(name) {
+			if n, err := strconv.Atoi(name[i+1 : i+4]); err == nil && n < 256 {
+				out.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		out.WriteByte(name[i+1])
+		i++
+	}
+	return out.String()
+}
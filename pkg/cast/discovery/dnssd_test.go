@@ -0,0 +1,98 @@
+package discovery
+
+import (
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	testInstance = "Chromecast-abc._googlecast._tcp.local."
+	testHost     = "abc.local."
+)
+
+func testRecords(ttl uint32) []dns.RR {
+	hdr := func(name string, rrtype uint16) dns.RR_Header {
+		return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: ttl}
+	}
+	return []dns.RR{
+		&dns.PTR{Hdr: hdr("_googlecast._tcp.local.", dns.TypePTR), Ptr: testInstance},
+		&dns.SRV{Hdr: hdr(testInstance, dns.TypeSRV), Target: testHost, Port: 8009},
+		&dns.TXT{Hdr: hdr(testInstance, dns.TypeTXT), Txt: []string{"id=abc-1", "fn=Living Room", "md=Chromecast", "ca=5", "rs="}},
+		&dns.A{Hdr: hdr(testHost, dns.TypeA), A: net.ParseIP("10.0.0.2").To4()},
+	}
+}
+
+// lookupIn looks records up in rrs, regardless of case.
+func lookupIn(rrs []dns.RR) func(string, uint16) []dns.RR {
+	return func(name string, rrtype uint16) []dns.RR {
+		var found []dns.RR
+		for _, rr := range rrs {
+			if h := rr.Header(); h.Rrtype == rrtype && dns.CanonicalName(h.Name) == dns.CanonicalName(name) {
+				found = append(found, rr)
+			}
+		}
+		return found
+	}
+}
+
+func TestBuildDevices(t *testing.T) {
+	rrs := testRecords(120)
+	devices, missing := buildDevices("local", lookupIn(rrs))
+	want := Device{
+		ID:           "abc1",
+		Name:         "Living Room",
+		Model:        "Chromecast",
+		Capabilities: CapabilityVideoOut | CapabilityAudioOut,
+		Instance:     "Chromecast-abc",
+		Host:         "abc.local",
+		Port:         8009,
+		IPv4:         []net.IP{net.ParseIP("10.0.0.2").To4()},
+		TXT:          map[string]string{"id": "abc-1", "fn": "Living Room", "md": "Chromecast", "ca": "5", "rs": ""},
+	}
+	if d := devices["chromecast-abc._googlecast._tcp.local."]; len(devices) != 1 || !reflect.DeepEqual(d, want) {
+		t.Errorf("got %+v, want %+v", devices, want)
+	}
+	if len(missing) != 0 {
+		t.Errorf("asked for %v", missing)
+	}
+
+	// Without an address, the device isn't usable yet.
+	devices, missing = buildDevices("local", lookupIn(rrs[:3]))
+	if len(devices) != 0 {
+		t.Errorf("got %+v without an address", devices)
+	}
+	if want := []dns.Question{question(testHost, dns.TypeA), question(testHost, dns.TypeAAAA)}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("asked for %v, want %v", missing, want)
+	}
+
+	// Without a TXT record, it is, but we still ask for it.
+	devices, missing = buildDevices("local", lookupIn([]dns.RR{rrs[0], rrs[1], rrs[3]}))
+	if d, ok := devices["chromecast-abc._googlecast._tcp.local."]; !ok || d.Name != "" || d.Addr() != "10.0.0.2:8009" {
+		t.Errorf("got %+v without a TXT record", devices)
+	}
+	if want := []dns.Question{question(testInstance, dns.TypeSRV), question(testInstance, dns.TypeTXT)}; !reflect.DeepEqual(missing, want) {
+		t.Errorf("asked for %v, want %v", missing, want)
+	}
+}
+
+func TestInstanceName(t *testing.T) {
+	tests := []struct {
+		name, domain, want string
+	}{
+		{"Chromecast-abc._googlecast._tcp.local.", "local.", "Chromecast-abc"},
+		{"Chromecast-abc._GoogleCast._TCP.Local", "local", "Chromecast-abc"},
+		{`Living\ Room\.1._googlecast._tcp.example.com.`, "example.com", "Living Room.1"},
+		{`Caf\195\169._googlecast._tcp.local.`, "local.", "Café"},
+		{`Odd\9._googlecast._tcp.local.`, "local.", "Odd9"},
+		{"Chromecast-abc._googlecast._tcp.local.", "example.com", "Chromecast-abc._googlecast._tcp.local"},
+		{"_googlecast._tcp.local.", "local.", "_googlecast._tcp.local"},
+	}
+	for _, test := range tests {
+		if got := instanceName(test.name, test.domain); got != test.want {
+			t.Errorf("instanceName(%q, %q) = %q, want %q", test.name, test.domain, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package discovery
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/miekg/dns"
+)
+
+// mdnsDomain is the domain of multicast DNS names.
+const mdnsDomain = "local."
+
+var (
+	mdnsIPv4 = &net.UDPAddr{IP: net.ParseIP("224.0.0.251"), Port: 5353}
+	mdnsIPv6 = &net.UDPAddr{IP: net.ParseIP("ff02::fb"), Port: 5353}
+)
+
+// mdnsConn sends multicast DNS queries, and reads every response on the
+// link, over IPv4 and, where available, IPv6.
+type mdnsConn struct {
+	conns  []*net.UDPConn
+	groups []*net.UDPAddr
+
+	closeOnce sync.Once
+}
+
+func listenMDNS(iface *net.Interface) (*mdnsConn, error) {
+	c := &mdnsConn{}
+	for _, group := range []*net.UDPAddr{mdnsIPv4, mdnsIPv6} {
+		network := "udp4"
+		if group.IP.To4() == nil {
+			network = "udp6"
+		}
+		conn, err := net.ListenMulticastUDP(network, iface, group)
+		if err != nil {
+			continue
+		}
+		c.conns = append(c.conns, conn)
+		c.groups = append(c.groups, group)
+	}
+	if len(c.conns) == 0 {
+		return nil, errors.New("Failed to join the multicast DNS group")
+	}
+	return c, nil
+}
+
+// query asks the link about questions. It fails only if no query could be
+// sent at all.
+func (c *mdnsConn) query(questions []dns.Question) error {
+	msg := new(dns.Msg)
+	msg.Question = questions
+	msg.RecursionDesired = false
+	b, err := msg.Pack()
+	if err != nil {
+		return err
+	}
+
+	var sent bool
+	for i, conn := range c.conns {
+		if _, e := conn.WriteToUDP(b, c.groups[i]); e == nil {
+			sent = true
+		} else {
+			err = e
+		}
+	}
+	if sent {
+		return nil
+	}
+	return err
+}
+
+// read calls handle with every response that arrives, until the connection
+// is closed.
+func (c *mdnsConn) read(handle func(*dns.Msg)) {
+	var wg sync.WaitGroup
+	for _, conn := range c.conns {
+		wg.Add(1)
+		go func(conn *net.UDPConn) {
+			defer wg.Done()
+			buf := make([]byte, 65536)
+			for {
+				n, _, err := conn.ReadFromUDP(buf)
+				if err != nil {
+					if ne, ok := err.(net.Error); ok && ne.Temporary() {
+						continue
+					}
+					return
+				}
+				msg := new(dns.Msg)
+				if err := msg.Unpack(buf[:n]); err != nil || !msg.Response {
+					continue
+				}
+				handle(msg)
+			}
+		}(conn)
+	}
+	wg.Wait()
+}
+
+func (c *mdnsConn) Close() error {
+	c.closeOnce.Do(func() {
+		for _, conn := range c.conns {
+			conn.Close()
+		}
+	})
+	return nil
+}
@@ -0,0 +1,123 @@
+package discovery
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Merged is a discoverer that combines the devices of others. A device that
+// several of them find, by UUID or by address, is taken from the first one
+// given, with what it lacks filled in from the others.
+type Merged struct {
+	registry
+	discoverers []Discoverer
+	cancel      context.CancelFunc
+
+	mu      sync.Mutex
+	sources []map[string]Device
+}
+
+func Merge(discoverers ...Discoverer) *Merged {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Merged{
+		registry:    newRegistry(),
+		discoverers: discoverers,
+		cancel:      cancel,
+		sources:     make([]map[string]Device, len(discoverers)),
+	}
+	for i := range discoverers {
+		m.sources[i] = make(map[string]Device)
+	}
+	for i, d := range discoverers {
+		go m.watch(ctx, i, d)
+	}
+	return m
+}
+
+func (m *Merged) watch(ctx context.Context, i int, d Discoverer) {
+	for e := range d.Watch(ctx) {
+		m.mu.Lock()
+		key := sourceKey(e.Device)
+		if e.Type == DeviceRemoved {
+			delete(m.sources[i], key)
+		} else {
+			m.sources[i][key] = e.Device
+		}
+		m.update()
+		m.mu.Unlock()
+	}
+}
+
+// sourceKey tells the devices of a single discoverer apart.
+func sourceKey(d Device) string {
+	if d.Instance != "" {
+		return strings.ToLower(d.Instance)
+	}
+	return d.Name + "@" + d.Addr()
+}
+
+func (m *Merged) update() {
+	devices := make(map[string]Device)
+	ids := make(map[string]string)
+	addrs := make(map[string]string)
+	for i, source := range m.sources {
+		for _, d := range sortDevices(source) {
+			addr := d.Addr()
+			key, seen := addrs[addr]
+			if !seen && d.ID != "" {
+				key, seen = ids[d.ID]
+			}
+			if seen {
+				devices[key] = fill(devices[key], d)
+			} else {
+				key = strconv.Itoa(i) + "/" + sourceKey(d)
+				devices[key] = d
+			}
+			addrs[addr] = key
+			if d.ID != "" {
+				ids[d.ID] = key
+			}
+		}
+	}
+	m.set(devices)
+}
+
+// fill fills in what d lacks, such as the UUID and TXT record of a static
+// device, from the same device found otherwise.
+func fill(d, from Device) Device {
+	if d.ID == "" {
+		d.ID = from.ID
+	}
+	if d.Model == "" {
+		d.Model = from.Model
+	}
+	if d.Capabilities == 0 {
+		d.Capabilities = from.Capabilities
+	}
+	if d.Status == "" {
+		d.Status = from.Status
+	}
+	if d.Instance == "" {
+		d.Instance = from.Instance
+	}
+	if len(d.TXT) == 0 {
+		d.TXT = from.TXT
+	}
+	return d
+}
+
+// Close closes every discoverer merged.
+func (m *Merged) Close() error {
+	m.cancel()
+	m.close()
+
+	var err error
+	for _, d := range m.discoverers {
+		if e := d.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
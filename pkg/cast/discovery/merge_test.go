@@ -0,0 +1,60 @@
+package discovery
+
+import (
+	"net"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// waitDevices waits for the devices of d to be want.
+func waitDevices(t *testing.T, d Discoverer, want []Device) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		devices := d.Devices()
+		if reflect.DeepEqual(devices, want) {
+			return
+		} else if time.Now().After(deadline) {
+			t.Fatalf("got %+v, want %+v", devices, want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	found := []Device{
+		{ID: "abc", Name: "Living Room TV", Model: "Chromecast", Instance: "Chromecast-abc", Host: "abc.local", Port: DefaultPort,
+			IPv4: []net.IP{net.ParseIP("10.0.0.2")}, TXT: map[string]string{"id": "abc", "fn": "Living Room TV"}},
+		{ID: "def", Name: "Kitchen speaker", Instance: "Speaker-def", Port: DefaultPort, IPv4: []net.IP{net.ParseIP("10.0.0.4")}, TXT: map[string]string{"id": "def"}},
+		{ID: "ghi", Name: "Bedroom", Instance: "Chromecast-ghi", Port: DefaultPort, IPv4: []net.IP{net.ParseIP("10.0.0.5")}, TXT: map[string]string{"id": "ghi"}},
+	}
+	static := []Device{
+		{Name: "TV", Host: "10.0.0.2", Port: DefaultPort, IPv4: []net.IP{net.ParseIP("10.0.0.2")}, TXT: map[string]string{}},
+		{ID: "def", Name: "Kitchen", Host: "10.0.0.9", Port: DefaultPort, IPv4: []net.IP{net.ParseIP("10.0.0.9")}, TXT: map[string]string{}},
+	}
+	m := Merge(NewStatic(static...), NewStatic(found...))
+	defer m.Close()
+
+	// The static devices win, with what they lack filled in, whether they
+	// match by address or by UUID.
+	tv := static[0]
+	tv.ID, tv.Model, tv.Instance, tv.TXT = "abc", "Chromecast", "Chromecast-abc", found[0].TXT
+	kitchen := static[1]
+	kitchen.Instance, kitchen.TXT = "Speaker-def", found[1].TXT
+	waitDevices(t, m, []Device{found[2], kitchen, tv})
+}
+
+func TestMergeRemoved(t *testing.T) {
+	u := NewStatic()
+	m := Merge(NewStatic(Device{Name: "TV", Host: "10.0.0.2", Port: DefaultPort}), u)
+	defer m.Close()
+
+	tv := Device{ID: "abc", Name: "TV", Instance: "Chromecast-abc", Port: DefaultPort, IPv4: []net.IP{net.ParseIP("10.0.0.2")}}
+	u.set(map[string]Device{"abc": tv})
+	waitDevices(t, m, []Device{{ID: "abc", Name: "TV", Instance: "Chromecast-abc", Host: "10.0.0.2", Port: DefaultPort}})
+
+	// Once the device is gone from the network, the static one is left as
+	// it was.
+	u.set(map[string]Device{})
+	waitDevices(t, m, []Device{{Name: "TV", Host: "10.0.0.2", Port: DefaultPort}})
+}
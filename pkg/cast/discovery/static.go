@@ -0,0 +1,95 @@
+package discovery
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultPort is the port Cast devices listen on.
+const DefaultPort = 8009
+
+// Static is a discoverer of devices known in advance, such as those from a
+// config file. Its devices never change.
+type Static struct {
+	registry
+}
+
+func NewStatic(devices ...Device) *Static {
+	s := &Static{registry: newRegistry()}
+	m := make(map[string]Device, len(devices))
+	for i, d := range devices {
+		m[strconv.Itoa(i)] = d
+	}
+	s.set(m)
+	return s
+}
+
+// ParseDevice parses a device given as [name=]host[:port]. The name
+// defaults to the host, and the port to DefaultPort.
+func ParseDevice(spec string) (Device, error) {
+	spec = strings.TrimSpace(spec)
+	var name string
+	if i := strings.LastIndexByte(spec, '='); i >= 0 {
+		name, spec = strings.TrimSpace(spec[:i]), strings.TrimSpace(spec[i+1:])
+	}
+
+	host, port := spec, DefaultPort
+	if h, p, err := net.SplitHostPort(spec); err == nil {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 || n > 65535 {
+			return Device{}, errors.New("Invalid port in " + spec)
+		}
+		host, port = h, n
+	} else {
+		host = strings.TrimSuffix(strings.TrimPrefix(host, "["), "]")
+	}
+	if host == "" {
+		return Device{}, errors.New("Missing host in " + spec)
+	}
+	if name == "" {
+		name = host
+	}
+
+	d := Device{Name: name, Host: host, Port: port, TXT: map[string]string{}}
+	if ip := net.ParseIP(host); ip.To4() != nil {
+		d.IPv4 = []net.IP{ip}
+	} else if ip != nil {
+		d.IPv6 = []net.IP{ip}
+	}
+	return d, nil
+}
+
+// ReadDevices reads devices from a file with one device per line, as
+// ParseDevice takes them. Blank lines and lines that start with # are
+// skipped.
+func ReadDevices(path string) ([]Device, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var devices []Device
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		d, err := ParseDevice(line)
+		if err != nil {
+			return nil, errors.New(path + ":" + strconv.Itoa(n) + ": " + err.Error())
+		}
+		devices = append(devices, d)
+	}
+	return devices, scanner.Err()
+}
+
+func (s *Static) Close() error {
+	s.close()
+	return nil
+}
@@ -0,0 +1,62 @@
+package discovery
+
+import (
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDevice(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Device
+	}{
+		{"10.0.0.2", Device{Name: "10.0.0.2", Host: "10.0.0.2", Port: DefaultPort, IPv4: []net.IP{net.ParseIP("10.0.0.2")}}},
+		{"Living Room=10.0.0.2:8010", Device{Name: "Living Room", Host: "10.0.0.2", Port: 8010, IPv4: []net.IP{net.ParseIP("10.0.0.2")}}},
+		{" tv.local ", Device{Name: "tv.local", Host: "tv.local", Port: DefaultPort}},
+		{"TV = tv.local:9000", Device{Name: "TV", Host: "tv.local", Port: 9000}},
+		{"fe80::1", Device{Name: "fe80::1", Host: "fe80::1", Port: DefaultPort, IPv6: []net.IP{net.ParseIP("fe80::1")}}},
+		{"[fe80::1]", Device{Name: "fe80::1", Host: "fe80::1", Port: DefaultPort, IPv6: []net.IP{net.ParseIP("fe80::1")}}},
+		{"a=b=[fe80::1]:8010", Device{Name: "a=b", Host: "fe80::1", Port: 8010, IPv6: []net.IP{net.ParseIP("fe80::1")}}},
+	}
+	for _, test := range tests {
+		test.want.TXT = map[string]string{}
+		d, err := ParseDevice(test.spec)
+		if err != nil {
+			t.Errorf("%q: %s", test.spec, err)
+		} else if !reflect.DeepEqual(d, test.want) {
+			t.Errorf("%q: got %+v, want %+v", test.spec, d, test.want)
+		}
+	}
+
+	for _, spec := range []string{"", "TV=", "10.0.0.2:0", "10.0.0.2:http", "[fe80::1]:70000", ":8009"} {
+		if d, err := ParseDevice(spec); err == nil {
+			t.Errorf("%q: got %+v", spec, d)
+		}
+	}
+}
+
+func TestReadDevices(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices")
+	content := "# Devices\n\nLiving Room=10.0.0.2\n  Kitchen=10.0.0.3:8010  \n"
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	devices, err := ReadDevices(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 2 || devices[0].Addr() != "10.0.0.2:8009" || devices[1].Name != "Kitchen" || devices[1].Addr() != "10.0.0.3:8010" {
+		t.Errorf("got %+v", devices)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("10.0.0.2\n\nTV=10.0.0.3:x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadDevices(path); err == nil || !strings.HasPrefix(err.Error(), path+":3: ") {
+		t.Errorf("got %v", err)
+	}
+}
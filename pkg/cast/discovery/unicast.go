@@ -0,0 +1,143 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// DefaultPollInterval is how often a unicast discoverer asks for
+	// devices again, unless told otherwise.
+	DefaultPollInterval = 30 * time.Second
+	// unicastTimeout bounds each round of queries.
+	unicastTimeout = 5 * time.Second
+)
+
+// Unicast is the unicast DNS-SD discoverer, as in RFC 6763. It polls a DNS
+// server for the devices of a domain, which helps where multicast doesn't
+// reach, such as across subnets and from inside containers.
+type Unicast struct {
+	registry
+	server string
+	domain string
+	cancel context.CancelFunc
+}
+
+// NewUnicast starts asking server, as host or host:port, for the devices of
+// domain, every interval.
+func NewUnicast(server, domain string, interval time.Duration) *Unicast {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	u := &Unicast{
+		registry: newRegistry(),
+		server:   server,
+		domain:   strings.ToLower(dns.Fqdn(domain)),
+		cancel:   cancel,
+	}
+	go u.run(ctx, interval)
+	return u
+}
+
+func (u *Unicast) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := u.poll(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Failed to look for devices on %s: %s", u.server, err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// poll asks for the services, then for their SRV and TXT records, then for
+// the addresses of their hosts, unless the server already gave them out
+// along the way. The devices stay as they were if the server fails.
+func (u *Unicast) poll(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, unicastTimeout)
+	defer cancel()
+
+	var rrs []dns.RR
+	lookup := func(name string, rrtype uint16) []dns.RR {
+		var found []dns.RR
+		for _, rr := range rrs {
+			hdr := rr.Header()
+			if hdr.Rrtype == rrtype && strings.EqualFold(hdr.Name, name) {
+				found = append(found, rr)
+			}
+		}
+		return found
+	}
+
+	asked := make(map[dns.Question]bool)
+	questions := []dns.Question{question(serviceName(u.domain), dns.TypePTR)}
+	for {
+		for _, q := range questions {
+			if asked[q] {
+				continue
+			}
+			asked[q] = true
+			in, err := u.exchange(ctx, q)
+			if err != nil {
+				return err
+			}
+			rrs = append(append(rrs, in.Answer...), in.Extra...)
+		}
+
+		devices, missing := buildDevices(u.domain, lookup)
+		questions = questions[:0]
+		for _, q := range missing {
+			if !asked[q] {
+				questions = append(questions, q)
+			}
+		}
+		if len(questions) == 0 {
+			u.set(devices)
+			return nil
+		}
+	}
+}
+
+// exchange asks the server q, over TCP if the answer doesn't fit in UDP.
+func (u *Unicast) exchange(ctx context.Context, q dns.Question) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.Id = dns.Id()
+	msg.RecursionDesired = true
+	msg.Question = []dns.Question{q}
+	msg.SetEdns0(dns.DefaultMsgSize, false)
+
+	client := &dns.Client{UDPSize: dns.DefaultMsgSize}
+	in, _, err := client.ExchangeContext(ctx, msg, u.server)
+	if err == nil && in.Truncated {
+		client.Net = "tcp"
+		in, _, err = client.ExchangeContext(ctx, msg, u.server)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if in.Rcode != dns.RcodeSuccess && in.Rcode != dns.RcodeNameError {
+		return nil, errors.New("DNS query failed: " + dns.RcodeToString[in.Rcode])
+	}
+	return in, nil
+}
+
+func (u *Unicast) Close() error {
+	u.cancel()
+	u.close()
+	return nil
+}
@@ -0,0 +1,75 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// serveDNS answers queries on localhost with handler, and returns the
+// server's address.
+func serveDNS(t *testing.T, handler dns.HandlerFunc) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: conn, Handler: handler, NotifyStartedFunc: func() { close(started) }}
+	go server.ActivateAndServe()
+	<-started
+	t.Cleanup(func() { server.Shutdown() })
+	return conn.LocalAddr().String()
+}
+
+func TestUnicast(t *testing.T) {
+	instance := "Chromecast-abc._googlecast._tcp.example.com."
+	hdr := func(name string, rrtype uint16) dns.RR_Header {
+		return dns.RR_Header{Name: name, Rrtype: rrtype, Class: dns.ClassINET, Ttl: 60}
+	}
+	records := map[uint16]dns.RR{
+		dns.TypePTR: &dns.PTR{Hdr: hdr("_googlecast._tcp.example.com.", dns.TypePTR), Ptr: instance},
+		dns.TypeSRV: &dns.SRV{Hdr: hdr(instance, dns.TypeSRV), Target: "abc.example.com.", Port: 8009},
+		dns.TypeTXT: &dns.TXT{Hdr: hdr(instance, dns.TypeTXT), Txt: []string{"id=abc", "fn=Living Room"}},
+		dns.TypeA:   &dns.A{Hdr: hdr("abc.example.com.", dns.TypeA), A: net.ParseIP("10.0.0.2").To4()},
+	}
+	addr := serveDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetReply(r)
+		if rr, ok := records[r.Question[0].Qtype]; ok && dns.CanonicalName(rr.Header().Name) == dns.CanonicalName(r.Question[0].Name) {
+			m.Answer = []dns.RR{rr}
+		}
+		w.WriteMsg(m)
+	})
+
+	u := NewUnicast(addr, "Example.com", time.Hour)
+	defer u.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	d, err := u.Lookup(ctx, "living room")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.ID != "abc" || d.Instance != "Chromecast-abc" || d.Host != "abc.example.com" || d.Addr() != "10.0.0.2:8009" {
+		t.Errorf("found %+v", d)
+	}
+}
+
+func TestUnicastRefused(t *testing.T) {
+	addr := serveDNS(t, func(w dns.ResponseWriter, r *dns.Msg) {
+		m := new(dns.Msg)
+		m.SetRcode(r, dns.RcodeRefused)
+		w.WriteMsg(m)
+	})
+
+	u := NewUnicast(addr, "example.com", time.Hour)
+	defer u.Close()
+	if err := u.poll(context.Background()); err == nil || err.Error() != "DNS query failed: REFUSED" {
+		t.Errorf("got %v", err)
+	}
+	if devices := u.Devices(); len(devices) != 0 {
+		t.Errorf("got %+v", devices)
+	}
+}
@@ -3,80 +3,175 @@ package cast
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"sync"
 
 	"github.com/golang/protobuf/proto"
 )
 
+// MaxMessageSize is the largest payload the Cast protocol allows in a
+// single frame, not counting the 4 bytes length header.
+const MaxMessageSize = 64 * 1024
+
+const headerSize = 4
+
 var IncompleteReadError = errors.New("Failed to read all the data")
 var IncompleteWriteError = errors.New("Failed to write all the data")
 
+// MessageTooLargeError is returned when a frame is larger than
+// MaxMessageSize. When reading, the stream can't be trusted anymore after
+// that and should be closed.
+type MessageTooLargeError struct {
+	Length uint32
+}
+
+func (e *MessageTooLargeError) Error() string {
+	return fmt.Sprintf("Message too large: %d bytes (max %d)", e.Length, MaxMessageSize)
+}
+
+// InvalidMessageError is returned by Read when a complete frame was read but
+// it doesn't contain a valid CastMessage. The frame is consumed, so it is
+// safe to keep reading from the same stream.
+type InvalidMessageError struct {
+	Err error
+}
+
+func (e *InvalidMessageError) Error() string {
+	return fmt.Sprintf("Invalid message: %s", e.Err)
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, 0, 512)
+		return &buf
+	},
+}
+
+func getBuffer(size int) *[]byte {
+	buf := bufferPool.Get().(*[]byte)
+	resizeBuffer(buf, size)
+	return buf
+}
+
+func resizeBuffer(buf *[]byte, size int) []byte {
+	if cap(*buf) < size {
+		*buf = make([]byte, size)
+	}
+	*buf = (*buf)[:size]
+	return *buf
+}
+
+func putBuffer(buf *[]byte) {
+	bufferPool.Put(buf)
+}
+
 func Read(r io.Reader) (*CastMessage, error) {
-	data, err := ReadMessage(r)
-	if err != nil && err != io.EOF || data == nil {
+	buf := getBuffer(headerSize)
+	defer putBuffer(buf)
+
+	length, err := readHeader(r, *buf)
+	if err != nil {
 		return nil, err
 	}
 
+	data := resizeBuffer(buf, int(length))
+	if err := readBody(r, data); err != nil {
+		return nil, err
+	}
+
+	// Unmarshal copies everything it keeps, so the buffer can be reused.
 	message := &CastMessage{}
 	if err := proto.Unmarshal(data, message); err != nil {
-		return nil, err
+		return nil, &InvalidMessageError{Err: err}
 	}
 
-	// We can have a non-nil message + io.EOF error
-	return message, err
+	return message, nil
 }
 
 func Write(w io.Writer, message *CastMessage) error {
 	proto.SetDefaults(message)
 
-	data, err := proto.Marshal(message)
-	if err != nil {
+	buf := getBuffer(headerSize)
+	defer putBuffer(buf)
+
+	b := proto.NewBuffer(*buf)
+	if err := b.Marshal(message); err != nil {
 		return err
 	}
+	*buf = b.Bytes()
 
-	return WriteMessage(w, data)
+	return writeFrame(w, *buf)
 }
 
+// ReadMessage reads a complete frame and returns its payload. It returns
+// io.EOF if the stream ended cleanly before the frame, IncompleteReadError if
+// it ended in the middle of one, and io.ErrNoProgress for empty frames.
 func ReadMessage(r io.Reader) ([]byte, error) {
-	length := new(uint32)
-	err := binary.Read(r, binary.BigEndian, length)
-	if err != nil && err != io.EOF || length == nil {
+	var header [headerSize]byte
+
+	length, err := readHeader(r, header[:])
+	if err != nil {
 		return nil, err
 	}
 
-	if *length > 0 {
-		buf := make([]byte, *length)
+	data := make([]byte, length)
+	if err := readBody(r, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func WriteMessage(w io.Writer, data []byte) error {
+	buf := getBuffer(headerSize + len(data))
+	defer putBuffer(buf)
+
+	copy((*buf)[headerSize:], data)
 
-		i, err := r.Read(buf)
-		if err != nil && err != io.EOF || i <= 0 {
-			return nil, err
-		}
+	return writeFrame(w, *buf)
+}
 
-		if uint32(i) != *length {
-			if err == nil {
-				err = IncompleteReadError
-			}
-			return nil, err
-		}
+func readHeader(r io.Reader, header []byte) (uint32, error) {
+	_, err := io.ReadFull(r, header[:headerSize])
+	if err == io.ErrUnexpectedEOF {
+		return 0, IncompleteReadError
+	} else if err != nil {
+		return 0, err
+	}
 
-		// We can have a non-nil message + io.EOF error
-		return buf, err
+	length := binary.BigEndian.Uint32(header)
+	if length == 0 {
+		return 0, io.ErrNoProgress
+	} else if length > MaxMessageSize {
+		return 0, &MessageTooLargeError{Length: length}
 	}
 
-	return nil, io.ErrNoProgress
+	return length, nil
 }
 
-func WriteMessage(w io.Writer, data []byte) error {
-	length := len(data)
-	err := binary.Write(w, binary.BigEndian, uint32(length))
-	if err != nil {
-		return err
+func readBody(r io.Reader, data []byte) error {
+	_, err := io.ReadFull(r, data)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return IncompleteReadError
 	}
+	return err
+}
+
+// writeFrame fills in the length header of frame, which must have room for
+// it at the start, and writes the whole frame at once.
+func writeFrame(w io.Writer, frame []byte) error {
+	length := len(frame) - headerSize
+	if length > MaxMessageSize {
+		return &MessageTooLargeError{Length: uint32(length)}
+	}
+
+	binary.BigEndian.PutUint32(frame, uint32(length))
 
-	written, err := w.Write(data)
+	written, err := w.Write(frame)
 	if err != nil {
 		return err
-	} else if written != length {
+	} else if written != len(frame) {
 		return IncompleteWriteError
 	}
 
@@ -0,0 +1,152 @@
+package cast
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func testMessage(payload string) *CastMessage {
+	return &CastMessage{
+		ProtocolVersion: CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        proto.String("sender-0"),
+		DestinationId:   proto.String("receiver-0"),
+		Namespace:       proto.String("urn:x-cast:com.google.cast.receiver"),
+		PayloadType:     CastMessage_STRING.Enum(),
+		PayloadUtf8:     proto.String(payload),
+	}
+}
+
+func frame(data []byte) []byte {
+	f := make([]byte, headerSize+len(data))
+	binary.BigEndian.PutUint32(f, uint32(len(data)))
+	copy(f[headerSize:], data)
+	return f
+}
+
+func TestWriteRead(t *testing.T) {
+	var buf bytes.Buffer
+	for _, payload := range []string{`{"type":"PING"}`, `{"type":"PONG"}`} {
+		if err := Write(&buf, testMessage(payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, payload := range []string{`{"type":"PING"}`, `{"type":"PONG"}`} {
+		message, err := Read(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(message, testMessage(payload)) {
+			t.Errorf("read %v, want payload %s", message, payload)
+		}
+	}
+	if _, err := Read(&buf); err != io.EOF {
+		t.Errorf("got %v at the end, want io.EOF", err)
+	}
+}
+
+func TestReadShortReads(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testMessage(`{"type":"GET_STATUS","requestId":1}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	message, err := Read(iotest.OneByteReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if message.GetPayloadUtf8() != `{"type":"GET_STATUS","requestId":1}` {
+		t.Errorf("read payload %q", message.GetPayloadUtf8())
+	}
+
+	buf.Reset()
+	if err := WriteMessage(&buf, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ReadMessage(iotest.OneByteReader(&buf))
+	if err != nil {
+		t.Fatal(err)
+	} else if string(data) != "hello" {
+		t.Errorf("read %q", data)
+	}
+}
+
+func TestReadIncomplete(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Write(&buf, testMessage(`{"type":"PING"}`)); err != nil {
+		t.Fatal(err)
+	}
+	full := buf.Bytes()
+
+	tests := []struct {
+		name string
+		data []byte
+		err  error
+	}{
+		{"empty", nil, io.EOF},
+		{"truncated header", full[:2], IncompleteReadError},
+		{"header only", full[:headerSize], IncompleteReadError},
+		{"truncated body", full[:len(full)-1], IncompleteReadError},
+		{"empty frame", frame(nil), io.ErrNoProgress},
+	}
+	for _, test := range tests {
+		if _, err := Read(bytes.NewReader(test.data)); err != test.err {
+			t.Errorf("%s: Read got %v, want %v", test.name, err, test.err)
+		}
+		if _, err := ReadMessage(bytes.NewReader(test.data)); err != test.err {
+			t.Errorf("%s: ReadMessage got %v, want %v", test.name, err, test.err)
+		}
+	}
+}
+
+func TestMessageTooLarge(t *testing.T) {
+	header := make([]byte, headerSize)
+	binary.BigEndian.PutUint32(header, MaxMessageSize+1)
+	_, err := Read(bytes.NewReader(header))
+	if e, ok := err.(*MessageTooLargeError); !ok || e.Length != MaxMessageSize+1 {
+		t.Errorf("Read got %v", err)
+	}
+
+	var buf bytes.Buffer
+	err = WriteMessage(&buf, make([]byte, MaxMessageSize+1))
+	if _, ok := err.(*MessageTooLargeError); !ok {
+		t.Errorf("WriteMessage got %v", err)
+	} else if buf.Len() != 0 {
+		t.Errorf("WriteMessage wrote %d bytes", buf.Len())
+	}
+
+	err = Write(&buf, testMessage(string(make([]byte, MaxMessageSize))))
+	if _, ok := err.(*MessageTooLargeError); !ok {
+		t.Errorf("Write got %v", err)
+	}
+
+	if err := WriteMessage(&buf, make([]byte, MaxMessageSize)); err != nil {
+		t.Errorf("WriteMessage of the largest frame got %v", err)
+	}
+}
+
+func TestReadSkipsInvalidMessages(t *testing.T) {
+	var buf bytes.Buffer
+	buf.Write(frame([]byte{0xff, 0xff, 0xff}))
+	if err := Write(&buf, testMessage(`{"type":"PING"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Read(&buf); err == nil {
+		t.Fatal("read an invalid message")
+	} else if _, ok := err.(*InvalidMessageError); !ok {
+		t.Fatalf("got %v, want an InvalidMessageError", err)
+	}
+
+	message, err := Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	} else if message.GetPayloadUtf8() != `{"type":"PING"}` {
+		t.Errorf("read payload %q after the invalid frame", message.GetPayloadUtf8())
+	}
+}
@@ -0,0 +1,67 @@
+package playlist
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ravishi/go-cast/pkg/cast/serve"
+)
+
+// Dir lists the audio and video files in dir and its subdirectories,
+// in order by path, each directory before its subdirectories. Hidden files
+// are skipped.
+func Dir(dir string) ([]Entry, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return strings.ToLower(infos[i].Name()) < strings.ToLower(infos[j].Name())
+	})
+
+	var entries []Entry
+	var subdirs []string
+	for _, info := range infos {
+		name := info.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		p := filepath.Join(dir, name)
+		if info.IsDir() {
+			subdirs = append(subdirs, p)
+		} else if isMedia(name) {
+			entries = append(entries, Entry{Location: p})
+		}
+	}
+
+	for _, sub := range subdirs {
+		subEntries, err := Dir(sub)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, subEntries...)
+	}
+	return entries, nil
+}
+
+// isMedia tells media apart from the other files of media directories,
+// such as cover images, subtitles and playlists.
+func isMedia(name string) bool {
+	if FormatOf(name) != 0 {
+		return false
+	}
+	contentType := serve.ContentType(name)
+	return strings.HasPrefix(contentType, "audio/") || strings.HasPrefix(contentType, "video/")
+}
@@ -0,0 +1,149 @@
+package playlist
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseM3U parses both plain and extended M3U, where #EXTINF lines give the
+// duration and title of the entry that follows.
+func parseM3U(data []byte) ([]Entry, error) {
+	if bytes.Contains(data, []byte("#EXT-X-TARGETDURATION")) || bytes.Contains(data, []byte("#EXT-X-STREAM-INF")) {
+		return nil, IsHLS
+	}
+
+	var entries []Entry
+	var next Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info := strings.TrimPrefix(line, "#EXTINF:")
+			comma := strings.IndexByte(info, ',')
+			if comma < 0 {
+				continue
+			}
+			// The duration may be followed by attributes.
+			fields := strings.Fields(info[:comma])
+			if len(fields) > 0 {
+				next.Duration = seconds(fields[0])
+			}
+			next.Title = strings.TrimSpace(info[comma+1:])
+		case strings.HasPrefix(line, "#"):
+		default:
+			next.Location = line
+			entries = append(entries, next)
+			next = Entry{}
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// parsePLS parses the INI-like PLS format, whose entries are numbered
+// FileN, TitleN and LengthN keys.
+func parsePLS(data []byte) ([]Entry, error) {
+	byNumber := make(map[int]*Entry)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			continue
+		}
+		key, value := strings.ToLower(strings.TrimSpace(line[:eq])), strings.TrimSpace(line[eq+1:])
+
+		var field string
+		for _, prefix := range []string{"file", "title", "length"} {
+			if strings.HasPrefix(key, prefix) {
+				field = prefix
+			}
+		}
+		n, err := strconv.Atoi(strings.TrimPrefix(key, field))
+		if field == "" || err != nil {
+			continue
+		}
+
+		e, ok := byNumber[n]
+		if !ok {
+			e = &Entry{}
+			byNumber[n] = e
+		}
+		switch field {
+		case "file":
+			e.Location = value
+		case "title":
+			e.Title = value
+		case "length":
+			// Streams have a length of -1.
+			e.Duration = seconds(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	numbers := make([]int, 0, len(byNumber))
+	for n := range byNumber {
+		numbers = append(numbers, n)
+	}
+	sort.Ints(numbers)
+
+	var entries []Entry
+	for _, n := range numbers {
+		if e := byNumber[n]; e.Location != "" {
+			entries = append(entries, *e)
+		}
+	}
+	return entries, nil
+}
+
+type xspf struct {
+	Tracks []struct {
+		Locations []string `xml:"location"`
+		Title     string   `xml:"title"`
+		Creator   string   `xml:"creator"`
+		// Duration is in milliseconds.
+		Duration int64 `xml:"duration"`
+	} `xml:"trackList>track"`
+}
+
+func parseXSPF(data []byte) ([]Entry, error) {
+	var x xspf
+	if err := xml.Unmarshal(data, &x); err != nil {
+		return nil, err
+	}
+
+	var entries []Entry
+	for _, t := range x.Tracks {
+		if len(t.Locations) == 0 {
+			continue
+		}
+		title := t.Title
+		if title != "" && t.Creator != "" {
+			title = t.Creator + " - " + title
+		}
+		entries = append(entries, Entry{
+			Location: t.Locations[0],
+			Title:    title,
+			Duration: time.Duration(t.Duration) * time.Millisecond,
+		})
+	}
+	return entries, nil
+}
+
+func seconds(s string) time.Duration {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil || v <= 0 {
+		return 0
+	}
+	return time.Duration(v * float64(time.Second))
+}
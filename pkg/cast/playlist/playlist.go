@@ -0,0 +1,230 @@
+// Package playlist reads M3U, PLS and XSPF playlists, and directories of
+// media, into lists of entries to queue.
+package playlist
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var UnknownFormat = errors.New("Unknown playlist format")
+
+// LocalLocation is returned for entries of remote playlists that aren't
+// HTTP URLs, such as local files, which a remote playlist mustn't get us to
+// publish.
+var LocalLocation = errors.New("Remote playlist points at a local file")
+
+// IsHLS is returned for M3U8 files that are HLS streams rather than
+// playlists, which are played as they are.
+var IsHLS = errors.New("Playlist is an HLS stream")
+
+// maxSize bounds the playlists we read.
+const maxSize = 16 * 1024 * 1024
+
+type Format int
+
+const (
+	M3U Format = iota + 1
+	PLS
+	XSPF
+)
+
+// FormatOf returns the format of a playlist by its name, or by the path of
+// its URL, or zero if it isn't one.
+func FormatOf(name string) Format {
+	if u, err := url.Parse(name); err == nil && isURL(name) {
+		name = u.Path
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".m3u", ".m3u8":
+		return M3U
+	case ".pls":
+		return PLS
+	case ".xspf":
+		return XSPF
+	}
+	return 0
+}
+
+// Entry is an item of a playlist. Location is either a URL or the absolute
+// path of a local file.
+type Entry struct {
+	Location string
+	Title    string
+	// Duration is zero when unknown.
+	Duration time.Duration
+}
+
+// IsURL tells whether the entry is a URL rather than a local file.
+func (e Entry) IsURL() bool {
+	return isURL(e.Location)
+}
+
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https")
+}
+
+// Reader reads playlists, fetching remote ones with Client, which defaults
+// to http.DefaultClient, adding Header to its requests.
+type Reader struct {
+	Client *http.Client
+	Header http.Header
+}
+
+// Read reads the playlist, or directory, at location with the default
+// Reader.
+func Read(location string) ([]Entry, error) {
+	return (&Reader{}).Read(location)
+}
+
+// Read reads the playlist, or directory, at location, a path or an HTTP
+// URL.
+func (r *Reader) Read(location string) ([]Entry, error) {
+	if isURL(location) {
+		return r.fetch(location)
+	}
+
+	info, err := os.Stat(location)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return Dir(location)
+	}
+
+	format := FormatOf(location)
+	if format == 0 {
+		return nil, UnknownFormat
+	}
+	data, err := ioutil.ReadFile(location)
+	if err != nil {
+		return nil, err
+	}
+	abs, err := filepath.Abs(location)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, format, filepath.Dir(abs))
+}
+
+func (r *Reader) fetch(location string) ([]Entry, error) {
+	req, err := http.NewRequest("GET", location, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range r.Header {
+		req.Header[k] = v
+	}
+
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Failed to fetch %s: %s", location, resp.Status)
+	}
+
+	format := FormatOf(resp.Request.URL.Path)
+	if format == 0 {
+		return nil, UnknownFormat
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize))
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data, format, resp.Request.URL.String())
+}
+
+// Parse parses a playlist. Relative locations in it are resolved against
+// base, the URL or directory the playlist is in.
+func Parse(data []byte, format Format, base string) ([]Entry, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	var entries []Entry
+	var err error
+	switch format {
+	case M3U:
+		entries, err = parseM3U(data)
+	case PLS:
+		entries, err = parsePLS(data)
+	case XSPF:
+		entries, err = parseXSPF(data)
+	default:
+		return nil, UnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resolved := entries[:0]
+	for _, e := range entries {
+		location, err := resolve(e.Location, base)
+		if err != nil {
+			continue
+		}
+		e.Location = location
+		resolved = append(resolved, e)
+	}
+	return resolved, nil
+}
+
+// resolve makes location absolute. Locations are URLs, file URLs, or paths
+// relative to base, which is a URL itself for remote playlists. Remote
+// playlists can only point at URLs.
+func resolve(location, base string) (string, error) {
+	location = strings.TrimSpace(location)
+	if location == "" {
+		return "", errors.New("Empty location")
+	}
+	if isURL(location) {
+		return location, nil
+	}
+
+	if isURL(base) {
+		if strings.HasPrefix(strings.ToLower(location), "file:") || filepath.VolumeName(location) != "" || strings.HasPrefix(location, `\\`) {
+			return "", LocalLocation
+		}
+		baseURL, err := url.Parse(base)
+		if err != nil {
+			return "", err
+		}
+		u, err := baseURL.Parse(filepath.ToSlash(location))
+		if err != nil {
+			return "", err
+		} else if !isURL(u.String()) {
+			return "", LocalLocation
+		}
+		return u.String(), nil
+	}
+
+	if strings.HasPrefix(location, "file:") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return "", err
+		}
+		return filepath.FromSlash(u.Path), nil
+	}
+
+	// Playlists made on Windows have backslashes.
+	location = filepath.FromSlash(strings.Replace(location, "\\", "/", -1))
+	if !filepath.IsAbs(location) {
+		location = filepath.Join(base, location)
+	}
+	return location, nil
+}
@@ -0,0 +1,89 @@
+package playlist
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFormatOf(t *testing.T) {
+	tests := map[string]Format{
+		"list.m3u":                             M3U,
+		"/music/List.M3U8":                     M3U,
+		"radio.pls":                            PLS,
+		"list.xspf":                            XSPF,
+		"song.mp3":                             0,
+		"http://example.com/list.m3u?token=1":  M3U,
+		"https://example.com/radio.pls#top":    PLS,
+		"http://example.com/play?list=a.m3u":   0,
+		"list.m3u?token=1":                     0,
+		"http://example.com/dir/":              0,
+		"https://example.com/list.xspf?a=b&c=": XSPF,
+	}
+	for name, want := range tests {
+		if f := FormatOf(name); f != want {
+			t.Errorf("%s: got %d, want %d", name, f, want)
+		}
+	}
+}
+
+func TestReaderFetch(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte("#EXTM3U\n#EXTINF:61,Artist - Song\nsongs/1.mp3\nhttp://example.com/2.mp3\n"))
+	}))
+	defer s.Close()
+
+	location := s.URL + "/music/list.m3u?token=1"
+	r := &Reader{Header: http.Header{"Authorization": {"Bearer token"}}}
+	entries, err := r.Read(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{
+		{Location: s.URL + "/music/songs/1.mp3", Title: "Artist - Song", Duration: 61 * time.Second},
+		{Location: "http://example.com/2.mp3"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %+v, want %+v", entries, want)
+	}
+
+	if _, err := Read(location); err == nil {
+		t.Error("read a playlist without the headers it needs")
+	}
+}
+
+func TestRemoteLocalLocations(t *testing.T) {
+	base := "http://example.com/music/list.m3u"
+	for _, location := range []string{
+		"file:///home/me/.ssh/id_rsa",
+		"FILE:/etc/passwd",
+		`C:\Users\me\Music\1.mp3`,
+		`\\server\share\1.mp3`,
+		"ftp://example.com/1.mp3",
+	} {
+		if got, err := resolve(location, base); err != LocalLocation {
+			t.Errorf("%s: got %q, %v", location, got, err)
+		}
+	}
+
+	entries, err := Parse([]byte("#EXTM3U\nfile:///etc/passwd\n/songs/1.mp3\n2.mp3\n"), M3U, base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{{Location: "http://example.com/songs/1.mp3"}, {Location: "http://example.com/music/2.mp3"}}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("got %+v, want %+v", entries, want)
+	}
+
+	// Local playlists can still point at local files.
+	if got, err := resolve("file:///music/1.mp3", "/home/me"); err != nil || got != filepath.FromSlash("/music/1.mp3") {
+		t.Errorf("got %q, %v", got, err)
+	}
+}
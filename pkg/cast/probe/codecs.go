@@ -0,0 +1,127 @@
+package probe
+
+import "strings"
+
+// videoCodecs are the codecs, by family, that carry video.
+var videoCodecs = map[string]bool{
+	"avc1":   true,
+	"avc3":   true,
+	"hvc1":   true,
+	"hev1":   true,
+	"dvh1":   true,
+	"dvhe":   true,
+	"vp8":    true,
+	"vp09":   true,
+	"vp9":    true,
+	"av01":   true,
+	"mp4v":   true,
+	"mp2v":   true,
+	"theora": true,
+}
+
+// supportedCodecs are the codecs, by family, that every device plays.
+var supportedCodecs = map[string]bool{
+	"avc1":   true,
+	"avc3":   true,
+	"vp8":    true,
+	"vp09":   true,
+	"vp9":    true,
+	"mp4a":   true,
+	"mp3":    true,
+	"opus":   true,
+	"vorbis": true,
+	"flac":   true,
+	"lpcm":   true,
+	// Subtitles and captions.
+	"wvtt": true,
+	"stpp": true,
+	"tx3g": true,
+}
+
+// limitedCodecs are the codecs only some devices play, and why.
+var limitedCodecs = map[string]string{
+	"hvc1": "HEVC video plays only on devices that support 4K, such as the Chromecast Ultra",
+	"hev1": "HEVC video plays only on devices that support 4K, such as the Chromecast Ultra",
+	"dvh1": "Dolby Vision video plays only on devices that support it",
+	"dvhe": "Dolby Vision video plays only on devices that support it",
+	"av01": "AV1 video plays only on recent devices",
+	"ac-3": "Dolby Digital audio plays only when passed through to a receiver that decodes it",
+	"ec-3": "Dolby Digital Plus audio plays only when passed through to a receiver that decodes it",
+}
+
+// unsupportedCodecs are the codecs no device plays.
+var unsupportedCodecs = map[string]string{
+	"dtsc":   "DTS audio isn't supported",
+	"mlpa":   "Dolby TrueHD audio isn't supported",
+	"mp4v":   "MPEG-4 Part 2 video isn't supported",
+	"mp2v":   "MPEG-2 video isn't supported",
+	"theora": "Theora video isn't supported",
+}
+
+// unsupportedContainers are the content types devices either don't play,
+// or only play by chance.
+var unsupportedContainers = map[string]string{
+	"video/x-matroska": "Matroska isn't officially supported, it plays only if its codecs are",
+	"audio/x-matroska": "Matroska isn't officially supported, it plays only if its codecs are",
+	"video/x-msvideo":  "AVI isn't supported",
+	"video/quicktime":  "QuickTime isn't officially supported, it plays only if its codecs are",
+	"video/x-ms-wmv":   "Windows Media isn't supported",
+	"audio/x-ms-wma":   "Windows Media isn't supported",
+	"video/x-flv":      "Flash video isn't supported",
+}
+
+// codecFamily returns the part of an RFC 6381 codec before its profile.
+func codecFamily(codec string) string {
+	if i := strings.IndexByte(codec, '.'); i >= 0 {
+		codec = codec[:i]
+	}
+	return strings.ToLower(codec)
+}
+
+func hasVideo(codecs []string) bool {
+	for _, c := range codecs {
+		if videoCodecs[codecFamily(c)] {
+			return true
+		}
+	}
+	return false
+}
+
+func checkContainer(r *Result) {
+	if reason, ok := unsupportedContainers[r.ContentType]; ok {
+		r.warn("%s", reason)
+	}
+}
+
+// checkCodecs removes duplicate codecs and warns about the ones that may
+// not play.
+func checkCodecs(r *Result) {
+	seen := make(map[string]bool)
+	warned := make(map[string]bool)
+	codecs := r.Codecs[:0]
+	for _, c := range r.Codecs {
+		c = strings.TrimSpace(c)
+		if c == "" || seen[c] {
+			continue
+		}
+		seen[c] = true
+		codecs = append(codecs, c)
+
+		family := codecFamily(c)
+		if supportedCodecs[family] {
+			continue
+		}
+		reason, ok := unsupportedCodecs[family]
+		if !ok {
+			reason, ok = limitedCodecs[family]
+		}
+		if !ok {
+			reason = "The " + c + " codec may not be supported"
+		}
+		if !warned[reason] {
+			warned[reason] = true
+			r.warn("%s", reason)
+		}
+	}
+	r.Codecs = codecs
+}
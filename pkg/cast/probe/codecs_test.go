@@ -0,0 +1,22 @@
+package probe
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCheckCodecs(t *testing.T) {
+	r := &Result{Codecs: []string{"avc1.64001f", " avc1.64001f", "", "mp4a.40.2", "theora", "hev1.1", "hvc1.2", "dtsc"}}
+	checkCodecs(r)
+	if want := []string{"avc1.64001f", "mp4a.40.2", "theora", "hev1.1", "hvc1.2", "dtsc"}; !reflect.DeepEqual(r.Codecs, want) {
+		t.Errorf("got %q, want %q", r.Codecs, want)
+	}
+	want := []string{
+		"Theora video isn't supported",
+		"HEVC video plays only on devices that support 4K, such as the Chromecast Ultra",
+		"DTS audio isn't supported",
+	}
+	if !reflect.DeepEqual(r.Warnings, want) {
+		t.Errorf("got %q, want %q", r.Warnings, want)
+	}
+}
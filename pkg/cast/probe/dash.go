@@ -0,0 +1,79 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/xml"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+)
+
+func isDASH(head []byte, contentType string) bool {
+	if baseType(contentType) == "application/dash+xml" {
+		return true
+	}
+	if len(head) > 1024 {
+		head = head[:1024]
+	}
+	return bytes.Contains(head, []byte("<MPD"))
+}
+
+type mpd struct {
+	Type     string `xml:"type,attr"`
+	Duration string `xml:"mediaPresentationDuration,attr"`
+	Periods  []struct {
+		AdaptationSets []struct {
+			Codecs          string `xml:"codecs,attr"`
+			Representations []struct {
+				Codecs string `xml:"codecs,attr"`
+			} `xml:"Representation"`
+		} `xml:"AdaptationSet"`
+	} `xml:"Period"`
+}
+
+func probeDASH(body []byte, result *Result) error {
+	var m mpd
+	if err := xml.Unmarshal(body, &m); err != nil {
+		return err
+	}
+
+	if m.Type == "dynamic" {
+		result.StreamType = ctrl.StreamTypeLive
+	} else {
+		result.Duration = parseISODuration(m.Duration)
+	}
+
+	for _, period := range m.Periods {
+		for _, set := range period.AdaptationSets {
+			if set.Codecs != "" {
+				result.Codecs = append(result.Codecs, set.Codecs)
+			}
+			for _, r := range set.Representations {
+				if r.Codecs != "" {
+					result.Codecs = append(result.Codecs, r.Codecs)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+var isoDuration = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseISODuration parses the durations of manifests, such as PT1H2M3.5S,
+// returning zero for the ones it can't.
+func parseISODuration(s string) time.Duration {
+	m := isoDuration.FindStringSubmatch(s)
+	if m == nil {
+		return 0
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if v, err := strconv.ParseFloat(m[i+1], 64); err == nil {
+			d += time.Duration(v * float64(unit))
+		}
+	}
+	return d
+}
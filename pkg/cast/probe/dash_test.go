@@ -0,0 +1,35 @@
+package probe
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseISODuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"PT1H2M3.5S": time.Hour + 2*time.Minute + 3500*time.Millisecond,
+		"PT90S":      90 * time.Second,
+		"P1DT1M":     24*time.Hour + time.Minute,
+		"PT0.25S":    250 * time.Millisecond,
+		"P1Y":        0,
+		"":           0,
+		"1:00:00":    0,
+	}
+	for s, want := range tests {
+		if d := parseISODuration(s); d != want {
+			t.Errorf("%q: got %s, want %s", s, d, want)
+		}
+	}
+}
+
+func TestIsDASH(t *testing.T) {
+	if !isDASH(nil, "application/dash+xml; charset=utf-8") {
+		t.Error("the content type isn't DASH")
+	}
+	if !isDASH([]byte(staticMPD), "text/xml") {
+		t.Error("the manifest isn't DASH")
+	}
+	if isDASH([]byte("<html><body>MPD</body></html>"), "text/html") {
+		t.Error("HTML is DASH")
+	}
+}
@@ -0,0 +1,214 @@
+package probe
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+)
+
+var EmptyPlaylist = errors.New("Empty HLS playlist")
+
+// segmentSniffSize is how much of a segment we fetch to recognize it.
+const segmentSniffSize = 4 * 1024
+
+func isHLS(head []byte, contentType string) bool {
+	switch baseType(contentType) {
+	case "application/x-mpegurl", "application/vnd.apple.mpegurl", "audio/mpegurl", "audio/x-mpegurl":
+		// Plain M3U playlists are served as these too.
+		return bytes.Contains(head, []byte("#EXT-X-"))
+	}
+	head = bytes.TrimPrefix(head, []byte("\xef\xbb\xbf"))
+	return bytes.HasPrefix(bytes.TrimSpace(head), []byte("#EXTM3U")) && bytes.Contains(head, []byte("#EXT-X-"))
+}
+
+// playlist is an HLS playlist, either a master playlist, with variants, or
+// a media playlist, with segments.
+type playlist struct {
+	variants []variant
+	segments []*url.URL
+	// mapped tells whether segments share an initialization section, as
+	// fragmented MP4 ones do.
+	mapped   bool
+	ended    bool
+	duration time.Duration
+}
+
+type variant struct {
+	url    *url.URL
+	codecs []string
+}
+
+func parsePlaylist(base *url.URL, body []byte) (*playlist, error) {
+	p := &playlist{}
+	var streamInf map[string]string
+
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	scanner.Buffer(nil, maxManifestSize)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-STREAM-INF:"):
+			streamInf = parseAttributes(strings.TrimPrefix(line, "#EXT-X-STREAM-INF:"))
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			p.mapped = true
+		case line == "#EXT-X-ENDLIST":
+			p.ended = true
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value := strings.TrimPrefix(line, "#EXTINF:")
+			if i := strings.IndexByte(value, ','); i >= 0 {
+				value = value[:i]
+			}
+			if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+				p.duration += time.Duration(seconds * float64(time.Second))
+			}
+		case strings.HasPrefix(line, "#"):
+		default:
+			u, err := base.Parse(line)
+			if err != nil {
+				return nil, err
+			}
+			if streamInf != nil {
+				v := variant{url: u}
+				if codecs := streamInf["CODECS"]; codecs != "" {
+					v.codecs = strings.Split(codecs, ",")
+				}
+				p.variants = append(p.variants, v)
+				streamInf = nil
+			} else {
+				p.segments = append(p.segments, u)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(p.variants) == 0 && len(p.segments) == 0 {
+		return nil, EmptyPlaylist
+	}
+	return p, nil
+}
+
+// parseAttributes parses the attribute list of a tag, whose values may be
+// quoted strings with commas in them.
+func parseAttributes(s string) map[string]string {
+	attrs := make(map[string]string)
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else if comma := strings.IndexByte(s, ','); comma >= 0 {
+			value, s = s[:comma], s[comma:]
+		} else {
+			value, s = s, ""
+		}
+		attrs[key] = value
+		s = strings.TrimPrefix(s, ",")
+	}
+	return attrs
+}
+
+// probeHLS reads a playlist and, if it's a master playlist, its first
+// variant, which is the one players start with.
+func (p *Prober) probeHLS(ctx context.Context, base *url.URL, body []byte, result *Result) error {
+	list, err := parsePlaylist(base, body)
+	if err != nil {
+		return err
+	}
+
+	if len(list.variants) > 0 {
+		result.Codecs = append(result.Codecs, list.variants[0].codecs...)
+		body, err := p.get(ctx, list.variants[0].url, "", maxManifestSize)
+		if err != nil {
+			return err
+		}
+		list, err = parsePlaylist(list.variants[0].url, body)
+		if err != nil {
+			return err
+		}
+		if len(list.variants) > 0 {
+			return errors.New("HLS master playlist points to another master playlist")
+		}
+	}
+
+	if list.ended {
+		result.Duration = list.duration
+	} else {
+		result.StreamType = ctrl.StreamTypeLive
+	}
+
+	format := ctrl.HLSSegmentFormatTS
+	if list.mapped {
+		format = ctrl.HLSSegmentFormatFMP4
+	} else if f, ok := segmentFormats[strings.ToLower(path.Ext(list.segments[0].Path))]; ok {
+		format = f
+	} else if head, err := p.get(ctx, list.segments[0], "bytes=0-4095", segmentSniffSize); err == nil {
+		if f, ok := sniffSegment(head); ok {
+			format = f
+		}
+	}
+
+	result.HLSSegmentFormat = format
+	// Without codecs, assume that there's video, unless the segments are
+	// audio only.
+	video := hasVideo(result.Codecs) || len(result.Codecs) == 0
+	if video && format == ctrl.HLSSegmentFormatTS {
+		result.HLSVideoSegmentFormat = ctrl.HLSVideoSegmentFormatMPEG2TS
+	} else if video && format == ctrl.HLSSegmentFormatFMP4 {
+		result.HLSVideoSegmentFormat = ctrl.HLSVideoSegmentFormatFMP4
+	}
+	return nil
+}
+
+// segmentFormats maps the extensions of segments to their format.
+var segmentFormats = map[string]ctrl.HLSSegmentFormat{
+	".ts":   ctrl.HLSSegmentFormatTS,
+	".aac":  ctrl.HLSSegmentFormatAAC,
+	".mp3":  ctrl.HLSSegmentFormatMP3,
+	".ac3":  ctrl.HLSSegmentFormatAC3,
+	".ec3":  ctrl.HLSSegmentFormatEAC3,
+	".m4s":  ctrl.HLSSegmentFormatFMP4,
+	".mp4":  ctrl.HLSSegmentFormatFMP4,
+	".m4a":  ctrl.HLSSegmentFormatFMP4,
+	".m4v":  ctrl.HLSSegmentFormatFMP4,
+	".cmfa": ctrl.HLSSegmentFormatFMP4,
+	".cmfv": ctrl.HLSSegmentFormatFMP4,
+}
+
+func sniffSegment(b []byte) (ctrl.HLSSegmentFormat, bool) {
+	if len(b) >= 8 {
+		switch string(b[4:8]) {
+		case "ftyp", "styp", "moof", "sidx":
+			return ctrl.HLSSegmentFormatFMP4, true
+		}
+	}
+	switch Sniff(b) {
+	case "video/mp2t":
+		return ctrl.HLSSegmentFormatTS, true
+	case "audio/aac":
+		return ctrl.HLSSegmentFormatAAC, true
+	case "audio/mpeg":
+		return ctrl.HLSSegmentFormatMP3, true
+	}
+	return "", false
+}
@@ -0,0 +1,117 @@
+package probe
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+)
+
+func TestParseAttributes(t *testing.T) {
+	tests := []struct {
+		s    string
+		want map[string]string
+	}{
+		{`BANDWIDTH=1280000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720`,
+			map[string]string{"BANDWIDTH": "1280000", "CODECS": "avc1.64001f,mp4a.40.2", "RESOLUTION": "1280x720"}},
+		{`URI="init.mp4"`, map[string]string{"URI": "init.mp4"}},
+		{`NAME="unterminated`, map[string]string{"NAME": "unterminated"}},
+		{`A=1,B="",C=3`, map[string]string{"A": "1", "B": "", "C": "3"}},
+		{"", map[string]string{}},
+		{"garbage", map[string]string{}},
+	}
+	for _, test := range tests {
+		if got := parseAttributes(test.s); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.s, got, test.want)
+		}
+	}
+}
+
+func TestParsePlaylist(t *testing.T) {
+	base, _ := url.Parse("http://example.com/hls/master.m3u8?token=1")
+
+	master, err := parsePlaylist(base, []byte(masterPlaylist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(master.variants) != 2 || len(master.segments) != 0 {
+		t.Fatalf("got %d variants and %d segments", len(master.variants), len(master.segments))
+	}
+	if v := master.variants[0]; v.url.String() != "http://example.com/hls/media/720.m3u8" ||
+		!reflect.DeepEqual(v.codecs, []string{"avc1.64001f", "mp4a.40.2"}) {
+		t.Errorf("got %s with %v", v.url, v.codecs)
+	}
+
+	media, err := parsePlaylist(base, []byte(vodPlaylist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !media.ended || media.mapped || media.duration != 19500*time.Millisecond {
+		t.Errorf("got ended %v, mapped %v, lasting %s", media.ended, media.mapped, media.duration)
+	}
+	if len(media.segments) != 2 || media.segments[1].String() != "http://example.com/hls/1.ts" {
+		t.Errorf("got segments %v", media.segments)
+	}
+
+	live, err := parsePlaylist(base, []byte(livePlaylist))
+	if err != nil {
+		t.Fatal(err)
+	} else if live.ended {
+		t.Error("a live playlist ended")
+	}
+
+	fmp4, err := parsePlaylist(base, []byte("\xef\xbb\xbf"+fmp4Playlist))
+	if err != nil {
+		t.Fatal(err)
+	} else if !fmp4.mapped {
+		t.Error("fragmented MP4 segments aren't mapped")
+	}
+
+	if _, err := parsePlaylist(base, []byte("#EXTM3U\n#EXT-X-ENDLIST\n")); err != EmptyPlaylist {
+		t.Errorf("got %v, want %v", err, EmptyPlaylist)
+	}
+	if _, err := parsePlaylist(base, []byte("#EXTM3U\n#EXTINF:1,\nhttp://[::1\n")); err == nil {
+		t.Error("parsed a bad segment URL")
+	}
+}
+
+func TestIsHLS(t *testing.T) {
+	tests := []struct {
+		head, contentType string
+		want              bool
+	}{
+		{vodPlaylist, "", true},
+		{"\xef\xbb\xbf" + vodPlaylist, "text/plain", true},
+		{"#EXTM3U\n#EXTINF:10,Song\nsong.mp3\n", "", false},
+		{"#EXT-X-TARGETDURATION:10\n0.ts\n", "application/vnd.apple.mpegurl", true},
+		{"#EXTM3U\n#EXTINF:10,Song\nsong.mp3\n", "audio/x-mpegurl; charset=utf-8", false},
+		{"<MPD/>", "", false},
+	}
+	for _, test := range tests {
+		if got := isHLS([]byte(test.head), test.contentType); got != test.want {
+			t.Errorf("%q as %q: got %v", test.head, test.contentType, got)
+		}
+	}
+}
+
+func TestSniffSegment(t *testing.T) {
+	tests := []struct {
+		head   []byte
+		want   ctrl.HLSSegmentFormat
+		wantOK bool
+	}{
+		{[]byte("\x00\x00\x00\x18styp"), ctrl.HLSSegmentFormatFMP4, true},
+		{mp4Head, ctrl.HLSSegmentFormatFMP4, true},
+		{tsHead, ctrl.HLSSegmentFormatTS, true},
+		{[]byte{0xff, 0xf1, 0x50, 0x80}, ctrl.HLSSegmentFormatAAC, true},
+		{[]byte("ID3\x04\x00\x00\x00\x00\x00\x00\xff\xfb\x90\x00"), ctrl.HLSSegmentFormatMP3, true},
+		{[]byte("<html>"), "", false},
+	}
+	for _, test := range tests {
+		if got, ok := sniffSegment(test.head); got != test.want || ok != test.wantOK {
+			t.Errorf("%q: got %q, %v", test.head, got, ok)
+		}
+	}
+}
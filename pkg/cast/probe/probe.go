@@ -0,0 +1,197 @@
+// Package probe finds out what a media URL serves, so that it can be loaded
+// with the right content and stream types.
+package probe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+	"github.com/ravishi/go-cast/pkg/cast/serve"
+)
+
+// sniffSize is how much of the media we fetch to recognize it.
+const sniffSize = 64 * 1024
+
+// maxManifestSize bounds the HLS playlists and DASH manifests we read.
+const maxManifestSize = 4 * 1024 * 1024
+
+// Result is what we learned about a URL.
+type Result struct {
+	// URL is where the media ended up being, after redirects.
+	URL         string
+	ContentType string
+	StreamType  ctrl.StreamType
+	// Duration is zero when unknown, as it is for live streams.
+	Duration time.Duration
+	// The segment formats are only set for HLS streams.
+	HLSSegmentFormat      ctrl.HLSSegmentFormat
+	HLSVideoSegmentFormat ctrl.HLSVideoSegmentFormat
+	// Codecs are the RFC 6381 codecs of the media, as far as we could tell.
+	Codecs []string
+	// Warnings explain why the device may fail to play the media.
+	Warnings []string
+}
+
+func (r *Result) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Prober probes URLs with Client, which defaults to http.DefaultClient,
+// adding Header to its requests.
+type Prober struct {
+	Client *http.Client
+	Header http.Header
+}
+
+// Probe probes rawURL with the default Prober.
+func Probe(ctx context.Context, rawURL string) (*Result, error) {
+	return (&Prober{}).Probe(ctx, rawURL)
+}
+
+// Probe fetches the beginning of the media at rawURL and, for HLS and DASH,
+// its manifests. It fails only when the media can't be fetched at all.
+func (p *Prober) Probe(ctx context.Context, rawURL string) (*Result, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{URL: u.String(), StreamType: ctrl.StreamTypeBuffered}
+
+	// Some servers reject HEAD, so we only use it as a hint.
+	var headType string
+	if resp, err := p.do(ctx, "HEAD", u, ""); err == nil {
+		resp.Body.Close()
+		if resp.StatusCode < 300 {
+			headType = resp.Header.Get("Content-Type")
+		}
+	}
+
+	resp, err := p.do(ctx, "GET", u, fmt.Sprintf("bytes=0-%d", sniffSize-1))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Failed to fetch %s: %s", u, resp.Status)
+	}
+	result.URL = resp.Request.URL.String()
+
+	head, err := ioutil.ReadAll(io.LimitReader(resp.Body, sniffSize))
+	if err != nil && len(head) == 0 {
+		return nil, err
+	}
+
+	serverType := resp.Header.Get("Content-Type")
+	if serverType == "" {
+		serverType = headType
+	}
+
+	switch {
+	case isHLS(head, serverType):
+		result.ContentType = "application/x-mpegURL"
+		body, err := p.rest(resp, head)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.probeHLS(ctx, resp.Request.URL, body, result); err != nil {
+			return nil, err
+		}
+	case isDASH(head, serverType):
+		result.ContentType = "application/dash+xml"
+		body, err := p.rest(resp, head)
+		if err != nil {
+			return nil, err
+		}
+		if err := probeDASH(body, result); err != nil {
+			return nil, err
+		}
+	default:
+		result.ContentType = Sniff(head)
+		if result.ContentType == "" {
+			result.ContentType = baseType(serverType)
+		}
+		if result.ContentType == "" || result.ContentType == "application/octet-stream" {
+			result.ContentType = serve.ContentType(resp.Request.URL.Path)
+		}
+		if isLive(resp) {
+			result.StreamType = ctrl.StreamTypeLive
+		} else if resp.StatusCode != http.StatusPartialContent {
+			result.warn("The server doesn't support range requests, so seeking won't work")
+		}
+		result.Codecs = append(result.Codecs, sniffCodecs(head)...)
+		checkContainer(result)
+	}
+
+	checkCodecs(result)
+	return result, nil
+}
+
+func (p *Prober) do(ctx context.Context, method string, u *url.URL, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	for k, v := range p.Header {
+		req.Header[k] = v
+	}
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+
+	client := p.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// get fetches a manifest, or the start of a segment when byteRange is set.
+func (p *Prober) get(ctx context.Context, u *url.URL, byteRange string, limit int64) ([]byte, error) {
+	resp, err := p.do(ctx, "GET", u, byteRange)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("Failed to fetch %s: %s", u, resp.Status)
+	}
+	return ioutil.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// rest reads the remainder of a manifest whose head we already have.
+func (p *Prober) rest(resp *http.Response, head []byte) ([]byte, error) {
+	rest, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxManifestSize-int64(len(head))))
+	if err != nil {
+		return nil, err
+	}
+	return append(head, rest...), nil
+}
+
+// isLive tells endless streams, such as internet radios, apart from files:
+// they have no length and can't be ranged.
+func isLive(resp *http.Response) bool {
+	if resp.Header.Get("Icy-Metaint") != "" || resp.Header.Get("Icy-Name") != "" {
+		return true
+	}
+	return resp.StatusCode != http.StatusPartialContent &&
+		resp.ContentLength < 0 &&
+		resp.Header.Get("Accept-Ranges") != "bytes"
+}
+
+func baseType(contentType string) string {
+	t, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(t)
+}
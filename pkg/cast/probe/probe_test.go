@@ -0,0 +1,224 @@
+package probe
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ravishi/go-cast/pkg/cast/ctrl"
+)
+
+// mp4Head is the start of an MP4 file whose first track is H.264 video.
+var mp4Head = []byte("\x00\x00\x00\x18ftypisom\x00\x00\x00\x00isomavc1" +
+	"\x00\x00\x00\x20stsd\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x10avc1")
+
+// tsHead is the start of an MPEG-2 transport stream.
+var tsHead = bytes.Repeat(append([]byte{0x47}, make([]byte, 187)...), 3)
+
+const (
+	masterPlaylist = "#EXTM3U\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=1280000,CODECS="avc1.64001f,mp4a.40.2",RESOLUTION=1280x720` + "\n" +
+		"media/720.m3u8\n" +
+		`#EXT-X-STREAM-INF:BANDWIDTH=640000,CODECS="avc1.42e01e,mp4a.40.2"` + "\n" +
+		"media/360.m3u8\n"
+	vodPlaylist = "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:10\n" +
+		"#EXTINF:10.0,\n0.ts\n#EXTINF:9.5,\n1.ts\n#EXT-X-ENDLIST\n"
+	livePlaylist = "#EXTM3U\n#EXT-X-TARGETDURATION:6\n#EXT-X-MEDIA-SEQUENCE:42\n" +
+		"#EXTINF:6,\nsegment?n=42\n#EXTINF:6,\nsegment?n=43\n"
+	fmp4Playlist = "#EXTM3U\n#EXT-X-MAP:URI=\"init.mp4\"\n#EXTINF:4,\n0.m4s\n#EXT-X-ENDLIST\n"
+	staticMPD    = `<?xml version="1.0"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" type="static" mediaPresentationDuration="PT1M30.5S">
+  <Period>
+    <AdaptationSet mimeType="video/mp4" codecs="avc1.640028">
+      <Representation id="1" bandwidth="800000"/>
+    </AdaptationSet>
+    <AdaptationSet mimeType="audio/mp4">
+      <Representation id="2" codecs="mp4a.40.2"/>
+      <Representation id="3" codecs="ec-3"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+)
+
+// testServer serves the files, supporting range requests, along with a few
+// handlers that don't.
+func testServer(t *testing.T, files map[string]string) *httptest.Server {
+	mux := http.NewServeMux()
+	for name, content := range files {
+		content := content
+		mux.HandleFunc(name, func(w http.ResponseWriter, r *http.Request) {
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader(content))
+		})
+	}
+	mux.HandleFunc("/norange.mp4", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "video/mp4")
+		w.Header().Set("Content-Length", "1000000")
+		w.Write(mp4Head)
+		w.Write(make([]byte, 1000000-len(mp4Head)))
+	})
+	mux.HandleFunc("/radio", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Header().Set("Icy-Name", "Radio")
+		w.Write([]byte{0xff, 0xfb, 0x90, 0x00})
+		w.(http.Flusher).Flush()
+	})
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/movie.mp4", http.StatusFound)
+	})
+	mux.HandleFunc("/private.mp4", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(mp4Head))
+	})
+	s := httptest.NewServer(mux)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestProbe(t *testing.T) {
+	s := testServer(t, map[string]string{
+		"/movie.mp4":            string(mp4Head),
+		"/master.m3u8":          masterPlaylist,
+		"/media/720.m3u8":       vodPlaylist,
+		"/live.m3u8":            livePlaylist,
+		"/segment":              string(tsHead),
+		"/manifest.mpd":         staticMPD,
+		"/nested.m3u8":          "#EXTM3U\n#EXT-X-STREAM-INF:BANDWIDTH=1\nmaster.m3u8\n",
+		"/empty.m3u8":           "#EXTM3U\n#EXT-X-VERSION:3\n",
+		"/unknown":              "\x00\x01\x02\x03",
+		"/live.mpd":             `<MPD type="dynamic"><Period/></MPD>`,
+		"/codecs/master.m3u8":   "#EXTM3U\n#EXT-X-STREAM-INF:CODECS=\"mp4a.40.2\"\naudio.m3u8\n",
+		"/codecs/audio.m3u8":    fmp4Playlist,
+		"/codecs/unknown.m3u8":  "#EXTM3U\n#EXT-X-STREAM-INF:CODECS=\"hvc1.1.6.L93.B0,xyz1\"\nmedia.m3u8\n",
+		"/codecs/media.m3u8":    vodPlaylist,
+		"/plain.m3u8":           "#EXTM3U\n#EXTINF:10,Song\nsong.mp3\n",
+		"/audio/no-type.xyz":    "\xff\xf1\x50\x80",
+		"/video/no-type.webm":   "\x1a\x45\xdf\xa3",
+		"/video/sniffed-ts.bin": string(tsHead),
+	})
+
+	tests := []struct {
+		path string
+		want Result
+	}{
+		{"/movie.mp4", Result{ContentType: "video/mp4", StreamType: ctrl.StreamTypeBuffered, Codecs: []string{"avc1"}}},
+		{"/norange.mp4", Result{ContentType: "video/mp4", StreamType: ctrl.StreamTypeBuffered, Codecs: []string{"avc1"},
+			Warnings: []string{"The server doesn't support range requests, so seeking won't work"}}},
+		{"/radio", Result{ContentType: "audio/mpeg", StreamType: ctrl.StreamTypeLive}},
+		{"/master.m3u8", Result{
+			ContentType:           "application/x-mpegURL",
+			StreamType:            ctrl.StreamTypeBuffered,
+			Duration:              19500 * time.Millisecond,
+			HLSSegmentFormat:      ctrl.HLSSegmentFormatTS,
+			HLSVideoSegmentFormat: ctrl.HLSVideoSegmentFormatMPEG2TS,
+			Codecs:                []string{"avc1.64001f", "mp4a.40.2"},
+		}},
+		{"/live.m3u8", Result{
+			ContentType:           "application/x-mpegURL",
+			StreamType:            ctrl.StreamTypeLive,
+			HLSSegmentFormat:      ctrl.HLSSegmentFormatTS,
+			HLSVideoSegmentFormat: ctrl.HLSVideoSegmentFormatMPEG2TS,
+		}},
+		{"/codecs/master.m3u8", Result{
+			ContentType:      "application/x-mpegURL",
+			StreamType:       ctrl.StreamTypeBuffered,
+			Duration:         4 * time.Second,
+			HLSSegmentFormat: ctrl.HLSSegmentFormatFMP4,
+			Codecs:           []string{"mp4a.40.2"},
+		}},
+		{"/codecs/unknown.m3u8", Result{
+			ContentType:           "application/x-mpegURL",
+			StreamType:            ctrl.StreamTypeBuffered,
+			Duration:              19500 * time.Millisecond,
+			HLSSegmentFormat:      ctrl.HLSSegmentFormatTS,
+			HLSVideoSegmentFormat: ctrl.HLSVideoSegmentFormatMPEG2TS,
+			Codecs:                []string{"hvc1.1.6.L93.B0", "xyz1"},
+			Warnings: []string{
+				"HEVC video plays only on devices that support 4K, such as the Chromecast Ultra",
+				"The xyz1 codec may not be supported",
+			},
+		}},
+		{"/manifest.mpd", Result{
+			ContentType: "application/dash+xml",
+			StreamType:  ctrl.StreamTypeBuffered,
+			Duration:    90500 * time.Millisecond,
+			Codecs:      []string{"avc1.640028", "mp4a.40.2", "ec-3"},
+			Warnings:    []string{"Dolby Digital Plus audio plays only when passed through to a receiver that decodes it"},
+		}},
+		{"/live.mpd", Result{ContentType: "application/dash+xml", StreamType: ctrl.StreamTypeLive}},
+		// Plain M3U playlists aren't HLS.
+		{"/plain.m3u8", Result{ContentType: "text/plain", StreamType: ctrl.StreamTypeBuffered}},
+		{"/audio/no-type.xyz", Result{ContentType: "audio/aac", StreamType: ctrl.StreamTypeBuffered}},
+		{"/video/no-type.webm", Result{ContentType: "video/x-matroska", StreamType: ctrl.StreamTypeBuffered,
+			Warnings: []string{"Matroska isn't officially supported, it plays only if its codecs are"}}},
+		{"/video/sniffed-ts.bin", Result{ContentType: "video/mp2t", StreamType: ctrl.StreamTypeBuffered}},
+		{"/redirect", Result{ContentType: "video/mp4", StreamType: ctrl.StreamTypeBuffered, Codecs: []string{"avc1"}}},
+	}
+	for _, test := range tests {
+		result, err := Probe(context.Background(), s.URL+test.path)
+		if err != nil {
+			t.Errorf("%s: %s", test.path, err)
+			continue
+		}
+		want := test.want
+		want.URL = s.URL + test.path
+		if test.path == "/redirect" {
+			want.URL = s.URL + "/movie.mp4"
+		}
+		if !reflect.DeepEqual(*result, want) {
+			t.Errorf("%s: got %+v, want %+v", test.path, *result, want)
+		}
+	}
+
+	for _, path := range []string{"/missing", "/nested.m3u8", "/empty.m3u8", "/private.mp4"} {
+		if _, err := Probe(context.Background(), s.URL+path); err == nil {
+			t.Errorf("%s: probed it", path)
+		}
+	}
+}
+
+func TestProberHeader(t *testing.T) {
+	s := testServer(t, nil)
+	p := &Prober{Header: http.Header{"Authorization": {"Bearer token"}}}
+	result, err := p.Probe(context.Background(), s.URL+"/private.mp4")
+	if err != nil {
+		t.Fatal(err)
+	} else if result.ContentType != "video/mp4" {
+		t.Errorf("got %s", result.ContentType)
+	}
+}
+
+func TestIsLive(t *testing.T) {
+	tests := []struct {
+		name string
+		resp *http.Response
+		want bool
+	}{
+		{"ranged", &http.Response{StatusCode: http.StatusPartialContent, ContentLength: -1}, false},
+		{"sized", &http.Response{StatusCode: http.StatusOK, ContentLength: 100}, false},
+		{"accepts ranges", &http.Response{StatusCode: http.StatusOK, ContentLength: -1,
+			Header: http.Header{"Accept-Ranges": {"bytes"}}}, false},
+		{"endless", &http.Response{StatusCode: http.StatusOK, ContentLength: -1}, true},
+		{"icecast", &http.Response{StatusCode: http.StatusOK, ContentLength: 100,
+			Header: http.Header{"Icy-Metaint": {"16000"}}}, true},
+	}
+	for _, test := range tests {
+		if test.resp.Header == nil {
+			test.resp.Header = http.Header{}
+		}
+		if got := isLive(test.resp); got != test.want {
+			t.Errorf("%s: got %v", test.name, got)
+		}
+	}
+}
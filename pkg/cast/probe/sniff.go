@@ -0,0 +1,195 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+)
+
+// Sniff tells the content type of media from its first bytes, or returns an
+// empty string.
+func Sniff(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte("\xff\xd8\xff")):
+		return "image/jpeg"
+	case bytes.HasPrefix(b, []byte("\x89PNG")):
+		return "image/png"
+	case bytes.HasPrefix(b, []byte("GIF8")):
+		return "image/gif"
+	case len(b) >= 12 && string(b[4:8]) == "ftyp":
+		return sniffMP4(b)
+	case bytes.HasPrefix(b, []byte("\x1a\x45\xdf\xa3")):
+		return sniffMatroska(b)
+	case bytes.HasPrefix(b, []byte("fLaC")):
+		return "audio/flac"
+	case bytes.HasPrefix(b, []byte("OggS")):
+		if bytes.Contains(b, []byte("\x80theora")) {
+			return "video/ogg"
+		}
+		return "audio/ogg"
+	case len(b) >= 12 && string(b[:4]) == "RIFF":
+		switch string(b[8:12]) {
+		case "WAVE":
+			return "audio/wav"
+		case "WEBP":
+			return "image/webp"
+		case "AVI ":
+			return "video/x-msvideo"
+		}
+	case isTransportStream(b):
+		return "video/mp2t"
+	case bytes.HasPrefix(b, []byte("ID3")):
+		return sniffAudio(skipID3(b))
+	}
+	return sniffAudio(b)
+}
+
+// sniffAudio recognizes raw AAC and MPEG audio by their frame sync.
+func sniffAudio(b []byte) string {
+	if len(b) < 2 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return ""
+	}
+	// ADTS has a layer of zero, which MPEG audio doesn't use.
+	if b[1]&0x06 == 0 {
+		return "audio/aac"
+	}
+	return "audio/mpeg"
+}
+
+func skipID3(b []byte) []byte {
+	if len(b) < 10 {
+		return nil
+	}
+	size := int(b[6]&0x7f)<<21 | int(b[7]&0x7f)<<14 | int(b[8]&0x7f)<<7 | int(b[9]&0x7f)
+	if 10+size > len(b) {
+		return nil
+	}
+	return b[10+size:]
+}
+
+// isTransportStream looks for the sync byte that starts every 188 byte
+// packet of an MPEG-2 transport stream.
+func isTransportStream(b []byte) bool {
+	if len(b) < 188*2+1 {
+		return len(b) > 0 && b[0] == 0x47 && (len(b) <= 188 || b[188] == 0x47)
+	}
+	return b[0] == 0x47 && b[188] == 0x47 && b[376] == 0x47
+}
+
+func sniffMP4(b []byte) string {
+	switch string(b[8:12]) {
+	case "M4A ", "M4B ", "M4P ":
+		return "audio/mp4"
+	}
+	codecs := sniffCodecs(b)
+	if len(codecs) > 0 && !hasVideo(codecs) {
+		return "audio/mp4"
+	}
+	return "video/mp4"
+}
+
+func sniffMatroska(b []byte) string {
+	header := b
+	if len(header) > 64 {
+		header = header[:64]
+	}
+	webm := bytes.Contains(header, []byte("webm"))
+	codecs := sniffCodecs(b)
+	audioOnly := len(codecs) > 0 && !hasVideo(codecs)
+	switch {
+	case webm && audioOnly:
+		return "audio/webm"
+	case webm:
+		return "video/webm"
+	case audioOnly:
+		return "audio/x-matroska"
+	}
+	return "video/x-matroska"
+}
+
+// mp4Codecs maps the sample entries of MP4 to codecs, where their names
+// differ.
+var mp4Codecs = map[string]string{
+	"avc3": "avc1",
+	"Opus": "opus",
+	"fLaC": "flac",
+	".mp3": "mp3",
+	"dtsh": "dtsc",
+	"dtsl": "dtsc",
+	"dtse": "dtsc",
+}
+
+// matroskaCodecs maps the codec ids of Matroska to codecs.
+var matroskaCodecs = map[string]string{
+	"V_MPEG4/ISO/AVC":  "avc1",
+	"V_MPEGH/ISO/HEVC": "hev1",
+	"V_VP8":            "vp8",
+	"V_VP9":            "vp09",
+	"V_AV1":            "av01",
+	"V_MPEG2":          "mp2v",
+	"V_MPEG4/ISO/ASP":  "mp4v",
+	"V_THEORA":         "theora",
+	"A_AAC":            "mp4a",
+	"A_MPEG/L3":        "mp3",
+	"A_OPUS":           "opus",
+	"A_VORBIS":         "vorbis",
+	"A_FLAC":           "flac",
+	"A_AC3":            "ac-3",
+	"A_EAC3":           "ec-3",
+	"A_DTS":            "dtsc",
+	"A_TRUEHD":         "mlpa",
+	"A_PCM/INT/LIT":    "lpcm",
+}
+
+// sniffCodecs finds the codecs of MP4 and Matroska media, when their track
+// headers come first, as they do in files meant for streaming.
+func sniffCodecs(b []byte) []string {
+	var codecs []string
+	switch {
+	case len(b) >= 12 && string(b[4:8]) == "ftyp":
+		// The stsd atom is a full atom with an entry count, followed by the
+		// size and type of its first sample entry.
+		for i := 0; ; {
+			n := bytes.Index(b[i:], []byte("stsd"))
+			if n < 0 {
+				break
+			}
+			i += n + 4
+			if i+16 > len(b) || binary.BigEndian.Uint32(b[i+4:]) == 0 {
+				continue
+			}
+			kind := string(b[i+12 : i+16])
+			if c, ok := mp4Codecs[kind]; ok {
+				kind = c
+			}
+			codecs = append(codecs, kind)
+		}
+	case bytes.HasPrefix(b, []byte("\x1a\x45\xdf\xa3")):
+		// CodecID elements, with a one byte size.
+		for i := 0; i+2 < len(b); i++ {
+			if b[i] != 0x86 || b[i+1]&0x80 == 0 {
+				continue
+			}
+			size := int(b[i+1] & 0x7f)
+			if i+2+size > len(b) {
+				break
+			}
+			id := string(b[i+2 : i+2+size])
+			if !strings.HasPrefix(id, "V_") && !strings.HasPrefix(id, "A_") {
+				continue
+			}
+			codec, ok := matroskaCodecs[id]
+			for prefix, c := range matroskaCodecs {
+				if !ok && strings.HasPrefix(id, prefix) {
+					codec, ok = c, true
+				}
+			}
+			if !ok {
+				codec = id
+			}
+			codecs = append(codecs, codec)
+			i += 1 + size
+		}
+	}
+	return codecs
+}
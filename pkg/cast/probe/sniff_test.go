@@ -0,0 +1,72 @@
+package probe
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSniff(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"jpeg", "\xff\xd8\xff\xe0", "image/jpeg"},
+		{"png", "\x89PNG\r\n", "image/png"},
+		{"gif", "GIF89a", "image/gif"},
+		{"mp4", string(mp4Head), "video/mp4"},
+		{"m4a", "\x00\x00\x00\x18ftypM4A \x00\x00\x00\x00", "audio/mp4"},
+		{"mp4 audio", "\x00\x00\x00\x18ftypisom\x00\x00\x00\x00" +
+			"stsd\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x10mp4a", "audio/mp4"},
+		{"webm", "\x1a\x45\xdf\xa3\x9f\x42\x82\x84webm\x86\x85V_VP9", "video/webm"},
+		{"webm audio", "\x1a\x45\xdf\xa3\x9f\x42\x82\x84webm\x86\x86A_OPUS", "audio/webm"},
+		{"mka", "\x1a\x45\xdf\xa3\x42\x82\x88matroska\x86\x8bA_AAC/MPEG2", "audio/x-matroska"},
+		{"mkv", "\x1a\x45\xdf\xa3", "video/x-matroska"},
+		{"flac", "fLaC", "audio/flac"},
+		{"ogg", "OggS\x00\x02", "audio/ogg"},
+		{"ogv", "OggS\x00\x02\x80theora", "video/ogg"},
+		{"wav", "RIFF\x00\x00\x00\x00WAVE", "audio/wav"},
+		{"webp", "RIFF\x00\x00\x00\x00WEBP", "image/webp"},
+		{"avi", "RIFF\x00\x00\x00\x00AVI ", "video/x-msvideo"},
+		{"riff", "RIFF\x00\x00\x00\x00XXXX", ""},
+		{"ts", string(tsHead), "video/mp2t"},
+		{"aac", "\xff\xf1\x50\x80", "audio/aac"},
+		{"mp3", "\xff\xfb\x90\x00", "audio/mpeg"},
+		{"id3", "ID3\x04\x00\x00\x00\x00\x00\x02\x00\x00\xff\xfb\x90\x00", "audio/mpeg"},
+		{"truncated id3", "ID3\x04\x00\x00\x00\x00\x01\x00\xff\xfb", ""},
+		{"text", "hello", ""},
+		{"empty", "", ""},
+	}
+	for _, test := range tests {
+		if got := Sniff([]byte(test.head)); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSniffCodecs(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want []string
+	}{
+		{"mp4", string(mp4Head), []string{"avc1"}},
+		{"mp4 tracks", "\x00\x00\x00\x18ftypisom\x00\x00\x00\x00" +
+			"stsd\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x10avc3" +
+			"stsd\x00\x00\x00\x00\x00\x00\x00\x01\x00\x00\x00\x10Opus" +
+			"stsd\x00\x00\x00\x00\x00\x00\x00\x00" +
+			"stsd\x00\x00", []string{"avc1", "opus"}},
+		{"mkv", "\x1a\x45\xdf\xa3" +
+			"\x86\x8fV_MPEG4/ISO/AVC" +
+			"\x86\x88A_DTS/MA" +
+			"\x86\x87S_TEXT/" +
+			"\x86\x85V_XYZ" +
+			"\x86\x90A_AAC", []string{"avc1", "dtsc", "V_XYZ"}},
+		{"neither", "OggS", nil},
+	}
+	for _, test := range tests {
+		if got := sniffCodecs([]byte(test.head)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
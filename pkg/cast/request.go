@@ -0,0 +1,140 @@
+package cast
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"math"
+	"sync"
+)
+
+var (
+	ChannelClosed      = errors.New("Channel closed")
+	DuplicateRequestId = errors.New("Duplicate request id")
+)
+
+// requests allocates request ids and keeps track of the requests that are
+// waiting for a response. Ids are unique for the whole connection, so
+// responses can be routed no matter which channel sent the request.
+type requests struct {
+	mu      sync.Mutex
+	lastId  int32
+	pending map[int32]*pendingRequest
+}
+
+type pendingRequest struct {
+	namespace string
+	peer      string
+	response  chan *CastMessage
+}
+
+func newRequests() *requests {
+	return &requests{
+		pending: make(map[int32]*pendingRequest),
+	}
+}
+
+func (r *requests) next() int32 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for {
+		if r.lastId == math.MaxInt32 {
+			r.lastId = 0
+		}
+		r.lastId++
+		if _, ok := r.pending[r.lastId]; !ok {
+			return r.lastId
+		}
+	}
+}
+
+func (r *requests) register(requestId int32, namespace, peer string) (*pendingRequest, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.pending[requestId]; ok {
+		return nil, DuplicateRequestId
+	}
+
+	p := &pendingRequest{
+		namespace: namespace,
+		peer:      peer,
+		response:  make(chan *CastMessage, 1),
+	}
+	r.pending[requestId] = p
+	return p, nil
+}
+
+func (r *requests) unregister(requestId int32) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.pending, requestId)
+}
+
+// deliver hands m to the request waiting for it, if any, and reports
+// whether it did.
+func (r *requests) deliver(m *CastMessage) bool {
+	requestId := responseId(m)
+	if requestId == 0 {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.pending[requestId]
+	if !ok || p.namespace != m.GetNamespace() || p.peer != m.GetSourceId() {
+		return false
+	}
+
+	delete(r.pending, requestId)
+	p.response <- m
+	return true
+}
+
+// responseId returns the requestId of a message, or 0 for messages that
+// aren't responses.
+func responseId(m *CastMessage) int32 {
+	if m.GetPayloadType() != CastMessage_STRING {
+		return 0
+	}
+
+	header := &struct {
+		RequestId int32 `json:"requestId"`
+	}{}
+	if err := json.Unmarshal([]byte(m.GetPayloadUtf8()), header); err != nil {
+		log.Println("Failed to decode message payload:", err)
+		return 0
+	}
+
+	return header.RequestId
+}
+
+// Request sends payload, which must carry requestId (as obtained from
+// Device.NextRequestId), and waits for the response with the same id. It
+// fails with DeviceClosed if the device goes away and with ChannelClosed if
+// the channel is closed before the response arrives.
+func (c *Channel) Request(ctx context.Context, requestId int32, payload string) (*CastMessage, error) {
+	p, err := c.device.requests.register(requestId, c.namespace, c.destinationId)
+	if err != nil {
+		return nil, err
+	}
+	defer c.device.requests.unregister(requestId)
+
+	if err := c.Send(payload); err != nil {
+		return nil, err
+	}
+
+	select {
+	case m := <-p.response:
+		return m, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-c.device.ctx.Done():
+		return nil, DeviceClosed
+	case <-c.done:
+		return nil, ChannelClosed
+	}
+}
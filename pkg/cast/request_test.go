@@ -0,0 +1,185 @@
+package cast
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+func TestMain(m *testing.M) {
+	// Devices log every message.
+	log.SetOutput(ioutil.Discard)
+	os.Exit(m.Run())
+}
+
+func response(namespace, sourceId, payload string) *CastMessage {
+	return &CastMessage{
+		ProtocolVersion: CastMessage_CASTV2_1_0.Enum(),
+		SourceId:        proto.String(sourceId),
+		DestinationId:   proto.String("sender-0"),
+		Namespace:       proto.String(namespace),
+		PayloadType:     CastMessage_STRING.Enum(),
+		PayloadUtf8:     proto.String(payload),
+	}
+}
+
+func TestNextRequestId(t *testing.T) {
+	r := newRequests()
+	if id := r.next(); id != 1 {
+		t.Errorf("first id is %d", id)
+	}
+
+	// Ids still waiting for a response are skipped...
+	r.register(2, "ns", "peer")
+	r.register(3, "ns", "peer")
+	if id := r.next(); id != 4 {
+		t.Errorf("got %d, want 4", id)
+	}
+
+	// ...even after wrapping around, which skips 0 too.
+	r.lastId = math.MaxInt32 - 1
+	if id := r.next(); id != math.MaxInt32 {
+		t.Errorf("got %d, want %d", id, math.MaxInt32)
+	}
+	if id := r.next(); id != 1 {
+		t.Errorf("got %d after wrapping around, want 1", id)
+	}
+	if id := r.next(); id != 4 {
+		t.Errorf("got %d, want 4", id)
+	}
+
+	if _, err := r.register(3, "ns", "peer"); err != DuplicateRequestId {
+		t.Errorf("got %v, want %v", err, DuplicateRequestId)
+	}
+	r.unregister(3)
+	if _, err := r.register(3, "ns", "peer"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDeliver(t *testing.T) {
+	r := newRequests()
+	p, _ := r.register(7, "ns", "peer")
+
+	tests := []struct {
+		name      string
+		message   *CastMessage
+		delivered bool
+	}{
+		{"unsolicited", response("ns", "peer", `{"type":"STATUS","requestId":0}`), false},
+		{"not JSON", response("ns", "peer", `STATUS`), false},
+		{"unknown id", response("ns", "peer", `{"requestId":8}`), false},
+		{"other namespace", response("other", "peer", `{"requestId":7}`), false},
+		{"other peer", response("ns", "other", `{"requestId":7}`), false},
+		{"response", response("ns", "peer", `{"requestId":7}`), true},
+		{"second response", response("ns", "peer", `{"requestId":7}`), false},
+	}
+	for _, test := range tests {
+		if delivered := r.deliver(test.message); delivered != test.delivered {
+			t.Errorf("%s: delivered %v, want %v", test.name, delivered, test.delivered)
+		}
+	}
+
+	select {
+	case m := <-p.response:
+		if m.GetPayloadUtf8() != `{"requestId":7}` {
+			t.Errorf("got %s", m.GetPayloadUtf8())
+		}
+	default:
+		t.Error("nothing was delivered")
+	}
+}
+
+// TestRouting answers requests from two channels in reverse order, and
+// checks that each one gets its own response while other messages go to
+// the channel readers.
+func TestRouting(t *testing.T) {
+	sender, peer := net.Pipe()
+	defer peer.Close()
+	device := NewDevice(sender)
+	go device.Run()
+	defer device.Close()
+
+	a := device.NewChannel("urn:x-cast:a", "sender-0", "receiver-0")
+	b := device.NewChannel("urn:x-cast:b", "sender-0", "receiver-0")
+	defer a.Close()
+	defer b.Close()
+
+	type result struct {
+		m   *CastMessage
+		err error
+	}
+	request := func(ch *Channel) (int32, <-chan result) {
+		requestId := device.NextRequestId()
+		results := make(chan result, 1)
+		go func() {
+			m, err := ch.Request(context.Background(), requestId, fmt.Sprintf(`{"requestId":%d}`, requestId))
+			results <- result{m, err}
+		}()
+		return requestId, results
+	}
+	idA, resultsA := request(a)
+	idB, resultsB := request(b)
+	if idA == idB {
+		t.Fatalf("both requests got id %d", idA)
+	}
+
+	var requests []*CastMessage
+	for len(requests) < 2 {
+		m, err := Read(peer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		requests = append(requests, m)
+	}
+	for i := len(requests) - 1; i >= 0; i-- {
+		m := requests[i]
+		var header struct {
+			RequestId int32 `json:"requestId"`
+		}
+		json.Unmarshal([]byte(m.GetPayloadUtf8()), &header)
+		payload := fmt.Sprintf(`{"requestId":%d,"to":%q}`, header.RequestId, m.GetNamespace())
+		if err := Write(peer, response(m.GetNamespace(), "receiver-0", payload)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := Write(peer, response("urn:x-cast:a", "receiver-0", `{"type":"STATUS"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		results   <-chan result
+		requestId int32
+		namespace string
+	}{{resultsA, idA, "urn:x-cast:a"}, {resultsB, idB, "urn:x-cast:b"}} {
+		select {
+		case r := <-test.results:
+			want := fmt.Sprintf(`{"requestId":%d,"to":%q}`, test.requestId, test.namespace)
+			if r.err != nil {
+				t.Error(r.err)
+			} else if r.m.GetPayloadUtf8() != want {
+				t.Errorf("%s got %s", test.namespace, r.m.GetPayloadUtf8())
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s never got its response", test.namespace)
+		}
+	}
+
+	select {
+	case m := <-a.Read():
+		if m.GetPayloadUtf8() != `{"type":"STATUS"}` {
+			t.Errorf("read %s", m.GetPayloadUtf8())
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the status never reached the channel")
+	}
+}
@@ -0,0 +1,64 @@
+package serve
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// mediaTypes has the types of the formats a Cast device can play, which the
+// system's MIME database often lacks or gets wrong.
+var mediaTypes = map[string]string{
+	".aac":  "audio/aac",
+	".flac": "audio/flac",
+	".m4a":  "audio/mp4",
+	".mp3":  "audio/mpeg",
+	".oga":  "audio/ogg",
+	".ogg":  "audio/ogg",
+	".opus": "audio/ogg",
+	".wav":  "audio/wav",
+	".weba": "audio/webm",
+
+	".m4v":  "video/mp4",
+	".mkv":  "video/x-matroska",
+	".mp4":  "video/mp4",
+	".ogv":  "video/ogg",
+	".webm": "video/webm",
+
+	".bmp":  "image/bmp",
+	".gif":  "image/gif",
+	".jpeg": "image/jpeg",
+	".jpg":  "image/jpeg",
+	".png":  "image/png",
+	".webp": "image/webp",
+
+	".m3u8": "application/x-mpegURL",
+	".mpd":  "application/dash+xml",
+	".vtt":  "text/vtt",
+}
+
+// ContentType guesses the content type of a file from its name.
+func ContentType(name string) string {
+	ext := strings.ToLower(filepath.Ext(name))
+	if t, ok := mediaTypes[ext]; ok {
+		return t
+	}
+	return mime.TypeByExtension(ext)
+}
+
+// detectContentType guesses the content type of a file from its name or,
+// failing that, its first bytes.
+func detectContentType(name string, r io.Reader) (string, error) {
+	if t := ContentType(name); t != "" {
+		return t, nil
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return http.DetectContentType(head[:n]), nil
+}
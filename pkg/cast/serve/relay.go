@@ -0,0 +1,298 @@
+package serve
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// maxPlaylistSize bounds the HLS playlists we rewrite.
+const maxPlaylistSize = 4 * 1024 * 1024
+
+// relayedPrefix marks a path element holding a whole relayed URL, such as
+// the ones we rewrite playlists with, and its signature.
+const relayedPrefix = "~"
+
+var (
+	NotRelayed       = errors.New("Not a URL of the relay")
+	PlaylistTooLarge = errors.New("Playlist too large")
+)
+
+// relay is where a relayed file comes from, and what to send along. Only
+// the origin gets the header, and only URLs signed with key are relayed.
+type relay struct {
+	origin *url.URL
+	header http.Header
+	key    []byte
+}
+
+// relayedRequestHeaders are the headers of the device we pass on.
+var relayedRequestHeaders = []string{
+	"Range",
+	"If-Range",
+	"If-Modified-Since",
+	"If-None-Match",
+	"Accept",
+	"Accept-Language",
+}
+
+// relayedResponseHeaders are the headers of the origin we pass back.
+var relayedResponseHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Range",
+	"Accept-Ranges",
+	"Last-Modified",
+	"ETag",
+	"Cache-Control",
+	"Expires",
+}
+
+// Relay makes the media at origin available through the server, which
+// fetches it with header added to its requests. This is for media that
+// needs cookies, a referer or credentials, which the device can't send.
+// Range requests go through, and HLS playlists are rewritten so that their
+// segments and keys are relayed too.
+func (s *Server) Relay(origin string, header http.Header) (*File, error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("Can't relay %s, only HTTP is supported", origin)
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	file := &File{
+		URL:         s.url(token, relayName(u)),
+		ContentType: ContentType(u.Path),
+		Size:        -1,
+		token:       token,
+		relay:       &relay{origin: u, header: header, key: key},
+	}
+	return file, s.add(file)
+}
+
+func relayName(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return "media"
+	}
+	return name
+}
+
+// relayURL returns the URL of target, relayed through file.
+func (s *Server) relayURL(file *File, target *url.URL) string {
+	if *target == *file.relay.origin {
+		return file.URL
+	}
+	raw := target.String()
+	encoded := relayedPrefix + base64.RawURLEncoding.EncodeToString([]byte(raw)) + "." + file.relay.sign(raw)
+	return s.url(file.token, encoded+"/"+relayName(target))
+}
+
+func (r *relay) sign(raw string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(raw))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// unsign returns the URL encoded, if we signed it.
+func (r *relay) unsign(encoded string) (*url.URL, bool) {
+	i := strings.IndexByte(encoded, '.')
+	if i < 0 {
+		return nil, false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(encoded[:i])
+	if err != nil || !hmac.Equal([]byte(encoded[i+1:]), []byte(r.sign(string(raw)))) {
+		return nil, false
+	}
+	u, err := url.Parse(string(raw))
+	return u, err == nil
+}
+
+// checkRedirect keeps the header from going along with redirects that
+// leave the origin.
+func (r *relay) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= 10 {
+		return errors.New("Stopped after 10 redirects")
+	}
+	if !sameOrigin(req.URL, r.origin) {
+		for k := range r.header {
+			delete(req.Header, k)
+		}
+	}
+	return nil
+}
+
+// sameOrigin tells whether a and b have the same scheme and host.
+func sameOrigin(a, b *url.URL) bool {
+	return strings.EqualFold(a.Scheme, b.Scheme) && strings.EqualFold(a.Host, b.Host)
+}
+
+// target returns the URL a request for file is after. Past the token come
+// either the name of the origin, a relayed URL and its name, or a path
+// relative to either, as a playlist we didn't rewrite would refer to. Those
+// paths can't leave the host of what they're relative to.
+func (f *File) target(r *http.Request) (*url.URL, error) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	rest := ""
+	if len(parts) > 1 {
+		rest = parts[1]
+	}
+
+	base := f.relay.origin
+	if strings.HasPrefix(rest, relayedPrefix) {
+		parts := strings.SplitN(strings.TrimPrefix(rest, relayedPrefix), "/", 2)
+		u, ok := f.relay.unsign(parts[0])
+		if !ok {
+			return nil, NotRelayed
+		}
+		base, rest = u, ""
+		if len(parts) > 1 {
+			rest = parts[1]
+		}
+	}
+
+	if rest == "" || rest == relayName(base) {
+		return base, nil
+	}
+	target, err := base.Parse(rest)
+	if err != nil {
+		return nil, err
+	} else if !sameOrigin(target, base) {
+		return nil, NotRelayed
+	}
+	target.RawQuery = r.URL.RawQuery
+	return target, nil
+}
+
+func (s *Server) serveRelay(w http.ResponseWriter, r *http.Request, file *File) {
+	target, err := file.target(r)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	req, err := http.NewRequest(r.Method, target.String(), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	req = req.WithContext(r.Context())
+	for _, k := range relayedRequestHeaders {
+		if v, ok := r.Header[k]; ok {
+			req.Header[k] = v
+		}
+	}
+	if sameOrigin(target, file.relay.origin) {
+		for k, v := range file.relay.header {
+			req.Header[k] = v
+		}
+	}
+
+	client := &http.Client{CheckRedirect: file.relay.checkRedirect}
+	resp, err := client.Do(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	body := bufio.NewReader(resp.Body)
+	if r.Method == http.MethodGet && resp.StatusCode == http.StatusOK && isPlaylist(resp, body) {
+		s.servePlaylist(w, file, resp, body)
+		return
+	}
+
+	for _, k := range relayedResponseHeaders {
+		if v, ok := resp.Header[k]; ok {
+			w.Header()[k] = v
+		}
+	}
+	if w.Header().Get("Content-Type") == "" && target == file.relay.origin {
+		w.Header().Set("Content-Type", file.ContentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, body)
+}
+
+func isPlaylist(resp *http.Response, body *bufio.Reader) bool {
+	switch strings.ToLower(strings.Split(resp.Header.Get("Content-Type"), ";")[0]) {
+	case "application/x-mpegurl", "application/vnd.apple.mpegurl", "audio/mpegurl", "audio/x-mpegurl":
+		return true
+	}
+	head, _ := body.Peek(10)
+	return bytes.HasPrefix(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")), []byte("#EXTM3U"))
+}
+
+// servePlaylist rewrites the URLs of an HLS playlist so that they go
+// through the relay, resolving them against the playlist they're in.
+func (s *Server) servePlaylist(w http.ResponseWriter, file *File, resp *http.Response, body io.Reader) {
+	data, err := ioutil.ReadAll(io.LimitReader(body, maxPlaylistSize+1))
+	if err == nil && len(data) > maxPlaylistSize {
+		err = PlaylistTooLarge
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	base := resp.Request.URL
+	rewrite := func(ref string) string {
+		u, err := base.Parse(ref)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			// Such as the skd: and data: URIs of keys.
+			return ref
+		}
+		return s.relayURL(file, u)
+	}
+
+	var out bytes.Buffer
+	for _, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+		case strings.HasPrefix(trimmed, "#"):
+			line = uriAttribute.ReplaceAllStringFunc(line, func(attr string) string {
+				ref := uriAttribute.FindStringSubmatch(attr)[1]
+				return `URI="` + rewrite(ref) + `"`
+			})
+		default:
+			line = rewrite(trimmed)
+		}
+		out.WriteString(line)
+		out.WriteByte('\n')
+	}
+	content := bytes.TrimSuffix(out.Bytes(), []byte("\n"))
+
+	w.Header().Set("Content-Type", "application/x-mpegURL")
+	// Live playlists change, they must be fetched every time.
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+	w.Write(content)
+}
+
+// uriAttribute matches the URIs of tags such as EXT-X-KEY and EXT-X-MAP.
+var uriAttribute = regexp.MustCompile(`URI="([^"]*)"`)
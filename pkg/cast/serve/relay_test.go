@@ -0,0 +1,237 @@
+package serve
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is an origin that remembers the requests it gets.
+type recorder struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*http.Request
+}
+
+func newRecorder(handler http.HandlerFunc) *recorder {
+	rec := &recorder{}
+	rec.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec.mu.Lock()
+		rec.requests = append(rec.requests, r)
+		rec.mu.Unlock()
+		handler(w, r)
+	}))
+	return rec
+}
+
+func (rec *recorder) last() *http.Request {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if len(rec.requests) == 0 {
+		return nil
+	}
+	return rec.requests[len(rec.requests)-1]
+}
+
+func newTestServer(t *testing.T) *Server {
+	s, err := Listen("127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+var testHeader = http.Header{"Cookie": {"session=secret"}, "Referer": {"http://example.com/"}}
+
+func get(t *testing.T, url string, header http.Header) (*http.Response, []byte) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestRelayPlaylist(t *testing.T) {
+	foreign := newRecorder(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("foreign segment"))
+	})
+	defer foreign.Close()
+	origin := newRecorder(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/live/index.m3u8":
+			w.Header().Set("Content-Type", "application/vnd.apple.mpegurl")
+			w.Write([]byte("#EXTM3U\n" +
+				"#EXT-X-KEY:METHOD=AES-128,URI=\"keys/1.bin\"\n" +
+				"#EXT-X-KEY:METHOD=SAMPLE-AES,URI=\"skd://key-1\"\n" +
+				"#EXT-X-MAP:URI=\"data:video/mp4;base64,AAAA\"\n" +
+				"#EXTINF:10,\n" +
+				"seg1.ts\n" +
+				"#EXTINF:10,\n" +
+				foreign.URL + "/seg2.ts\n"))
+		default:
+			w.Write([]byte("segment " + r.URL.Path))
+		}
+	})
+	defer origin.Close()
+
+	s := newTestServer(t)
+	defer s.Close()
+	file, err := s.Relay(origin.URL+"/live/index.m3u8", testHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := get(t, file.URL, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got %s", resp.Status)
+	}
+	if r := origin.last(); r.Header.Get("Cookie") != "session=secret" || r.Header.Get("Referer") != "http://example.com/" {
+		t.Errorf("the origin got %v", r.Header)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(body), "\n"), "\n")
+	if len(lines) != 8 {
+		t.Fatalf("got %q", body)
+	}
+	prefix := strings.TrimSuffix(file.URL, "index.m3u8") + relayedPrefix
+	key := strings.TrimSuffix(strings.TrimPrefix(lines[1], `#EXT-X-KEY:METHOD=AES-128,URI="`), `"`)
+	for _, u := range []string{key, lines[5], lines[7]} {
+		if !strings.HasPrefix(u, prefix) {
+			t.Errorf("%s isn't relayed", u)
+		}
+	}
+	if lines[2] != `#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://key-1"` || lines[3] != `#EXT-X-MAP:URI="data:video/mp4;base64,AAAA"` {
+		t.Errorf("rewrote %q and %q", lines[2], lines[3])
+	}
+
+	for _, test := range []struct {
+		url  string
+		want string
+	}{
+		{key, "segment /live/keys/1.bin"},
+		{lines[5], "segment /live/seg1.ts"},
+	} {
+		if _, body := get(t, test.url, nil); string(body) != test.want {
+			t.Errorf("%s: got %q, want %q", test.url, body, test.want)
+		}
+		if r := origin.last(); r.Header.Get("Cookie") != "session=secret" {
+			t.Errorf("%s: the origin got %v", test.url, r.Header)
+		}
+	}
+
+	// Other hosts get the segments asked for, but not the credentials.
+	if _, body := get(t, lines[7], nil); string(body) != "foreign segment" {
+		t.Errorf("got %q", body)
+	}
+	if r := foreign.last(); r == nil || r.Header.Get("Cookie") != "" || r.Header.Get("Referer") != "" {
+		t.Errorf("the foreign host got %v", r)
+	}
+}
+
+func TestRelayPaths(t *testing.T) {
+	foreign := newRecorder(func(w http.ResponseWriter, r *http.Request) {})
+	defer foreign.Close()
+	origin := newRecorder(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away" {
+			http.Redirect(w, r, foreign.URL+"/landed", http.StatusFound)
+			return
+		}
+		w.Write([]byte(r.URL.Path + "?" + r.URL.RawQuery))
+	})
+	defer origin.Close()
+
+	s := newTestServer(t)
+	defer s.Close()
+	file, err := s.Relay(origin.URL+"/live/index.m3u8", testHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := strings.TrimSuffix(file.URL, "index.m3u8")
+
+	// Paths are relative to the origin.
+	if _, body := get(t, base+"sub/seg.ts?n=1", nil); string(body) != "/live/sub/seg.ts?n=1" {
+		t.Errorf("got %q", body)
+	}
+
+	// Whatever doesn't come from the relay isn't relayed.
+	forged := relayedPrefix + "aHR0cDovL2V4YW1wbGUuY29tLw.AAAAAAAAAAAAAAAAAAAAAA/seg.ts"
+	for _, path := range []string{
+		forged,
+		relayedPrefix + "aHR0cDovL2V4YW1wbGUuY29tLw/seg.ts",
+		"http:%2F%2F" + strings.TrimPrefix(foreign.URL, "http://") + "/seg.ts",
+	} {
+		if resp, _ := get(t, base+path, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: got %s", path, resp.Status)
+		}
+	}
+	if r := foreign.last(); r != nil {
+		t.Errorf("the foreign host got %s", r.URL)
+	}
+
+	// Neither do the credentials follow redirects to other hosts.
+	get(t, base+"../away", nil)
+	if r := foreign.last(); r == nil || r.URL.Path != "/landed" || r.Header.Get("Cookie") != "" || r.Header.Get("Referer") != "" {
+		t.Errorf("the foreign host got %v", r)
+	}
+}
+
+func TestRelayRange(t *testing.T) {
+	content := []byte("0123456789")
+	origin := newRecorder(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "movie.mp4", time.Time{}, bytes.NewReader(content))
+	})
+	defer origin.Close()
+
+	s := newTestServer(t)
+	defer s.Close()
+	file, err := s.Relay(origin.URL+"/movie.mp4", testHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, body := get(t, file.URL, http.Header{"Range": {"bytes=2-5"}})
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "2345" {
+		t.Errorf("got %s: %q", resp.Status, body)
+	}
+	if cr := resp.Header.Get("Content-Range"); cr != "bytes 2-5/10" {
+		t.Errorf("got Content-Range %q", cr)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "video/mp4" {
+		t.Errorf("got Content-Type %q", ct)
+	}
+}
+
+func TestRelayLargePlaylist(t *testing.T) {
+	origin := newRecorder(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("#EXTM3U\n"))
+		w.Write(bytes.Repeat([]byte("seg.ts\n"), maxPlaylistSize/7))
+	})
+	defer origin.Close()
+
+	s := newTestServer(t)
+	defer s.Close()
+	file, err := s.Relay(origin.URL+"/index.m3u8", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := get(t, file.URL, nil); resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("got %s", resp.Status)
+	}
+}
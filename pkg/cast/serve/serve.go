@@ -0,0 +1,272 @@
+// Package serve publishes local files over HTTP so that a Cast device can
+// play them.
+package serve
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ServerClosed = errors.New("Server closed")
+
+// File is a file published by a Server.
+type File struct {
+	// URL is where the device can fetch the file from.
+	URL         string
+	ContentType string
+	Size        int64
+
+	token string
+	// Files either live on disk, at path, in memory, at the other end of a
+	// relay, or are a live stream.
+	path    string
+	content []byte
+	modTime time.Time
+	relay   *relay
+	stream  *stream
+}
+
+// Server is an HTTP server for the files of a single casting session. Every
+// file gets a random path, so that only whoever we hand its URL can get it.
+type Server struct {
+	listener net.Listener
+	server   *http.Server
+	host     string
+
+	mu     sync.Mutex
+	files  map[string]*File
+	closed bool
+}
+
+// Listen starts a server on the local address that faces the device at
+// deviceHost, a host or host:port.
+func Listen(deviceHost string) (*Server, error) {
+	ip, err := LocalIP(deviceHost)
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(ip.String(), "0"))
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		listener: listener,
+		host:     listener.Addr().String(),
+		files:    make(map[string]*File),
+	}
+	s.server = &http.Server{
+		Handler:           s,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+
+	go s.server.Serve(listener)
+
+	return s, nil
+}
+
+// LocalIP returns the address of the interface we use to reach deviceHost.
+func LocalIP(deviceHost string) (net.IP, error) {
+	if _, _, err := net.SplitHostPort(deviceHost); err != nil {
+		deviceHost = net.JoinHostPort(deviceHost, "8009")
+	}
+
+	// Nothing is sent, dialing UDP only picks a route.
+	conn, err := net.Dial("udp", deviceHost)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Publish makes the file at filePath available until it is unpublished or
+// the server is closed.
+func (s *Server) Publish(filePath string) (*File, error) {
+	filePath, err := filepath.Abs(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	} else if info.IsDir() {
+		return nil, fmt.Errorf("%s is a directory", filePath)
+	}
+
+	contentType, err := detectContentType(filePath, f)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	file := &File{
+		ContentType: contentType,
+		Size:        info.Size(),
+		token:       token,
+		path:        filePath,
+	}
+	file.URL = s.url(token, filepath.Base(filePath))
+
+	return file, s.add(file)
+}
+
+// PublishContent makes content available as a file with the given name,
+// e.g. to serve something converted on the fly.
+func (s *Server) PublishContent(name, contentType string, content []byte) (*File, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	file := &File{
+		URL:         s.url(token, name),
+		ContentType: contentType,
+		Size:        int64(len(content)),
+		token:       token,
+		content:     content,
+		modTime:     time.Now(),
+	}
+	return file, s.add(file)
+}
+
+func (s *Server) add(file *File) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ServerClosed
+	}
+	s.files[file.token] = file
+	return nil
+}
+
+func (s *Server) Unpublish(file *File) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, file.token)
+}
+
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	s.files = make(map[string]*File)
+	s.mu.Unlock()
+	return s.server.Close()
+}
+
+// url returns the URL of a published file. The name is only there for the
+// device's (and our logs') sake, files are found by their token.
+func (s *Server) url(token, name string) string {
+	u := url.URL{
+		Scheme: "http",
+		Host:   s.host,
+		Path:   "/" + token + "/" + name,
+	}
+	return u.String()
+}
+
+func (s *Server) lookup(urlPath string) *File {
+	parts := strings.SplitN(strings.TrimPrefix(urlPath, "/"), "/", 2)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files[parts[0]]
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	setCORSHeaders(w.Header())
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	default:
+		w.Header().Set("Allow", "GET, HEAD, OPTIONS")
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	file := s.lookup(r.URL.Path)
+	if file == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	if file.relay != nil {
+		s.serveRelay(w, r, file)
+		return
+	} else if file.stream != nil {
+		s.serveStream(w, r, file)
+		return
+	}
+
+	w.Header().Set("Content-Type", file.ContentType)
+
+	if file.path == "" {
+		http.ServeContent(w, r, "", file.modTime, bytes.NewReader(file.content))
+		return
+	}
+
+	f, err := os.Open(file.path)
+	if err != nil {
+		http.Error(w, "File not available", http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, "File not available", http.StatusInternalServerError)
+		return
+	}
+
+	// ServeContent takes care of Range and conditional requests.
+	http.ServeContent(w, r, filepath.Base(file.path), info.ModTime(), f)
+}
+
+// setCORSHeaders allows the receiver app, which runs on another origin, to
+// fetch and seek through our files.
+func setCORSHeaders(h http.Header) {
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Set("Access-Control-Allow-Methods", "GET, HEAD, OPTIONS")
+	h.Set("Access-Control-Allow-Headers", "Content-Type, Range, Accept-Encoding, Origin")
+	h.Set("Access-Control-Expose-Headers", "Content-Length, Content-Range, Accept-Ranges, Content-Type")
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
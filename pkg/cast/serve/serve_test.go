@@ -0,0 +1,159 @@
+package serve
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func publishTemp(t *testing.T, s *Server, name string, content []byte) *File {
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := s.Publish(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestServeFile(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	file := publishTemp(t, s, "movie.mp4", []byte("0123456789"))
+	if file.ContentType != "video/mp4" || file.Size != 10 {
+		t.Errorf("published %+v", file)
+	}
+
+	resp, body := get(t, file.URL, nil)
+	if resp.StatusCode != http.StatusOK || string(body) != "0123456789" || resp.Header.Get("Content-Type") != "video/mp4" {
+		t.Errorf("got %s, %s: %q", resp.Status, resp.Header.Get("Content-Type"), body)
+	}
+
+	resp, body = get(t, file.URL, http.Header{"Range": {"bytes=3-"}})
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "3456789" || resp.Header.Get("Content-Range") != "bytes 3-9/10" {
+		t.Errorf("got %s, %s: %q", resp.Status, resp.Header.Get("Content-Range"), body)
+	}
+
+	resp, err := http.Head(file.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength != 10 || resp.Header.Get("Accept-Ranges") != "bytes" {
+		t.Errorf("got %s, %d bytes, ranges %q", resp.Status, resp.ContentLength, resp.Header.Get("Accept-Ranges"))
+	}
+
+	// Only the token matters, not the name.
+	token := strings.TrimSuffix(file.URL, "movie.mp4")
+	if resp, body := get(t, token+"other.mp4", nil); resp.StatusCode != http.StatusOK || string(body) != "0123456789" {
+		t.Errorf("got %s: %q", resp.Status, body)
+	}
+}
+
+func TestServeNotFound(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	file := publishTemp(t, s, "movie.mp4", []byte("movie"))
+	base := "http://" + s.Addr().String() + "/"
+
+	for _, url := range []string{base, base + "0123456789abcdef0123456789abcdef/movie.mp4", base + "movie.mp4"} {
+		if resp, _ := get(t, url, nil); resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: got %s", url, resp.Status)
+		}
+	}
+
+	s.Unpublish(file)
+	if resp, _ := get(t, file.URL, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("got %s after unpublishing", resp.Status)
+	}
+	if _, err := s.PublishContent("a.vtt", "text/vtt", nil); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	if _, err := s.PublishContent("a.vtt", "text/vtt", nil); err != ServerClosed {
+		t.Errorf("got %v, want %v", err, ServerClosed)
+	}
+}
+
+func TestServeCORS(t *testing.T) {
+	s := newTestServer(t)
+	defer s.Close()
+	file, err := s.PublishContent("subtitles.vtt", "text/vtt", []byte("WEBVTT\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req, err := http.NewRequest(http.MethodOptions, file.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Origin", "https://receiver.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent || resp.Header.Get("Access-Control-Allow-Origin") != "*" ||
+		!strings.Contains(resp.Header.Get("Access-Control-Allow-Headers"), "Range") {
+		t.Errorf("got %s, %v", resp.Status, resp.Header)
+	}
+
+	resp, body := get(t, file.URL, nil)
+	if resp.Header.Get("Access-Control-Allow-Origin") != "*" || resp.Header.Get("Content-Type") != "text/vtt" || string(body) != "WEBVTT\n" {
+		t.Errorf("got %v: %q", resp.Header, body)
+	}
+
+	resp, err = http.Post(file.URL, "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed || resp.Header.Get("Allow") != "GET, HEAD, OPTIONS" {
+		t.Errorf("got %s, allowing %q", resp.Status, resp.Header.Get("Allow"))
+	}
+}
+
+func TestContentType(t *testing.T) {
+	tests := map[string]string{
+		"song.MP3":      "audio/mpeg",
+		"movie.mkv":     "video/x-matroska",
+		"a/b/live.m3u8": "application/x-mpegURL",
+		"manifest.mpd":  "application/dash+xml",
+		"subs.vtt":      "text/vtt",
+		"noext":         "",
+	}
+	for name, want := range tests {
+		if got := ContentType(name); got != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+
+	for _, test := range []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"movie.mp4", []byte("whatever"), "video/mp4"},
+		{"image", []byte("\x89PNG\r\n\x1a\n"), "image/png"},
+		{"empty", nil, "text/plain; charset=utf-8"},
+		{"clip", append([]byte("\x1a\x45\xdf\xa3"), bytes.Repeat([]byte{0}, 600)...), "video/webm"},
+	} {
+		got, err := detectContentType(test.name, bytes.NewReader(test.content))
+		if err != nil || got != test.want {
+			t.Errorf("%s: got %q, %v, want %q", test.name, got, err, test.want)
+		}
+	}
+
+	s := newTestServer(t)
+	defer s.Close()
+	if _, err := s.Publish(os.TempDir()); err == nil {
+		t.Error("published a directory")
+	}
+}
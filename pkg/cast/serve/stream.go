@@ -0,0 +1,452 @@
+package serve
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// streamBacklog is how much of a live stream we keep, so that a device that
+// reconnects can start over from the last point it can join at.
+const streamBacklog = 16 * 1024 * 1024
+
+const streamReadSize = 32 * 1024
+
+// stream is a live stream, read once and served to any number of clients.
+// Clients get the header of the stream, such as the ftyp and moov boxes of
+// MP4, and then the stream from the last point they can join at, such as a
+// Matroska cluster or an MP4 fragment.
+type stream struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	// chunks hold the stream from offset start on, size bytes in all. They
+	// are dropped whole, oldest first, so the backlog is never copied.
+	chunks [][]byte
+	start  int64
+	size   int64
+	// headerEnd is where the header ends, -1 until we know it.
+	header    []byte
+	headerEnd int64
+	// joins are the offsets, in data, that clients can start at.
+	joins  []int64
+	joiner joiner
+	err    error
+}
+
+// PublishStream makes a live stream, read from r until it ends, available
+// as a file with the given name. Every request gets the stream from around
+// its live edge, so devices can reconnect mid-stream.
+func (s *Server) PublishStream(name, contentType string, r io.Reader) (*File, error) {
+	token, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	st := newStream(contentType)
+	file := &File{
+		URL:         s.url(token, name),
+		ContentType: contentType,
+		Size:        -1,
+		token:       token,
+		modTime:     time.Now(),
+		stream:      st,
+	}
+	if err := s.add(file); err != nil {
+		return nil, err
+	}
+
+	go st.read(r)
+	return file, nil
+}
+
+func newStream(contentType string) *stream {
+	st := &stream{
+		headerEnd: -1,
+		joiner:    newJoiner(contentType),
+	}
+	st.cond = sync.NewCond(&st.mu)
+	return st
+}
+
+// read reads r to the end, whether or not anybody is listening, so that
+// whoever writes to it doesn't block.
+func (st *stream) read(r io.Reader) {
+	b := make([]byte, streamReadSize)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			st.append(b[:n])
+		}
+		if err != nil {
+			st.mu.Lock()
+			st.err = err
+			st.mu.Unlock()
+			st.cond.Broadcast()
+			return
+		}
+	}
+}
+
+func (st *stream) append(p []byte) {
+	headerEnd, joins := st.joiner.feed(p)
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	defer st.cond.Broadcast()
+
+	st.write(p)
+	// We keep the whole stream until we know where its header ends, so it
+	// all starts at zero.
+	if st.headerEnd < 0 && headerEnd >= 0 {
+		st.headerEnd = headerEnd
+		st.header = st.slice(0, headerEnd)
+	}
+	st.joins = append(st.joins, joins...)
+
+	if st.size <= streamBacklog {
+		return
+	}
+
+	if st.headerEnd < 0 {
+		// No header in sight, whoever joins gets the stream as it is.
+		st.headerEnd = 0
+		st.joiner = &packetJoiner{pos: st.end(), size: 1}
+	}
+
+	for st.size > streamBacklog {
+		n := int64(len(st.chunks[0]))
+		st.chunks[0] = nil
+		st.chunks = st.chunks[1:]
+		st.start += n
+		st.size -= n
+	}
+	for len(st.joins) > 0 && st.joins[0] < st.start {
+		st.joins = st.joins[1:]
+	}
+	if len(st.joins) == 0 {
+		st.joins = append(st.joins, st.start)
+	}
+}
+
+// write adds p to the end of the last chunk, if it fits, or to a new one.
+func (st *stream) write(p []byte) {
+	st.size += int64(len(p))
+	if n := len(st.chunks); n > 0 && cap(st.chunks[n-1])-len(st.chunks[n-1]) >= len(p) {
+		st.chunks[n-1] = append(st.chunks[n-1], p...)
+		return
+	}
+	size := streamReadSize
+	if len(p) > size {
+		size = len(p)
+	}
+	st.chunks = append(st.chunks, append(make([]byte, 0, size), p...))
+}
+
+// slice copies the data between the offsets from and to, which must be
+// between start and end.
+func (st *stream) slice(from, to int64) []byte {
+	b := make([]byte, 0, to-from)
+	pos := st.start
+	for _, c := range st.chunks {
+		if pos >= to {
+			break
+		}
+		if end := pos + int64(len(c)); end > from {
+			lo, hi := int64(0), int64(len(c))
+			if from > pos {
+				lo = from - pos
+			}
+			if to < end {
+				hi = to - pos
+			}
+			b = append(b, c[lo:hi]...)
+		}
+		pos += int64(len(c))
+	}
+	return b
+}
+
+// end is where the data we have ends.
+func (st *stream) end() int64 {
+	return st.start + st.size
+}
+
+// wakeOnDone wakes up whoever waits for the stream once ctx is done, so that
+// they can see it.
+func (st *stream) wakeOnDone(ctx context.Context) {
+	<-ctx.Done()
+	// Waiters check ctx with the lock held, so they can't miss this.
+	st.mu.Lock()
+	st.cond.Broadcast()
+	st.mu.Unlock()
+}
+
+// next returns the data from pos on, waiting for it if need be, and where
+// it ends. If pos is gone, which happens to slow clients, it skips to the
+// first point left to join at.
+func (st *stream) next(ctx context.Context, pos int64) ([]byte, int64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for {
+		if pos < st.start && len(st.joins) > 0 {
+			pos = st.joins[0]
+		}
+		if pos >= st.start && pos < st.end() {
+			end := pos + streamReadSize*8
+			if end > st.end() {
+				end = st.end()
+			}
+			return st.slice(pos, end), end, nil
+		}
+		if st.err != nil {
+			return nil, pos, st.err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, pos, err
+		}
+		st.cond.Wait()
+	}
+}
+
+// join waits for a point to join the stream at and returns the header of the
+// stream, along with that point.
+func (st *stream) join(ctx context.Context) ([]byte, int64, error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for st.headerEnd < 0 || len(st.joins) == 0 {
+		if st.err != nil {
+			return nil, 0, st.err
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+		st.cond.Wait()
+	}
+	return st.header, st.joins[len(st.joins)-1], nil
+}
+
+func (s *Server) serveStream(w http.ResponseWriter, r *http.Request, file *File) {
+	w.Header().Set("Content-Type", file.ContentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	// The request is done once the handler returns, so this doesn't leak.
+	ctx := r.Context()
+	go file.stream.wakeOnDone(ctx)
+
+	header, pos, err := file.stream.join(ctx)
+	if err != nil {
+		http.Error(w, "Stream ended", http.StatusNotFound)
+		return
+	}
+
+	// Ranges don't make sense here, whoever asks gets the live edge.
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(header); err != nil {
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	for {
+		var data []byte
+		data, pos, err = file.stream.next(ctx, pos)
+		if len(data) > 0 {
+			if _, err := w.Write(data); err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// joiner finds where clients can join a stream: where its header ends and
+// where its self-contained parts start. It's fed the stream as it comes,
+// and returns offsets from the start of the stream, headerEnd being -1
+// until known.
+type joiner interface {
+	feed(p []byte) (headerEnd int64, joins []int64)
+}
+
+func newJoiner(contentType string) joiner {
+	switch contentType {
+	case "video/mp4", "audio/mp4":
+		return &boxJoiner{headerEnd: -1}
+	case "video/webm", "audio/webm", "video/x-matroska", "audio/x-matroska":
+		return &ebmlJoiner{headerEnd: -1}
+	case "video/mp2t":
+		return &packetJoiner{size: 188}
+	}
+	// Streams of frames, like MP3 and ADTS, which players sync to.
+	return &packetJoiner{size: 1}
+}
+
+// packetJoiner joins streams of fixed size packets at a packet boundary.
+type packetJoiner struct {
+	pos  int64
+	size int64
+}
+
+func (j *packetJoiner) feed(p []byte) (int64, []int64) {
+	var joins []int64
+	offset := (j.size - j.pos%j.size) % j.size
+	if offset < int64(len(p)) {
+		joins = append(joins, j.pos+offset)
+	}
+	j.pos += int64(len(p))
+	return 0, joins
+}
+
+// boxJoiner joins fragmented MP4 at its moof boxes. The boxes before the
+// first one, ftyp and moov, are the header.
+type boxJoiner struct {
+	pos int64
+	// next is the offset of the next box, whose header is pending.
+	next      int64
+	pending   []byte
+	headerEnd int64
+	done      bool
+}
+
+func (j *boxJoiner) feed(p []byte) (int64, []int64) {
+	var joins []int64
+	end := j.pos + int64(len(p))
+	for !j.done && j.next+int64(len(j.pending)) < end {
+		need := 8
+		if len(j.pending) >= 8 && binary.BigEndian.Uint32(j.pending) == 1 {
+			need = 16
+		}
+		if len(j.pending) < need {
+			i := j.next + int64(len(j.pending)) - j.pos
+			n := int64(need - len(j.pending))
+			if n > int64(len(p))-i {
+				n = int64(len(p)) - i
+			}
+			j.pending = append(j.pending, p[i:i+n]...)
+			continue
+		}
+
+		size := int64(binary.BigEndian.Uint32(j.pending))
+		if size == 1 {
+			size = int64(binary.BigEndian.Uint64(j.pending[8:]))
+		}
+		if string(j.pending[4:8]) == "moof" {
+			if j.headerEnd < 0 {
+				j.headerEnd = j.next
+			}
+			joins = append(joins, j.next)
+		}
+		if size < int64(len(j.pending)) {
+			// The box goes to the end of the stream, or we lost track.
+			j.done = true
+		}
+		j.next += size
+		j.pending = j.pending[:0]
+	}
+	j.pos = end
+	if j.done && j.headerEnd < 0 {
+		j.headerEnd = 0
+		joins = append(joins, 0)
+	}
+	return j.headerEnd, joins
+}
+
+// Matroska elements that matter to joining.
+const (
+	ebmlSegment = 0x18538067
+	ebmlCluster = 0x1f43b675
+)
+
+// ebmlJoiner joins Matroska and WebM at their clusters. Everything before
+// the first one is the header. It reads the elements of every level in
+// order, entering only the segment and clusters, which live streams write
+// without a size.
+type ebmlJoiner struct {
+	pos       int64
+	next      int64
+	pending   []byte
+	headerEnd int64
+	done      bool
+}
+
+func (j *ebmlJoiner) feed(p []byte) (int64, []int64) {
+	var joins []int64
+	end := j.pos + int64(len(p))
+	for !j.done && j.next+int64(len(j.pending)) < end {
+		i := j.next + int64(len(j.pending)) - j.pos
+		j.pending = append(j.pending, p[i])
+
+		id, size, n, ok := parseElementHeader(j.pending)
+		if !ok {
+			if len(j.pending) >= 12 {
+				j.done = true
+			}
+			continue
+		}
+
+		if id == ebmlCluster {
+			if j.headerEnd < 0 {
+				j.headerEnd = j.next
+			}
+			joins = append(joins, j.next)
+		}
+		j.next += int64(n)
+		if id != ebmlSegment && size >= 0 {
+			j.next += size
+		}
+		j.pending = j.pending[:0]
+	}
+	j.pos = end
+	if j.done && j.headerEnd < 0 {
+		j.headerEnd = 0
+		joins = append(joins, 0)
+	}
+	return j.headerEnd, joins
+}
+
+// parseElementHeader parses the id and size of an EBML element, the size
+// being -1 when unknown. It returns false until b holds all of them.
+func parseElementHeader(b []byte) (id uint32, size int64, n int, ok bool) {
+	idLen := vintLength(b)
+	if idLen == 0 || idLen > 4 || len(b) <= idLen {
+		return 0, 0, 0, false
+	}
+	for _, c := range b[:idLen] {
+		id = id<<8 | uint32(c)
+	}
+
+	sizeLen := vintLength(b[idLen:])
+	if sizeLen == 0 || len(b) < idLen+sizeLen {
+		return 0, 0, 0, false
+	}
+	v := uint64(b[idLen] & (0xff >> uint(sizeLen)))
+	for _, c := range b[idLen+1 : idLen+sizeLen] {
+		v = v<<8 | uint64(c)
+	}
+	size = int64(v)
+	if v == 1<<(7*uint(sizeLen))-1 {
+		size = -1
+	}
+	return id, size, idLen + sizeLen, true
+}
+
+func vintLength(b []byte) int {
+	if len(b) == 0 {
+		return 0
+	}
+	for n := 1; n <= 8; n++ {
+		if b[0]&(0x80>>uint(n-1)) != 0 {
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,110 @@
+package serve
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+// pattern returns n bytes of a stream whose byte at offset i is i%251.
+func pattern(pos int64, n int) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = byte((pos + int64(i)) % 251)
+	}
+	return b
+}
+
+func TestStreamBacklog(t *testing.T) {
+	st := newStream("audio/mpeg")
+	var pos int64
+	for pos < 2*streamBacklog {
+		st.append(pattern(pos, streamReadSize))
+		pos += streamReadSize
+	}
+
+	if st.size > streamBacklog || st.end() != pos || st.start != pos-st.size {
+		t.Fatalf("kept %d bytes from %d, up to %d of %d", st.size, st.start, st.end(), pos)
+	}
+	if len(st.chunks) != streamBacklog/streamReadSize {
+		t.Errorf("kept %d chunks", len(st.chunks))
+	}
+
+	// A client that fell behind skips to the oldest data left.
+	data, next, err := st.next(context.Background(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, pattern(st.start, len(data))) || next != st.start+int64(len(data)) {
+		t.Errorf("got %d bytes up to %d, from %d", len(data), next, st.start)
+	}
+}
+
+func TestStreamSmallWrites(t *testing.T) {
+	st := newStream("audio/mpeg")
+	var pos int64
+	for _, n := range []int{1, 100, 1000, streamReadSize, 7, streamReadSize * 2, 3} {
+		st.append(pattern(pos, n))
+		pos += int64(n)
+	}
+	if len(st.chunks) != 5 {
+		t.Errorf("wrote %d chunks", len(st.chunks))
+	}
+
+	for _, r := range [][2]int64{{0, pos}, {1, 101}, {1000, streamReadSize + 2000}, {pos - 3, pos}, {5, 5}} {
+		if got := st.slice(r[0], r[1]); !bytes.Equal(got, pattern(r[0], int(r[1]-r[0]))) {
+			t.Errorf("%d to %d: got %d wrong bytes", r[0], r[1], len(got))
+		}
+	}
+}
+
+func TestStreamEnd(t *testing.T) {
+	st := newStream("audio/mpeg")
+	go st.read(bytes.NewReader(pattern(0, 100)))
+
+	var got []byte
+	var pos int64
+	for {
+		data, next, err := st.next(context.Background(), pos)
+		got, pos = append(got, data...), next
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !bytes.Equal(got, pattern(0, 100)) {
+		t.Errorf("got %d bytes", len(got))
+	}
+}
+
+func TestStreamWaitCancelled(t *testing.T) {
+	st := newStream("video/mp4")
+	ctx, cancel := context.WithCancel(context.Background())
+	go st.wakeOnDone(ctx)
+
+	errs := make(chan error, 2)
+	go func() {
+		_, _, err := st.join(ctx)
+		errs <- err
+	}()
+	go func() {
+		_, _, err := st.next(ctx, 0)
+		errs <- err
+	}()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if err != context.Canceled {
+				t.Errorf("got %v, want %v", err, context.Canceled)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("still waiting after the request was cancelled")
+		}
+	}
+}
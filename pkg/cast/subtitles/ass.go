@@ -0,0 +1,94 @@
+package subtitles
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// defaultASSFormat is the order of the fields of an event when the file
+// doesn't say.
+var defaultASSFormat = []string{"layer", "start", "end", "style", "name", "marginl", "marginr", "marginv", "effect", "text"}
+
+var assTime = regexp.MustCompile(`^(\d+):(\d{1,2}):(\d{1,2})(?:\.(\d{1,3}))?$`)
+
+// parseASS reads the dialogue events of an ASS or SSA file. Styles and
+// positioning are dropped, only the text is kept.
+func parseASS(text string) ([]Cue, error) {
+	var cues []Cue
+	section := ""
+	format := defaultASSFormat
+
+	for n, line := range splitLines(text) {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(line)
+			continue
+		} else if section != "[events]" {
+			continue
+		}
+
+		key, value := splitASSLine(line)
+		switch key {
+		case "format":
+			format = nil
+			for _, f := range strings.Split(value, ",") {
+				format = append(format, strings.ToLower(strings.TrimSpace(f)))
+			}
+		case "dialogue":
+			// The text is last and can have commas of its own.
+			fields := strings.SplitN(value, ",", len(format))
+			if len(fields) != len(format) {
+				return nil, fmt.Errorf("Invalid dialogue at line %d", n+1)
+			}
+
+			cue := Cue{}
+			var err error
+			for i, name := range format {
+				field := strings.TrimSpace(fields[i])
+				switch name {
+				case "start":
+					cue.Start, err = parseASSTime(field)
+				case "end":
+					cue.End, err = parseASSTime(field)
+				case "text":
+					cue.Text = cleanText(assText(fields[i]))
+				}
+				if err != nil {
+					return nil, fmt.Errorf("Invalid dialogue at line %d: %s", n+1, err)
+				}
+			}
+
+			if cue.Text != "" {
+				cues = append(cues, cue)
+			}
+		}
+	}
+
+	return cues, nil
+}
+
+func splitASSLine(line string) (string, string) {
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return "", ""
+	}
+	return strings.ToLower(strings.TrimSpace(line[:i])), strings.TrimSpace(line[i+1:])
+}
+
+func parseASSTime(s string) (time.Duration, error) {
+	m := assTime.FindStringSubmatch(s)
+	if m == nil {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return srtTime(m[1:5]), nil
+}
+
+// assText turns the ASS escapes for line breaks and hard spaces into text.
+// Override blocks are left for cleanText.
+func assText(text string) string {
+	text = strings.Replace(text, `\N`, "\n", -1)
+	text = strings.Replace(text, `\n`, "\n", -1)
+	return strings.Replace(text, `\h`, " ", -1)
+}
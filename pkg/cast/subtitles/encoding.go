@@ -0,0 +1,59 @@
+package subtitles
+
+import (
+	"bytes"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+)
+
+// windows1252 maps the bytes 0x80 to 0x9f of Windows-1252, the rest of which
+// matches Latin-1 and so the first 256 code points. Undefined bytes map to
+// the replacement character.
+var windows1252 = [32]rune{
+	'€', '�', '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', '�', 'Ž', '�',
+	'�', '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', '�', 'ž', 'Ÿ',
+}
+
+// decode guesses the encoding of a subtitle file. Files with a BOM are UTF-8
+// or UTF-16, and those without one are UTF-8 if they are valid UTF-8 and
+// Windows-1252, the most common legacy encoding for subtitles, otherwise.
+func decode(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return string(data[len(utf8BOM):])
+	case bytes.HasPrefix(data, utf16LEBOM):
+		return decodeUTF16(data[2:], false)
+	case bytes.HasPrefix(data, utf16BEBOM):
+		return decodeUTF16(data[2:], true)
+	case utf8.Valid(data):
+		return string(data)
+	}
+
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		if b >= 0x80 && b < 0xa0 {
+			runes[i] = windows1252[b-0x80]
+		} else {
+			runes[i] = rune(b)
+		}
+	}
+	return string(runes)
+}
+
+func decodeUTF16(data []byte, bigEndian bool) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+		} else {
+			units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+		}
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,101 @@
+package subtitles
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// File is a subtitle file found next to a video.
+type File struct {
+	Path   string
+	Format Format
+	// Language is taken from the file name, e.g. "pt-BR" for
+	// "movie.pt-BR.srt". It is empty if the name doesn't have one.
+	Language string
+	// Name is what tells the file apart from the other subtitles of the
+	// video, e.g. "pt-BR.forced" for "movie.pt-BR.forced.srt".
+	Name string
+}
+
+// Find returns the subtitle files in the directory of a video whose name
+// starts with the name of the video, like "movie.srt" or "movie.en.ass" for
+// "movie.mp4".
+func Find(videoPath string) ([]File, error) {
+	dir := filepath.Dir(videoPath)
+	base := filepath.Base(videoPath)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []File
+	for _, e := range entries {
+		name := e.Name()
+		format := FormatOf(name)
+		if e.IsDir() || format == FormatUnknown {
+			continue
+		}
+
+		stem := strings.TrimSuffix(name, filepath.Ext(name))
+		if len(stem) < len(base) || !strings.EqualFold(stem[:len(base)], base) {
+			continue
+		}
+
+		// What is left is empty or a suffix like ".en" or " - English".
+		rest := stem[len(base):]
+		if rest != "" && !strings.ContainsAny(rest[:1], " .-_") {
+			continue
+		}
+		label := strings.Trim(rest, " .-_")
+
+		files = append(files, File{
+			Path:     filepath.Join(dir, name),
+			Format:   format,
+			Language: language(label),
+			Name:     label,
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Path < files[j].Path
+	})
+	return files, nil
+}
+
+// language returns the first part of a label that looks like a language
+// tag, such as "en", "por" or "pt-BR".
+func language(label string) string {
+	for _, part := range strings.FieldsFunc(label, func(r rune) bool {
+		return r == '.' || r == '_' || r == ' '
+	}) {
+		if isLanguageTag(part) {
+			return part
+		}
+	}
+	return ""
+}
+
+func isLanguageTag(s string) bool {
+	parts := strings.Split(s, "-")
+	if len(parts) > 2 || len(parts[0]) < 2 || len(parts[0]) > 3 || !isLetters(parts[0]) {
+		return false
+	}
+	if len(parts) == 2 {
+		region := parts[1]
+		return len(region) >= 2 && len(region) <= 4 && isLetters(region)
+	}
+	return true
+}
+
+func isLetters(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,39 @@
+package subtitles
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"İstanbul.mp4",
+		"İstanbul.srt",
+		"İSTANBUL.pt-BR.forced.ass",
+		"İstanbul - English.vtt",
+		"İstanbul.en.txt",
+		"İstanbuls.srt",
+		"İst.srt",
+		"other.srt",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files, err := Find(filepath.Join(dir, "İstanbul.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []File{
+		{Path: filepath.Join(dir, "İSTANBUL.pt-BR.forced.ass"), Format: FormatASS, Language: "pt-BR", Name: "pt-BR.forced"},
+		{Path: filepath.Join(dir, "İstanbul - English.vtt"), Format: FormatWebVTT, Name: "English"},
+		{Path: filepath.Join(dir, "İstanbul.srt"), Format: FormatSRT},
+	}
+	if !reflect.DeepEqual(files, want) {
+		t.Errorf("got %+v, want %+v", files, want)
+	}
+}
@@ -0,0 +1,122 @@
+package subtitles
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// srtTiming matches the timing line of a cue, which WebVTT also uses, only
+// with a dot instead of a comma and optional hours.
+var srtTiming = regexp.MustCompile(`^\s*(?:(\d+):)?(\d{1,2}):(\d{1,2})[,.](\d{1,3})\s*-->\s*(?:(\d+):)?(\d{1,2}):(\d{1,2})[,.](\d{1,3})`)
+
+// parseSRT reads SRT and, since it is close enough, WebVTT. Anything that
+// isn't a cue, like indexes and WebVTT notes and styles, is skipped.
+func parseSRT(text string) ([]Cue, error) {
+	var cues []Cue
+	var cue *Cue
+	var lines []string
+
+	flush := func() {
+		if cue != nil {
+			cue.Text = cleanText(strings.Join(lines, "\n"))
+			if cue.Text != "" {
+				cues = append(cues, *cue)
+			}
+		}
+		cue, lines = nil, nil
+	}
+
+	for _, line := range splitLines(text) {
+		if strings.TrimSpace(line) == "" {
+			flush()
+		} else if m := srtTiming.FindStringSubmatch(line); m != nil {
+			flush()
+			cue = &Cue{
+				Start: srtTime(m[1:5]),
+				End:   srtTime(m[5:9]),
+			}
+		} else if cue != nil {
+			lines = append(lines, line)
+		}
+	}
+	flush()
+
+	return cues, nil
+}
+
+// srtTime converts the hours, minutes, seconds and milliseconds matched by
+// srtTiming.
+func srtTime(parts []string) time.Duration {
+	h, _ := strconv.Atoi(parts[0])
+	m, _ := strconv.Atoi(parts[1])
+	s, _ := strconv.Atoi(parts[2])
+	// "5" is 500ms, not 5ms.
+	ms, _ := strconv.Atoi((parts[3] + "00")[:3])
+	return time.Duration(h)*time.Hour +
+		time.Duration(m)*time.Minute +
+		time.Duration(s)*time.Second +
+		time.Duration(ms)*time.Millisecond
+}
+
+func splitLines(text string) []string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	return strings.Split(text, "\n")
+}
+
+var (
+	htmlTag     = regexp.MustCompile(`<\s*(/?)\s*([a-zA-Z]+)[^>]*>`)
+	assOverride = regexp.MustCompile(`\{[^}]*\}`)
+	entity      = regexp.MustCompile(`^&(?:amp|lt|gt|nbsp|lrm|rlm|#\d+|#x[0-9a-fA-F]+);`)
+)
+
+// cleanText keeps the bold, italic and underline tags of a cue and drops
+// every other style, like fonts, colors and ASS overrides, escaping what is
+// left so that it is valid WebVTT.
+func cleanText(text string) string {
+	text = assOverride.ReplaceAllString(text, "")
+
+	var b strings.Builder
+	last := 0
+	for _, m := range htmlTag.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(escape(text[last:m[0]]))
+		last = m[1]
+
+		switch name := strings.ToLower(text[m[4]:m[5]]); name {
+		case "b", "i", "u":
+			b.WriteString("<" + text[m[2]:m[3]] + name + ">")
+		}
+	}
+	b.WriteString(escape(text[last:]))
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// escape escapes the characters WebVTT gives a meaning to, leaving
+// entities alone.
+func escape(text string) string {
+	var b strings.Builder
+	for i, r := range text {
+		switch {
+		case r == '&' && entity.MatchString(text[i:]):
+			b.WriteRune(r)
+		case r == '&':
+			b.WriteString("&amp;")
+		case r == '<':
+			b.WriteString("&lt;")
+		case r == '>':
+			b.WriteString("&gt;")
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
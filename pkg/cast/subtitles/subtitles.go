@@ -0,0 +1,113 @@
+// Package subtitles converts subtitle files to WebVTT, the only format the
+// Default Media Receiver can show.
+package subtitles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Format int
+
+const (
+	FormatUnknown Format = iota
+	FormatSRT
+	FormatASS
+	FormatWebVTT
+)
+
+var UnknownFormat = errors.New("Unknown subtitle format")
+
+const ContentType = "text/vtt"
+
+// FormatOf returns the format of a subtitle file, going by its extension.
+func FormatOf(name string) Format {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".srt":
+		return FormatSRT
+	case ".ass", ".ssa":
+		return FormatASS
+	case ".vtt":
+		return FormatWebVTT
+	}
+	return FormatUnknown
+}
+
+// Cue is a piece of text shown between Start and End.
+type Cue struct {
+	Start time.Duration
+	End   time.Duration
+	// Text can have <b>, <i> and <u> tags, and nothing else.
+	Text string
+}
+
+// Parse reads the cues of a subtitle file. The text can be in UTF-8,
+// UTF-16 with a BOM, or Windows-1252.
+func Parse(data []byte, format Format) ([]Cue, error) {
+	text := decode(data)
+
+	var cues []Cue
+	var err error
+	switch format {
+	case FormatSRT, FormatWebVTT:
+		cues, err = parseSRT(text)
+	case FormatASS:
+		cues, err = parseASS(text)
+	default:
+		return nil, UnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(cues, func(i, j int) bool {
+		return cues[i].Start < cues[j].Start
+	})
+	return cues, nil
+}
+
+// Convert turns a subtitle file into WebVTT, delaying every cue by offset,
+// which may be negative.
+func Convert(data []byte, format Format, offset time.Duration) ([]byte, error) {
+	cues, err := Parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+	return WriteWebVTT(Shift(cues, offset)), nil
+}
+
+// Shift delays every cue by offset, dropping the ones that would end before
+// the start of the media.
+func Shift(cues []Cue, offset time.Duration) []Cue {
+	shifted := make([]Cue, 0, len(cues))
+	for _, c := range cues {
+		c.Start += offset
+		c.End += offset
+		if c.End <= 0 {
+			continue
+		} else if c.Start < 0 {
+			c.Start = 0
+		}
+		shifted = append(shifted, c)
+	}
+	return shifted
+}
+
+func WriteWebVTT(cues []Cue) []byte {
+	var b bytes.Buffer
+	b.WriteString("WEBVTT\n")
+	for _, c := range cues {
+		fmt.Fprintf(&b, "\n%s --> %s\n%s\n", vttTime(c.Start), vttTime(c.End), c.Text)
+	}
+	return b.Bytes()
+}
+
+func vttTime(d time.Duration) string {
+	ms := d.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", ms/3600000, ms/60000%60, ms/1000%60, ms%1000)
+}
@@ -0,0 +1,114 @@
+package subtitles
+
+import (
+	"reflect"
+	"testing"
+	"time"
+	"unicode/utf16"
+)
+
+func TestConvertSRT(t *testing.T) {
+	tests := []struct {
+		name, srt, vtt string
+	}{
+		{"timestamps",
+			"1\n00:00:01,000 --> 00:00:02,500\nHello\n\n2\n01:02:03,4 --> 01:02:05,45\nWorld\n",
+			"WEBVTT\n\n00:00:01.000 --> 00:00:02.500\nHello\n\n01:02:03.400 --> 01:02:05.450\nWorld\n"},
+		{"no hours", "1\n01:02,003 --> 01:03.000\nShort\n",
+			"WEBVTT\n\n00:01:02.003 --> 00:01:03.000\nShort\n"},
+		{"crlf", "1\r\n00:00:01,000 --> 00:00:02,000\r\nOne\r\nTwo\r\n\r\n",
+			"WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nOne\nTwo\n"},
+		{"bom", "\xef\xbb\xbf1\n00:00:01,000 --> 00:00:02,000\nBOM\n",
+			"WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nBOM\n"},
+		{"styling", "1\n00:00:01,000 --> 00:00:02,000\n<B>Bold</B> <font color=\"red\">red</font> <i >it</ i>\n{\\an8}<u>top</u> & 1 < 2 &amp;\n",
+			"WEBVTT\n\n00:00:01.000 --> 00:00:02.000\n<b>Bold</b> red <i>it</i>\n<u>top</u> &amp; 1 &lt; 2 &amp;\n"},
+		{"empty cue", "1\n00:00:01,000 --> 00:00:02,000\n<font></font>\n\n2\n00:00:03,000 --> 00:00:04,000\nKept\n",
+			"WEBVTT\n\n00:00:03.000 --> 00:00:04.000\nKept\n"},
+		{"unordered", "2\n00:00:03,000 --> 00:00:04,000\nSecond\n\n1\n00:00:01,000 --> 00:00:02,000\nFirst\n",
+			"WEBVTT\n\n00:00:01.000 --> 00:00:02.000\nFirst\n\n00:00:03.000 --> 00:00:04.000\nSecond\n"},
+	}
+	for _, test := range tests {
+		vtt, err := Convert([]byte(test.srt), FormatSRT, 0)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if string(vtt) != test.vtt {
+			t.Errorf("%s: got %q, want %q", test.name, vtt, test.vtt)
+		}
+	}
+}
+
+func TestConvertASS(t *testing.T) {
+	ass := "[Script Info]\r\nTitle: Test\r\n\r\n" +
+		"[V4+ Styles]\r\nFormat: Name, Fontname\r\nStyle: Default,Arial\r\n\r\n" +
+		"[Events]\r\n" +
+		"Format: Layer, Start, End, Style, Text\r\n" +
+		"Dialogue: 0,0:00:05.00,0:00:06.5,Default,{\\b1}Bold{\\b0}, with a comma\\Nand a break\r\n" +
+		"Comment: 0,0:00:07.00,0:00:08.00,Default,Not shown\r\n" +
+		"Dialogue: 0,1:00:00.25,1:00:01.00,Default,{\\i1}<i>hard\\hspace</i>\r\n"
+	want := "WEBVTT\n\n" +
+		"00:00:05.000 --> 00:00:06.500\nBold, with a comma\nand a break\n\n" +
+		"01:00:00.250 --> 01:00:01.000\n<i>hard\u00a0space</i>\n"
+
+	vtt, err := Convert([]byte(ass), FormatASS, 0)
+	if err != nil {
+		t.Fatal(err)
+	} else if string(vtt) != want {
+		t.Errorf("got %q, want %q", vtt, want)
+	}
+
+	for _, bad := range []string{
+		"[Events]\nFormat: Start, End, Text\nDialogue: 0:00:01.00\n",
+		"[Events]\nFormat: Start, End, Text\nDialogue: 1s,0:00:02.00,Text\n",
+	} {
+		if _, err := Convert([]byte(bad), FormatASS, 0); err == nil {
+			t.Errorf("converted %q", bad)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	utf16le := []byte{0xff, 0xfe}
+	utf16be := []byte{0xfe, 0xff}
+	for _, u := range utf16.Encode([]rune("Olá")) {
+		utf16le = append(utf16le, byte(u), byte(u>>8))
+		utf16be = append(utf16be, byte(u>>8), byte(u))
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{"utf-8", []byte("Olá"), "Olá"},
+		{"utf-8 bom", []byte("\xef\xbb\xbfOlá"), "Olá"},
+		{"utf-16le", utf16le, "Olá"},
+		{"utf-16be", utf16be, "Olá"},
+		{"windows-1252", []byte("Ol\xe1 \x93x\x94 \x80"), "Olá “x” €"},
+	}
+	for _, test := range tests {
+		if got := decode(test.data); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestShift(t *testing.T) {
+	cues := []Cue{
+		{Start: 1 * time.Second, End: 2 * time.Second, Text: "gone"},
+		{Start: 2 * time.Second, End: 4 * time.Second, Text: "cut"},
+		{Start: 5 * time.Second, End: 6 * time.Second, Text: "moved"},
+	}
+	want := []Cue{
+		{Start: 0, End: 1 * time.Second, Text: "cut"},
+		{Start: 2 * time.Second, End: 3 * time.Second, Text: "moved"},
+	}
+	if got := Shift(cues, -3*time.Second); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestParseUnknownFormat(t *testing.T) {
+	if _, err := Parse([]byte("WEBVTT"), FormatUnknown); err != UnknownFormat {
+		t.Errorf("got %v, want %v", err, UnknownFormat)
+	}
+}
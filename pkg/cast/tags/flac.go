@@ -0,0 +1,189 @@
+package tags
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	flacStreamInfo    = 0
+	flacVorbisComment = 4
+	flacPicture       = 6
+)
+
+func readFLAC(r io.ReadSeeker, t *Tags) error {
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return err
+	}
+
+	var pictureKind uint32
+	for {
+		header := make([]byte, 4)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+		last := header[0]&0x80 != 0
+		kind := header[0] & 0x7f
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		switch kind {
+		case flacStreamInfo, flacVorbisComment, flacPicture:
+			block, err := readFull(r, size)
+			if err != nil {
+				return err
+			}
+
+			switch kind {
+			case flacStreamInfo:
+				readFLACStreamInfo(block, t)
+			case flacVorbisComment:
+				readVorbisComment(block, t)
+			case flacPicture:
+				p, kind, err := parseFLACPicture(block)
+				if err == nil && (t.Picture == nil || kind == frontCover && pictureKind != frontCover) {
+					t.Picture, pictureKind = p, kind
+				}
+			}
+		default:
+			if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+				return err
+			}
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+func readFLACStreamInfo(block []byte, t *Tags) {
+	if len(block) < 18 {
+		return
+	}
+	// 20 bits of sample rate, 3 of channels, 5 of bits per sample and 36 of
+	// total samples, starting at byte 10.
+	bits := binary.BigEndian.Uint64(block[10:18])
+	sampleRate := bits >> 44
+	samples := bits & (1<<36 - 1)
+	if sampleRate > 0 && samples > 0 {
+		t.Duration = time.Duration(float64(samples) / float64(sampleRate) * float64(time.Second))
+	}
+}
+
+// readVorbisComment reads a Vorbis comment block, a list of KEY=value
+// pairs whose lengths, unlike the rest of FLAC, are little endian.
+func readVorbisComment(block []byte, t *Tags) {
+	next := func() (string, bool) {
+		if len(block) < 4 {
+			return "", false
+		}
+		n := binary.LittleEndian.Uint32(block)
+		block = block[4:]
+		if uint64(n) > uint64(len(block)) {
+			return "", false
+		}
+		s := string(block[:n])
+		block = block[n:]
+		return s, true
+	}
+
+	// The vendor string.
+	if _, ok := next(); !ok || len(block) < 4 {
+		return
+	}
+	count := binary.LittleEndian.Uint32(block)
+	block = block[4:]
+
+	for i := uint32(0); i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return
+		}
+		eq := strings.IndexByte(comment, '=')
+		if eq < 0 {
+			continue
+		}
+		setVorbisField(t, strings.ToUpper(comment[:eq]), strings.TrimSpace(comment[eq+1:]))
+	}
+}
+
+func setVorbisField(t *Tags, key, value string) {
+	switch key {
+	case "TITLE":
+		t.Title = value
+	case "ARTIST":
+		t.Artist = value
+	case "ALBUM":
+		t.Album = value
+	case "ALBUMARTIST", "ALBUM ARTIST":
+		t.AlbumArtist = value
+	case "COMPOSER":
+		t.Composer = value
+	case "GENRE":
+		t.Genre = value
+	case "DATE", "YEAR":
+		t.Date = value
+	case "TRACKNUMBER":
+		t.TrackNumber = parseNumber(value)
+	case "DISCNUMBER":
+		t.DiscNumber = parseNumber(value)
+	}
+}
+
+var invalidPicture = errors.New("invalid picture block")
+
+func parseFLACPicture(block []byte) (*Picture, uint32, error) {
+	next := func(n uint64) ([]byte, bool) {
+		if n > uint64(len(block)) {
+			return nil, false
+		}
+		b := block[:n]
+		block = block[n:]
+		return b, true
+	}
+	number := func() (uint32, bool) {
+		b, ok := next(4)
+		if !ok {
+			return 0, false
+		}
+		return binary.BigEndian.Uint32(b), true
+	}
+
+	kind, ok := number()
+	if !ok {
+		return nil, 0, invalidPicture
+	}
+	n, ok := number()
+	if !ok {
+		return nil, 0, invalidPicture
+	}
+	mimeType, ok := next(uint64(n))
+	if !ok {
+		return nil, 0, invalidPicture
+	}
+	n, ok = number()
+	if !ok {
+		return nil, 0, invalidPicture
+	}
+	// Skip the description, width, height, depth and number of colors.
+	if _, ok := next(uint64(n) + 16); !ok {
+		return nil, 0, invalidPicture
+	}
+	n, ok = number()
+	if !ok {
+		return nil, 0, invalidPicture
+	}
+	data, ok := next(uint64(n))
+	if !ok {
+		return nil, 0, invalidPicture
+	}
+
+	p := &Picture{MIMEType: string(mimeType), Data: data}
+	if !strings.Contains(p.MIMEType, "/") {
+		p.MIMEType = detectPictureType(data)
+	}
+	return p, kind, nil
+}
@@ -0,0 +1,109 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func flacBlock(kind byte, last bool, data []byte) []byte {
+	if last {
+		kind |= 0x80
+	}
+	n := len(data)
+	return cat([]byte{kind, byte(n >> 16), byte(n >> 8), byte(n)}, data)
+}
+
+func streamInfo(sampleRate, samples uint64) []byte {
+	b := make([]byte, 34)
+	// Stereo, 16 bits per sample.
+	binary.BigEndian.PutUint64(b[10:], sampleRate<<44|1<<41|15<<36|samples)
+	return b
+}
+
+func le32(n int) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(n))
+	return b
+}
+
+func vorbisComment(comments ...string) []byte {
+	b := cat(le32(6), []byte("vendor"), le32(len(comments)))
+	for _, c := range comments {
+		b = cat(b, le32(len(c)), []byte(c))
+	}
+	return b
+}
+
+func flacPictureBlock(kind uint32, mimeType string, data []byte) []byte {
+	return cat(be32(kind), be32(uint32(len(mimeType))), []byte(mimeType),
+		be32(5), []byte("cover"), make([]byte, 16), be32(uint32(len(data))), data)
+}
+
+func testFLAC() []byte {
+	return cat(
+		[]byte("fLaC"),
+		flacBlock(flacStreamInfo, false, streamInfo(44100, 88200)),
+		// Padding.
+		flacBlock(1, false, make([]byte, 8)),
+		flacBlock(flacPicture, false, flacPictureBlock(0, "image/png", png)),
+		flacBlock(flacVorbisComment, false, vorbisComment(
+			"title=Title",
+			"ARTIST=Artist",
+			"ALBUM=Album",
+			"ALBUM ARTIST=Band",
+			"COMPOSER=Composer",
+			"GENRE=Genre",
+			"DATE=2006",
+			"TRACKNUMBER=03/12",
+			"DISCNUMBER=1",
+			"no equals sign",
+		)),
+		flacBlock(flacPicture, false, flacPictureBlock(frontCover, "", jpeg)),
+		flacBlock(flacPicture, true, flacPictureBlock(4, "image/gif", []byte("GIF89a"))),
+		[]byte{0xff, 0xf8},
+	)
+}
+
+func TestReadFLAC(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Tags
+	}{
+		{"tags", testFLAC(), Tags{
+			Title:       "Title",
+			Artist:      "Artist",
+			Album:       "Album",
+			AlbumArtist: "Band",
+			Composer:    "Composer",
+			Genre:       "Genre",
+			Date:        "2006",
+			TrackNumber: 3,
+			DiscNumber:  1,
+			Duration:    2 * time.Second,
+			Picture:     &Picture{MIMEType: "image/jpeg", Data: jpeg},
+		}},
+		{"short comment", cat([]byte("fLaC"),
+			flacBlock(flacVorbisComment, false, cat(vorbisComment("TITLE=Kept", "ARTIST=Lost")[:30], le32(100))),
+			flacBlock(flacPicture, true, flacPictureBlock(frontCover, "image/png", png)[:20]),
+		), Tags{Title: "Kept"}},
+		{"unknown length", cat([]byte("fLaC"), flacBlock(flacStreamInfo, true, streamInfo(44100, 0))), Tags{}},
+	}
+	for _, test := range tests {
+		tags, err := ReadFrom(bytes.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if !reflect.DeepEqual(*tags, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, *tags, test.want)
+		}
+	}
+
+	// Without a last block, the file ends too early.
+	data := cat([]byte("fLaC"), flacBlock(flacStreamInfo, false, streamInfo(44100, 88200)))
+	if _, err := ReadFrom(bytes.NewReader(data)); err == nil {
+		t.Error("read a FLAC file without its last block")
+	}
+}
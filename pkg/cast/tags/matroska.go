@@ -0,0 +1,407 @@
+package tags
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Matroska element ids, with their length marker bits.
+const (
+	mkvSegment        = 0x18538067
+	mkvInfo           = 0x1549a966
+	mkvTimecodeScale  = 0x2ad7b1
+	mkvDuration       = 0x4489
+	mkvTitle          = 0x7ba9
+	mkvTracks         = 0x1654ae6b
+	mkvTrackEntry     = 0xae
+	mkvTrackType      = 0x83
+	mkvTags           = 0x1254c367
+	mkvTag            = 0x7373
+	mkvTargets        = 0x63c0
+	mkvTargetType     = 0x68ca
+	mkvSimpleTag      = 0x67c8
+	mkvTagName        = 0x45a3
+	mkvTagString      = 0x4487
+	mkvAttachments    = 0x1941a469
+	mkvAttachedFile   = 0x61a7
+	mkvFileName       = 0x466e
+	mkvFileMimeType   = 0x4660
+	mkvFileData       = 0x465c
+	mkvTrackTypeVideo = 1
+	// Tags aimed at an album (or season) rather than at the track (or
+	// episode) itself have a target type over this.
+	mkvTargetTrack = 30
+)
+
+// unknownSize is the size of elements written before their size was known,
+// as live streams do.
+const unknownSize = -1
+
+var invalidEBML = errors.New("invalid EBML")
+
+type ebmlReader struct {
+	r io.ReadSeeker
+}
+
+// element reads the id and size of the next element.
+func (e *ebmlReader) element() (id uint32, size int64, err error) {
+	v, n, err := e.vint()
+	if err != nil {
+		return 0, 0, err
+	}
+	// Ids keep their length marker.
+	id = uint32(v | 1<<(7*uint(n)))
+
+	v, n, err = e.vint()
+	if err != nil {
+		return 0, 0, err
+	}
+	if v == 1<<(7*uint(n))-1 {
+		return id, unknownSize, nil
+	}
+	return id, int64(v), nil
+}
+
+// vint reads a variable length integer, returning it without its length
+// marker, along with its length.
+func (e *ebmlReader) vint() (uint64, int, error) {
+	b := make([]byte, 1)
+	if _, err := io.ReadFull(e.r, b); err != nil {
+		return 0, 0, err
+	}
+
+	n := 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		n++
+		if n > 8 {
+			return 0, 0, invalidEBML
+		}
+	}
+
+	v := uint64(b[0] & (0xff >> uint(n)))
+	if n > 1 {
+		rest := make([]byte, n-1)
+		if _, err := io.ReadFull(e.r, rest); err != nil {
+			return 0, 0, err
+		}
+		for _, c := range rest {
+			v = v<<8 | uint64(c)
+		}
+	}
+	return v, n, nil
+}
+
+func (e *ebmlReader) position() (int64, error) {
+	return e.r.Seek(0, io.SeekCurrent)
+}
+
+func (e *ebmlReader) uint(size int64) (uint64, error) {
+	if size > 8 {
+		return 0, invalidEBML
+	}
+	b, err := readFull(e.r, size)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+func (e *ebmlReader) float(size int64) (float64, error) {
+	b, err := readFull(e.r, size)
+	if err != nil {
+		return 0, err
+	}
+	switch size {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	}
+	return 0, invalidEBML
+}
+
+func (e *ebmlReader) string(size int64) (string, error) {
+	b, err := readFull(e.r, size)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\x00"), nil
+}
+
+// children calls fn for each child of the element whose body ends at end,
+// skipping over whatever fn leaves unread.
+func (e *ebmlReader) children(end int64, fn func(id uint32, size int64) error) error {
+	for {
+		start, err := e.position()
+		if err != nil {
+			return err
+		}
+		if end >= 0 && start >= end {
+			return nil
+		}
+
+		id, size, err := e.element()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		body, err := e.position()
+		if err != nil {
+			return err
+		}
+
+		if size == unknownSize && id != mkvSegment {
+			// We can't skip it, so we can't read past it either. These are
+			// the clusters of a live stream, which come last anyway.
+			return nil
+		}
+
+		if err := fn(id, size); err != nil {
+			return err
+		}
+
+		if size != unknownSize {
+			if _, err := e.r.Seek(body+size, io.SeekStart); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func readMatroska(r io.ReadSeeker, t *Tags) error {
+	e := &ebmlReader{r: r}
+	m := &matroska{timecodeScale: 1000000}
+
+	err := e.children(-1, func(id uint32, size int64) error {
+		if id != mkvSegment {
+			return nil
+		}
+		end := int64(-1)
+		if size != unknownSize {
+			body, err := e.position()
+			if err != nil {
+				return err
+			}
+			end = body + size
+		}
+		return e.children(end, func(id uint32, size int64) error {
+			return m.readSegmentChild(e, id, size)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	m.apply(t)
+	return nil
+}
+
+type matroska struct {
+	timecodeScale uint64
+	duration      float64
+	title         string
+	hasVideo      bool
+	// tags holds the track level tags, and albumTags the ones about the
+	// album, season or collection.
+	tags      map[string]string
+	albumTags map[string]string
+	cover     *Picture
+}
+
+func (m *matroska) readSegmentChild(e *ebmlReader, id uint32, size int64) error {
+	body, err := e.position()
+	if err != nil {
+		return err
+	}
+	end := body + size
+
+	switch id {
+	case mkvInfo:
+		return e.children(end, func(id uint32, size int64) (err error) {
+			switch id {
+			case mkvTimecodeScale:
+				m.timecodeScale, err = e.uint(size)
+			case mkvDuration:
+				m.duration, err = e.float(size)
+			case mkvTitle:
+				m.title, err = e.string(size)
+			}
+			return err
+		})
+	case mkvTracks:
+		return e.children(end, func(id uint32, size int64) error {
+			if id != mkvTrackEntry {
+				return nil
+			}
+			body, err := e.position()
+			if err != nil {
+				return err
+			}
+			return e.children(body+size, func(id uint32, size int64) error {
+				if id != mkvTrackType {
+					return nil
+				}
+				kind, err := e.uint(size)
+				if kind == mkvTrackTypeVideo {
+					m.hasVideo = true
+				}
+				return err
+			})
+		})
+	case mkvTags:
+		return e.children(end, func(id uint32, size int64) error {
+			if id != mkvTag {
+				return nil
+			}
+			body, err := e.position()
+			if err != nil {
+				return err
+			}
+			return m.readTag(e, body+size)
+		})
+	case mkvAttachments:
+		return e.children(end, func(id uint32, size int64) error {
+			if id != mkvAttachedFile {
+				return nil
+			}
+			body, err := e.position()
+			if err != nil {
+				return err
+			}
+			return m.readAttachment(e, body+size)
+		})
+	}
+	return nil
+}
+
+func (m *matroska) readTag(e *ebmlReader, end int64) error {
+	target := uint64(50)
+	tags := make(map[string]string)
+
+	err := e.children(end, func(id uint32, size int64) error {
+		body, err := e.position()
+		if err != nil {
+			return err
+		}
+		switch id {
+		case mkvTargets:
+			return e.children(body+size, func(id uint32, size int64) (err error) {
+				if id == mkvTargetType {
+					target, err = e.uint(size)
+				}
+				return err
+			})
+		case mkvSimpleTag:
+			var name, value string
+			err := e.children(body+size, func(id uint32, size int64) (err error) {
+				switch id {
+				case mkvTagName:
+					name, err = e.string(size)
+				case mkvTagString:
+					value, err = e.string(size)
+				}
+				return err
+			})
+			if name != "" && value != "" {
+				tags[strings.ToUpper(name)] = value
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	dest := &m.tags
+	if target > mkvTargetTrack {
+		dest = &m.albumTags
+	}
+	if *dest == nil {
+		*dest = make(map[string]string)
+	}
+	for k, v := range tags {
+		(*dest)[k] = v
+	}
+	return nil
+}
+
+// readAttachment keeps the first attached image, preferring one named like a
+// cover.
+func (m *matroska) readAttachment(e *ebmlReader, end int64) error {
+	var name, mimeType string
+	var dataStart, dataSize int64 = -1, 0
+
+	err := e.children(end, func(id uint32, size int64) (err error) {
+		switch id {
+		case mkvFileName:
+			name, err = e.string(size)
+		case mkvFileMimeType:
+			mimeType, err = e.string(size)
+		case mkvFileData:
+			// Read the data only if we want it.
+			dataStart, err = e.position()
+			dataSize = size
+		}
+		return err
+	})
+	if err != nil || dataStart < 0 || dataSize > maxElementSize || !strings.HasPrefix(mimeType, "image/") {
+		return err
+	}
+
+	isCover := strings.HasPrefix(strings.ToLower(name), "cover")
+	if m.cover != nil && !isCover {
+		return nil
+	}
+
+	if _, err := e.r.Seek(dataStart, io.SeekStart); err != nil {
+		return err
+	}
+	data, err := readFull(e.r, dataSize)
+	if err != nil {
+		return err
+	}
+	m.cover = &Picture{MIMEType: mimeType, Data: data}
+	return nil
+}
+
+func (m *matroska) apply(t *Tags) {
+	t.HasVideo = m.hasVideo
+	t.Picture = m.cover
+	if m.duration > 0 {
+		t.Duration = time.Duration(m.duration * float64(m.timecodeScale))
+	}
+
+	get := func(name string) string {
+		if v := m.tags[name]; v != "" {
+			return v
+		}
+		return m.albumTags[name]
+	}
+
+	t.Title = get("TITLE")
+	if t.Title == "" {
+		t.Title = m.title
+	}
+	t.Artist = get("ARTIST")
+	t.Composer = get("COMPOSER")
+	t.Genre = get("GENRE")
+	t.Date = get("DATE_RELEASED")
+	if t.Date == "" {
+		t.Date = get("DATE_RECORDED")
+	}
+	t.TrackNumber = parseNumber(m.tags["PART_NUMBER"])
+	// The title and artist of the album are the ones of the upper level.
+	t.Album = m.albumTags["TITLE"]
+	t.AlbumArtist = m.albumTags["ARTIST"]
+}
@@ -0,0 +1,152 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// ebml encodes an element, writing its size with eight bytes, which is
+// valid for any size.
+func ebml(id uint32, body ...[]byte) []byte {
+	b := cat(body...)
+	size := make([]byte, 8)
+	binary.BigEndian.PutUint64(size, uint64(len(b)))
+	size[0] = 0x01
+	return cat(ebmlID(id), size, b)
+}
+
+func ebmlID(id uint32) []byte {
+	b := be32(id)
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	return b
+}
+
+func ebmlUint(id uint32, v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return ebml(id, b)
+}
+
+func ebmlFloat(id uint32, v float64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, math.Float64bits(v))
+	return ebml(id, b)
+}
+
+func ebmlString(id uint32, s string) []byte {
+	return ebml(id, []byte(s))
+}
+
+var ebmlHeader = ebml(0x1a45dfa3, ebmlString(0x4282, "matroska"))
+
+func simpleTag(name, value string) []byte {
+	return ebml(mkvSimpleTag, ebmlString(mkvTagName, name), ebmlString(mkvTagString, value))
+}
+
+func attachment(name, mimeType string, data []byte) []byte {
+	return ebml(mkvAttachedFile,
+		ebmlString(mkvFileName, name),
+		ebmlString(mkvFileMimeType, mimeType),
+		ebml(mkvFileData, data))
+}
+
+func testMatroska() []byte {
+	return cat(ebmlHeader, ebml(mkvSegment,
+		ebml(mkvInfo,
+			ebmlUint(mkvTimecodeScale, 1000000),
+			ebmlFloat(mkvDuration, 1500),
+			ebmlString(mkvTitle, "Segment title\x00\x00"),
+		),
+		ebml(mkvTracks,
+			ebml(mkvTrackEntry, ebmlUint(mkvTrackType, 2)),
+			ebml(mkvTrackEntry, ebmlUint(mkvTrackType, mkvTrackTypeVideo)),
+		),
+		ebml(mkvTags,
+			ebml(mkvTag,
+				ebml(mkvTargets, ebmlUint(mkvTargetType, 50)),
+				simpleTag("TITLE", "Album"),
+				simpleTag("ARTIST", "Band"),
+				simpleTag("GENRE", "Genre"),
+			),
+			ebml(mkvTag,
+				ebml(mkvTargets, ebmlUint(mkvTargetType, 30)),
+				simpleTag("title", "Title"),
+				simpleTag("ARTIST", "Artist"),
+				simpleTag("DATE_RECORDED", "2006"),
+				simpleTag("PART_NUMBER", "3"),
+			),
+		),
+		ebml(mkvAttachments,
+			attachment("font.ttf", "application/x-truetype-font", []byte("font")),
+			attachment("back.png", "image/png", png),
+			attachment("Cover.jpg", "image/jpeg", jpeg),
+		),
+	))
+}
+
+func TestReadMatroska(t *testing.T) {
+	// A segment of unknown size, as live streams have, which ends at a
+	// cluster of unknown size.
+	live := cat(ebmlHeader,
+		ebmlID(mkvSegment), []byte{0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		ebml(mkvInfo, ebmlString(mkvTitle, "Live")),
+		ebmlID(0x1f43b675), []byte{0xff},
+		ebml(mkvInfo, ebmlString(mkvTitle, "Unreachable")),
+	)
+
+	tests := []struct {
+		name string
+		data []byte
+		want Tags
+	}{
+		{"tags", testMatroska(), Tags{
+			Title:       "Title",
+			Artist:      "Artist",
+			Album:       "Album",
+			AlbumArtist: "Band",
+			Genre:       "Genre",
+			Date:        "2006",
+			TrackNumber: 3,
+			Duration:    1500 * time.Millisecond,
+			Picture:     &Picture{MIMEType: "image/jpeg", Data: jpeg},
+			HasVideo:    true,
+		}},
+		{"segment title", cat(ebmlHeader, ebml(mkvSegment,
+			ebml(mkvInfo, ebmlString(mkvTitle, "Segment title"), ebmlUint(mkvTimecodeScale, 1000)),
+			ebml(mkvInfo, ebml(mkvDuration, be32(math.Float32bits(2000)))),
+		)), Tags{Title: "Segment title", Duration: 2 * time.Millisecond}},
+		{"live", live, Tags{Title: "Live"}},
+	}
+	for _, test := range tests {
+		tags, err := ReadFrom(bytes.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if !reflect.DeepEqual(*tags, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, *tags, test.want)
+		}
+	}
+}
+
+func TestReadMatroskaErrors(t *testing.T) {
+	tests := map[string][]byte{
+		"zero length marker": cat(ebmlHeader, []byte{0x00, 0x81}),
+		"uint too long":      cat(ebmlHeader, ebml(mkvSegment, ebml(mkvInfo, ebml(mkvTimecodeScale, make([]byte, 9))))),
+		"bad float":          cat(ebmlHeader, ebml(mkvSegment, ebml(mkvInfo, ebml(mkvDuration, make([]byte, 3))))),
+		// A 128MiB title.
+		"too large": cat(ebmlHeader,
+			ebmlID(mkvSegment), []byte{0x01, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+			ebmlID(mkvInfo), []byte{0x86},
+			ebmlID(mkvTitle), []byte{0x18, 0x00, 0x00, 0x00}),
+	}
+	for name, data := range tests {
+		if _, err := ReadFrom(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: read it", name)
+		}
+	}
+}
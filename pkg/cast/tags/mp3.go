@@ -0,0 +1,487 @@
+package tags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// id3v22Frames maps the three letter frame ids of ID3v2.2 to the ones
+// that replaced them.
+var id3v22Frames = map[string]string{
+	"TT2": "TIT2",
+	"TP1": "TPE1",
+	"TP2": "TPE2",
+	"TAL": "TALB",
+	"TCM": "TCOM",
+	"TCO": "TCON",
+	"TRK": "TRCK",
+	"TPA": "TPOS",
+	"TYE": "TYER",
+	"TLE": "TLEN",
+	"PIC": "APIC",
+}
+
+// frontCover is the picture type of the front cover in ID3 and FLAC.
+const frontCover = 3
+
+func readMP3(r io.ReadSeeker, t *Tags) error {
+	start, err := readID3v2(r, t)
+	if err != nil {
+		return err
+	}
+
+	if t.Duration == 0 {
+		t.Duration, err = mpegDuration(r, start)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readID3v2 reads the ID3v2 tag at the start of r, if there is one, and
+// returns where the audio starts.
+func readID3v2(r io.ReadSeeker, t *Tags) (int64, error) {
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, err
+	}
+	if !bytes.HasPrefix(header, []byte("ID3")) {
+		return 0, nil
+	}
+
+	version := header[3]
+	flags := header[5]
+	size := int64(syncsafe(header[6:10]))
+	end := 10 + size
+	if flags&0x10 != 0 {
+		// There is a footer.
+		end += 10
+	}
+
+	body, err := readFull(r, size)
+	if err != nil {
+		return 0, err
+	}
+
+	if flags&0x80 != 0 && version < 4 {
+		body = removeUnsync(body)
+	}
+
+	if flags&0x40 != 0 && len(body) >= 4 {
+		// Skip the extended header.
+		var n int
+		if version == 4 {
+			n = int(syncsafe(body[:4]))
+		} else {
+			n = int(binary.BigEndian.Uint32(body[:4])) + 4
+		}
+		if n > len(body) {
+			return end, nil
+		}
+		body = body[n:]
+	}
+
+	var pictures []id3Picture
+	for len(body) > 0 {
+		id, data, rest, ok := nextID3Frame(body, version)
+		if !ok {
+			break
+		}
+		body = rest
+
+		if version == 2 {
+			id = id3v22Frames[id]
+		}
+
+		switch id {
+		case "TIT2":
+			t.Title = id3Text(data)
+		case "TPE1":
+			t.Artist = id3Text(data)
+		case "TPE2":
+			t.AlbumArtist = id3Text(data)
+		case "TALB":
+			t.Album = id3Text(data)
+		case "TCOM":
+			t.Composer = id3Text(data)
+		case "TCON":
+			t.Genre = id3Genre(id3Text(data))
+		case "TRCK":
+			t.TrackNumber = parseNumber(id3Text(data))
+		case "TPOS":
+			t.DiscNumber = parseNumber(id3Text(data))
+		case "TDRC", "TYER":
+			if t.Date == "" || id == "TDRC" {
+				t.Date = id3Text(data)
+			}
+		case "TLEN":
+			if ms := parseNumber(id3Text(data)); ms > 0 {
+				t.Duration = time.Duration(ms) * time.Millisecond
+			}
+		case "APIC":
+			if p, ok := parseID3Picture(data, version); ok {
+				pictures = append(pictures, p)
+			}
+		}
+	}
+
+	for _, p := range pictures {
+		if t.Picture == nil || p.kind == frontCover {
+			t.Picture = p.Picture
+			if p.kind == frontCover {
+				break
+			}
+		}
+	}
+
+	return end, nil
+}
+
+// nextID3Frame splits the first frame off body. It skips the frames we
+// can't read, which are compressed or encrypted, returning them with no
+// data.
+func nextID3Frame(body []byte, version byte) (id string, data, rest []byte, ok bool) {
+	headerSize := 10
+	if version == 2 {
+		headerSize = 6
+	}
+	if len(body) < headerSize || body[0] == 0 {
+		// Padding.
+		return "", nil, nil, false
+	}
+
+	var size int
+	var flags uint16
+	switch version {
+	case 2:
+		id = string(body[:3])
+		size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+	case 3:
+		id = string(body[:4])
+		size = int(binary.BigEndian.Uint32(body[4:8]))
+		flags = binary.BigEndian.Uint16(body[8:10])
+	default:
+		id = string(body[:4])
+		size = int(syncsafe(body[4:8]))
+		flags = binary.BigEndian.Uint16(body[8:10])
+	}
+
+	if size < 0 || headerSize+size > len(body) {
+		return "", nil, nil, false
+	}
+	data = body[headerSize : headerSize+size]
+	rest = body[headerSize+size:]
+
+	switch version {
+	case 3:
+		if flags&0xc0 != 0 {
+			return id, nil, rest, true
+		}
+		if flags&0x20 != 0 && len(data) > 0 {
+			data = data[1:]
+		}
+	case 4:
+		if flags&0x0c != 0 {
+			return id, nil, rest, true
+		}
+		if flags&0x40 != 0 && len(data) > 0 {
+			// Grouping identity.
+			data = data[1:]
+		}
+		if flags&0x01 != 0 && len(data) >= 4 {
+			// Data length indicator.
+			data = data[4:]
+		}
+		if flags&0x02 != 0 {
+			data = removeUnsync(data)
+		}
+	}
+
+	return id, data, rest, true
+}
+
+func syncsafe(b []byte) uint32 {
+	return uint32(b[0]&0x7f)<<21 | uint32(b[1]&0x7f)<<14 | uint32(b[2]&0x7f)<<7 | uint32(b[3]&0x7f)
+}
+
+// removeUnsync undoes the unsynchronisation scheme, which inserts a zero
+// after every 0xff.
+func removeUnsync(b []byte) []byte {
+	return bytes.Replace(b, []byte{0xff, 0x00}, []byte{0xff}, -1)
+}
+
+// id3Text decodes the first string of a text frame.
+func id3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	s, _ := id3String(data[0], data[1:])
+	return strings.TrimSpace(s)
+}
+
+// id3String decodes a null terminated string in the given encoding, and
+// returns what follows it.
+func id3String(encoding byte, data []byte) (string, []byte) {
+	switch encoding {
+	case 1, 2:
+		// UTF-16, the terminator is two aligned zero bytes.
+		end := len(data)
+		for i := 0; i+1 < len(data); i += 2 {
+			if data[i] == 0 && data[i+1] == 0 {
+				end = i
+				break
+			}
+		}
+		rest := data[end:]
+		if len(rest) >= 2 {
+			rest = rest[2:]
+		}
+		return decodeUTF16(data[:end], encoding == 2), rest
+	}
+
+	end := bytes.IndexByte(data, 0)
+	rest := []byte{}
+	if end < 0 {
+		end = len(data)
+	} else {
+		rest = data[end+1:]
+	}
+
+	if encoding == 0 {
+		return latin1(data[:end]), rest
+	}
+	return string(data[:end]), rest
+}
+
+// decodeUTF16 decodes UTF-16 text, which is in big endian unless it starts
+// with a byte order mark saying otherwise.
+func decodeUTF16(b []byte, bigEndian bool) string {
+	if len(b) >= 2 {
+		switch {
+		case b[0] == 0xff && b[1] == 0xfe:
+			b, bigEndian = b[2:], false
+		case b[0] == 0xfe && b[1] == 0xff:
+			b, bigEndian = b[2:], true
+		}
+	}
+
+	units := make([]uint16, len(b)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = binary.BigEndian.Uint16(b[2*i:])
+		} else {
+			units[i] = binary.LittleEndian.Uint16(b[2*i:])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+func latin1(b []byte) string {
+	runes := make([]rune, len(b))
+	for i, c := range b {
+		runes[i] = rune(c)
+	}
+	return string(runes)
+}
+
+var id3GenreRef = regexp.MustCompile(`^\((\d+)\)`)
+
+// id3Genre drops the references to numbered genres that old taggers put
+// before the genre name, like "(17)Rock".
+func id3Genre(s string) string {
+	if rest := id3GenreRef.ReplaceAllString(s, ""); rest != "" {
+		return rest
+	}
+	return s
+}
+
+type id3Picture struct {
+	*Picture
+	kind byte
+}
+
+func parseID3Picture(data []byte, version byte) (id3Picture, bool) {
+	if len(data) < 2 {
+		return id3Picture{}, false
+	}
+	encoding := data[0]
+	data = data[1:]
+
+	var mimeType string
+	if version == 2 {
+		if len(data) < 3 {
+			return id3Picture{}, false
+		}
+		mimeType = "image/" + strings.ToLower(string(data[:3]))
+		if mimeType == "image/jpg" {
+			mimeType = "image/jpeg"
+		}
+		data = data[3:]
+	} else {
+		mimeType, data = id3String(0, data)
+	}
+
+	if len(data) < 1 {
+		return id3Picture{}, false
+	}
+	kind := data[0]
+	_, data = id3String(encoding, data[1:])
+	if len(data) == 0 {
+		return id3Picture{}, false
+	}
+
+	if mimeType == "" || !strings.Contains(mimeType, "/") {
+		mimeType = detectPictureType(data)
+	}
+
+	return id3Picture{
+		Picture: &Picture{MIMEType: mimeType, Data: data},
+		kind:    kind,
+	}, true
+}
+
+var mpegBitrates = [2][3][16]int{
+	// MPEG 1, layers I, II and III.
+	{
+		{0, 32, 64, 96, 128, 160, 192, 224, 256, 288, 320, 352, 384, 416, 448},
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320, 384},
+		{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320},
+	},
+	// MPEG 2 and 2.5.
+	{
+		{0, 32, 48, 56, 64, 80, 96, 112, 128, 144, 160, 176, 192, 224, 256},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+		{0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160},
+	},
+}
+
+var mpegSampleRates = map[byte][3]int{
+	3: {44100, 48000, 32000}, // MPEG 1
+	2: {22050, 24000, 16000}, // MPEG 2
+	0: {11025, 12000, 8000},  // MPEG 2.5
+}
+
+type mpegFrame struct {
+	version    byte
+	layer      int
+	bitrate    int
+	sampleRate int
+	mono       bool
+}
+
+func isMPEGFrame(b []byte) bool {
+	_, ok := parseMPEGFrame(b)
+	return ok
+}
+
+func parseMPEGFrame(b []byte) (mpegFrame, bool) {
+	if len(b) < 4 || b[0] != 0xff || b[1]&0xe0 != 0xe0 {
+		return mpegFrame{}, false
+	}
+
+	f := mpegFrame{version: b[1] >> 3 & 3}
+	layerBits := b[1] >> 1 & 3
+	bitrateIndex := b[2] >> 4
+	rateIndex := b[2] >> 2 & 3
+	if f.version == 1 || layerBits == 0 || bitrateIndex == 0 || bitrateIndex == 15 || rateIndex == 3 {
+		return mpegFrame{}, false
+	}
+
+	f.layer = int(4 - layerBits)
+	table := 0
+	if f.version != 3 {
+		table = 1
+	}
+	f.bitrate = mpegBitrates[table][f.layer-1][bitrateIndex] * 1000
+	f.sampleRate = mpegSampleRates[f.version][rateIndex]
+	f.mono = b[3]>>6 == 3
+	return f, true
+}
+
+func (f mpegFrame) samples() int {
+	switch {
+	case f.layer == 1:
+		return 384
+	case f.layer == 3 && f.version != 3:
+		return 576
+	}
+	return 1152
+}
+
+// mpegDuration finds the first MPEG audio frame after start and works out
+// the duration from the frame count in its Xing or VBRI header or, for
+// constant bitrate files, from the size of the file.
+func mpegDuration(r io.ReadSeeker, start int64) (time.Duration, error) {
+	fileSize, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := r.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 64*1024)
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return 0, nil
+	}
+	buf = buf[:n]
+
+	for i := 0; i+4 <= len(buf); i++ {
+		f, ok := parseMPEGFrame(buf[i:])
+		if !ok {
+			continue
+		}
+		frame := buf[i:]
+
+		sideInfo := 32
+		switch {
+		case f.version == 3 && f.mono:
+			sideInfo = 17
+		case f.version != 3 && !f.mono:
+			sideInfo = 17
+		case f.version != 3:
+			sideInfo = 9
+		}
+
+		var frames uint32
+		if x := 4 + sideInfo; len(frame) >= x+12 && (bytes.Equal(frame[x:x+4], []byte("Xing")) || bytes.Equal(frame[x:x+4], []byte("Info"))) {
+			if binary.BigEndian.Uint32(frame[x+4:])&1 != 0 {
+				frames = binary.BigEndian.Uint32(frame[x+8:])
+			}
+		} else if v := 4 + 32; len(frame) >= v+18 && bytes.Equal(frame[v:v+4], []byte("VBRI")) {
+			frames = binary.BigEndian.Uint32(frame[v+14:])
+		}
+
+		if frames > 0 {
+			seconds := float64(frames) * float64(f.samples()) / float64(f.sampleRate)
+			return time.Duration(seconds * float64(time.Second)), nil
+		}
+
+		audioSize := fileSize - start - int64(i)
+		if hasID3v1(r, fileSize) {
+			audioSize -= 128
+		}
+		return time.Duration(float64(audioSize*8) / float64(f.bitrate) * float64(time.Second)), nil
+	}
+
+	return 0, nil
+}
+
+func hasID3v1(r io.ReadSeeker, fileSize int64) bool {
+	if fileSize < 128 {
+		return false
+	}
+	if _, err := r.Seek(fileSize-128, io.SeekStart); err != nil {
+		return false
+	}
+	tag := make([]byte, 3)
+	_, err := io.ReadFull(r, tag)
+	return err == nil && string(tag) == "TAG"
+}
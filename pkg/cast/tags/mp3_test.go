@@ -0,0 +1,170 @@
+package tags
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func syncsafeBytes(n int) []byte {
+	return []byte{byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+}
+
+func id3Tag(version, flags byte, body []byte) []byte {
+	return cat([]byte{'I', 'D', '3', version, 0, flags}, syncsafeBytes(len(body)), body)
+}
+
+func id3Frame(version byte, id string, flags uint16, data []byte) []byte {
+	switch version {
+	case 2:
+		n := len(data)
+		return cat([]byte(id), []byte{byte(n >> 16), byte(n >> 8), byte(n)}, data)
+	case 3:
+		return cat([]byte(id), be32(uint32(len(data))), []byte{byte(flags >> 8), byte(flags)}, data)
+	}
+	return cat([]byte(id), syncsafeBytes(len(data)), []byte{byte(flags >> 8), byte(flags)}, data)
+}
+
+func latin1Text(s string) []byte {
+	return append([]byte{0}, s...)
+}
+
+// utf16Text encodes s, which must be ASCII, as little endian UTF-16 with a
+// BOM.
+func utf16Text(s string) []byte {
+	b := []byte{1, 0xff, 0xfe}
+	for _, c := range []byte(s) {
+		b = append(b, c, 0)
+	}
+	return b
+}
+
+func testID3v23() []byte {
+	frames := cat(
+		id3Frame(3, "TIT2", 0, latin1Text("Caf\xe9 \xff")),
+		id3Frame(3, "TPE1", 0, utf16Text("Artist")),
+		id3Frame(3, "TALB", 0, append([]byte{3}, "Album\x00"...)),
+		id3Frame(3, "TCON", 0, latin1Text("(17)Rock")),
+		id3Frame(3, "TRCK", 0, latin1Text("3/12")),
+		id3Frame(3, "TYER", 0, latin1Text("1999")),
+		id3Frame(3, "TLEN", 0, latin1Text("61000")),
+		// Compressed, so skipped.
+		id3Frame(3, "TCOM", 0x0080, latin1Text("Composer")),
+		id3Frame(3, "APIC", 0, cat([]byte{0}, []byte("image/jpeg\x00"), []byte{0}, []byte("other\x00"), jpeg)),
+		id3Frame(3, "APIC", 0, cat([]byte{0}, []byte("\x00"), []byte{frontCover}, []byte("\x00"), png)),
+	)
+	// An extended header, then the frames, unsynchronised as a whole.
+	body := cat(be32(6), make([]byte, 6), frames, make([]byte, 16))
+	return id3Tag(3, 0x80|0x40, unsync(body))
+}
+
+// unsync applies the unsynchronisation scheme.
+func unsync(b []byte) []byte {
+	return bytes.Replace(b, []byte{0xff}, []byte{0xff, 0x00}, -1)
+}
+
+func testID3v24() []byte {
+	unsynced := unsync(latin1Text("\xff\xfeTitle"))
+	frames := cat(
+		// Unsynchronised on its own, with a data length indicator.
+		id3Frame(4, "TIT2", 0x0003, cat(syncsafeBytes(7), unsynced)),
+		id3Frame(4, "TDRC", 0, latin1Text("2006-01-02")),
+		id3Frame(4, "TYER", 0, latin1Text("1999")),
+		id3Frame(4, "TPOS", 0, latin1Text("2")),
+		id3Frame(4, "TPE2", 0x0040, cat([]byte{1}, latin1Text("Band"))),
+	)
+	body := cat(syncsafeBytes(6), []byte{1, 0}, frames)
+	return id3Tag(4, 0x40, body)
+}
+
+func testID3v22() []byte {
+	return id3Tag(2, 0, cat(
+		id3Frame(2, "TT2", 0, latin1Text("Old")),
+		id3Frame(2, "TLE", 0, latin1Text("2000")),
+		id3Frame(2, "PIC", 0, cat([]byte{0}, []byte("JPG"), []byte{0}, []byte("\x00"), jpeg)),
+	))
+}
+
+func TestReadID3v2(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Tags
+	}{
+		{"v2.3", testID3v23(), Tags{
+			Title:       "Café ÿ",
+			Artist:      "Artist",
+			Album:       "Album",
+			Genre:       "Rock",
+			TrackNumber: 3,
+			Date:        "1999",
+			Duration:    61 * time.Second,
+			Picture:     &Picture{MIMEType: "image/png", Data: png},
+		}},
+		{"v2.4", testID3v24(), Tags{
+			Title:       "ÿþTitle",
+			Date:        "2006-01-02",
+			DiscNumber:  2,
+			AlbumArtist: "Band",
+		}},
+		{"v2.2", testID3v22(), Tags{
+			Title:    "Old",
+			Duration: 2 * time.Second,
+			Picture:  &Picture{MIMEType: "image/jpeg", Data: jpeg},
+		}},
+		{"genre only", id3Tag(3, 0, id3Frame(3, "TCON", 0, latin1Text("(17)"))), Tags{Genre: "(17)"}},
+		{"bad frame", id3Tag(3, 0, cat(
+			id3Frame(3, "TIT2", 0, latin1Text("Kept")),
+			[]byte("TPE1\x00\x00\x01\x00\x00\x00"),
+		)), Tags{Title: "Kept"}},
+	}
+	for _, test := range tests {
+		tags, err := ReadFrom(bytes.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if !reflect.DeepEqual(*tags, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, *tags, test.want)
+		}
+	}
+}
+
+func TestReadMP3Duration(t *testing.T) {
+	// MPEG 1 layer III, 128 kbit/s, 44.1 kHz, stereo.
+	frame := []byte{0xff, 0xfb, 0x90, 0x00}
+
+	cbr := cat(id3Tag(3, 0, nil), frame, make([]byte, 16000-len(frame)))
+	xing := cat(frame, make([]byte, 32), []byte("Xing"), be32(1), be32(100), make([]byte, 400))
+	withID3v1 := cat(cbr, []byte("TAG"), make([]byte, 125))
+
+	tests := []struct {
+		name string
+		data []byte
+		want time.Duration
+	}{
+		{"cbr", cbr, time.Second},
+		{"id3v1", withID3v1, time.Second},
+		{"xing", xing, 100 * 1152 * time.Second / 44100},
+		{"no frames", id3Tag(3, 0, nil), 0},
+	}
+	for _, test := range tests {
+		tags, err := ReadFrom(bytes.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if tags.Duration != test.want {
+			t.Errorf("%s: got %s, want %s", test.name, tags.Duration, test.want)
+		}
+	}
+}
+
+func TestReadID3v2Errors(t *testing.T) {
+	tests := map[string][]byte{
+		"truncated": id3Tag(3, 0, id3Frame(3, "TIT2", 0, latin1Text("Title")))[:15],
+		"too large": {'I', 'D', '3', 3, 0, 0, 0x7f, 0x7f, 0x7f, 0x7f},
+	}
+	for name, data := range tests {
+		if _, err := ReadFrom(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: read it", name)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package tags
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// mp4Containers are the atoms we look into, and how many bytes of their own
+// fields come before their children.
+var mp4Containers = map[string]int64{
+	"moov": 0,
+	"trak": 0,
+	"mdia": 0,
+	"udta": 0,
+	// meta is a full atom, with a version and flags.
+	"meta": 4,
+	"ilst": 0,
+}
+
+// mp4Items are the atoms of ilst we read. The copyright sign is 0xa9.
+var mp4Items = map[string]bool{
+	"\xa9nam": true,
+	"\xa9ART": true,
+	"aART":    true,
+	"\xa9alb": true,
+	"\xa9wrt": true,
+	"\xa9gen": true,
+	"\xa9day": true,
+	"trkn":    true,
+	"disk":    true,
+	"covr":    true,
+}
+
+// Types of the data atoms of cover art.
+const (
+	mp4JPEG = 13
+	mp4PNG  = 14
+)
+
+func readMP4(r io.ReadSeeker, t *Tags) error {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return readMP4Atoms(r, 0, end, "", t)
+}
+
+// readMP4Atoms reads the atoms between start and end, whose parent is
+// parent.
+func readMP4Atoms(r io.ReadSeeker, start, end int64, parent string, t *Tags) error {
+	for offset := start; offset+8 <= end; {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+
+		header := make([]byte, 8)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header))
+		kind := string(header[4:8])
+		headerSize := int64(8)
+
+		switch size {
+		case 0:
+			// The atom goes to the end of the file.
+			size = end - offset
+		case 1:
+			large := make([]byte, 8)
+			if _, err := io.ReadFull(r, large); err != nil {
+				return err
+			}
+			size = int64(binary.BigEndian.Uint64(large))
+			headerSize = 16
+		}
+		if size < headerSize || offset+size > end {
+			// A broken or truncated file, keep what we have.
+			return nil
+		}
+
+		body := offset + headerSize
+		next := offset + size
+
+		var err error
+		switch {
+		case kind == "mvhd":
+			err = readMP4Duration(r, size-headerSize, t)
+		case kind == "hdlr" && parent == "mdia":
+			err = readMP4Handler(r, size-headerSize, t)
+		case parent == "ilst" && mp4Items[kind]:
+			err = readMP4Item(r, body, next, kind, t)
+		case kind == "meta" && isQuickTimeMeta(r, body):
+			err = readMP4Atoms(r, body, next, kind, t)
+		default:
+			if skip, ok := mp4Containers[kind]; ok {
+				err = readMP4Atoms(r, body+skip, next, kind, t)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		offset = next
+	}
+	return nil
+}
+
+// isQuickTimeMeta reports whether the meta atom at body is a plain atom, as
+// QuickTime writes it, instead of a full one. Its first child then comes
+// right away.
+func isQuickTimeMeta(r io.ReadSeeker, body int64) bool {
+	if _, err := r.Seek(body, io.SeekStart); err != nil {
+		return false
+	}
+	b := make([]byte, 8)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return false
+	}
+	return string(b[4:8]) == "hdlr"
+}
+
+func readMP4Duration(r io.Reader, size int64, t *Tags) error {
+	if size > 1024 {
+		size = 1024
+	}
+	b, err := readFull(r, size)
+	if err != nil {
+		return err
+	}
+	if len(b) < 4 {
+		return nil
+	}
+
+	var timescale, duration uint64
+	if b[0] == 1 {
+		// Version 1 has 64 bit times.
+		if len(b) < 32 {
+			return nil
+		}
+		timescale = uint64(binary.BigEndian.Uint32(b[20:]))
+		duration = binary.BigEndian.Uint64(b[24:])
+	} else {
+		if len(b) < 20 {
+			return nil
+		}
+		timescale = uint64(binary.BigEndian.Uint32(b[12:]))
+		duration = uint64(binary.BigEndian.Uint32(b[16:]))
+	}
+
+	if timescale > 0 && duration > 0 && duration != 1<<32-1 {
+		t.Duration = time.Duration(float64(duration) / float64(timescale) * float64(time.Second))
+	}
+	return nil
+}
+
+func readMP4Handler(r io.Reader, size int64, t *Tags) error {
+	if size < 12 {
+		return nil
+	}
+	b, err := readFull(r, 12)
+	if err != nil {
+		return err
+	}
+	// Version and flags, pre-defined, then the handler type.
+	if string(b[8:12]) == "vide" {
+		t.HasVideo = true
+	}
+	return nil
+}
+
+// readMP4Item reads the data atom inside an item of the ilst atom.
+func readMP4Item(r io.ReadSeeker, start, end int64, kind string, t *Tags) error {
+	for offset := start; offset+16 <= end; {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		header := make([]byte, 16)
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+		size := int64(binary.BigEndian.Uint32(header))
+		if size < 16 || offset+size > end {
+			return nil
+		}
+		if string(header[4:8]) != "data" {
+			offset += size
+			continue
+		}
+
+		dataType := binary.BigEndian.Uint32(header[8:12]) & 0xffffff
+		// The rest of the header is the locale.
+		value, err := readFull(r, size-16)
+		if err != nil {
+			return err
+		}
+		setMP4Item(t, kind, dataType, value)
+		return nil
+	}
+	return nil
+}
+
+func setMP4Item(t *Tags, kind string, dataType uint32, value []byte) {
+	text := string(value)
+	switch kind {
+	case "\xa9nam":
+		t.Title = text
+	case "\xa9ART":
+		t.Artist = text
+	case "aART":
+		t.AlbumArtist = text
+	case "\xa9alb":
+		t.Album = text
+	case "\xa9wrt":
+		t.Composer = text
+	case "\xa9gen":
+		t.Genre = text
+	case "\xa9day":
+		t.Date = text
+	case "trkn", "disk":
+		// Two reserved bytes, the number and the total.
+		if len(value) < 4 {
+			return
+		}
+		n := int(binary.BigEndian.Uint16(value[2:]))
+		if kind == "trkn" {
+			t.TrackNumber = n
+		} else {
+			t.DiscNumber = n
+		}
+	case "covr":
+		if t.Picture != nil || len(value) == 0 {
+			return
+		}
+		p := &Picture{Data: value}
+		switch dataType {
+		case mp4JPEG:
+			p.MIMEType = "image/jpeg"
+		case mp4PNG:
+			p.MIMEType = "image/png"
+		default:
+			p.MIMEType = detectPictureType(value)
+		}
+		t.Picture = p
+	}
+}
@@ -0,0 +1,102 @@
+package tags
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func atom(kind string, body ...[]byte) []byte {
+	b := cat(body...)
+	return cat(be32(uint32(8+len(b))), []byte(kind), b)
+}
+
+func fullAtom(kind string, body ...[]byte) []byte {
+	return atom(kind, append([][]byte{{0, 0, 0, 0}}, body...)...)
+}
+
+func dataAtom(dataType uint32, value []byte) []byte {
+	return atom("data", be32(dataType), be32(0), value)
+}
+
+func mvhd(timescale, duration uint32) []byte {
+	return fullAtom("mvhd", be32(0), be32(0), be32(timescale), be32(duration), make([]byte, 80))
+}
+
+func handler(kind string) []byte {
+	return fullAtom("hdlr", be32(0), []byte(kind), make([]byte, 12))
+}
+
+func testMP4() []byte {
+	return cat(
+		atom("ftyp", []byte("M4A "), be32(0)),
+		atom("moov",
+			mvhd(1000, 90500),
+			atom("trak", atom("mdia", handler("soun"))),
+			atom("udta", fullAtom("meta",
+				handler("mdir"),
+				atom("ilst",
+					atom("\xa9nam", dataAtom(1, []byte("Title"))),
+					atom("\xa9ART", dataAtom(1, []byte("Artist"))),
+					atom("aART", dataAtom(1, []byte("Band"))),
+					atom("\xa9alb", dataAtom(1, []byte("Album"))),
+					atom("\xa9wrt", dataAtom(1, []byte("Composer"))),
+					atom("\xa9gen", dataAtom(1, []byte("Genre"))),
+					atom("\xa9day", dataAtom(1, []byte("2006"))),
+					atom("trkn", dataAtom(0, []byte{0, 0, 0, 3, 0, 12, 0, 0})),
+					atom("disk", dataAtom(0, []byte{0, 0, 0, 1, 0, 2})),
+					atom("covr", dataAtom(mp4PNG, png), dataAtom(mp4JPEG, jpeg)),
+					atom("\xa9too", dataAtom(1, []byte("Encoder"))),
+				),
+			)),
+		),
+		atom("mdat", make([]byte, 16)),
+	)
+}
+
+func TestReadMP4(t *testing.T) {
+	ftyp := atom("ftyp", []byte("isom"), be32(0))
+	tests := []struct {
+		name string
+		data []byte
+		want Tags
+	}{
+		{"itunes", testMP4(), Tags{
+			Title:       "Title",
+			Artist:      "Artist",
+			AlbumArtist: "Band",
+			Album:       "Album",
+			Composer:    "Composer",
+			Genre:       "Genre",
+			Date:        "2006",
+			TrackNumber: 3,
+			DiscNumber:  1,
+			Duration:    90500 * time.Millisecond,
+			Picture:     &Picture{MIMEType: "image/png", Data: png},
+		}},
+		{"video", cat(ftyp, atom("moov",
+			atom("trak", atom("mdia", handler("vide"))),
+			// QuickTime writes a plain meta atom.
+			atom("meta", handler("mdta"), atom("ilst", atom("covr", dataAtom(0, jpeg)))),
+		)), Tags{HasVideo: true, Picture: &Picture{MIMEType: "image/jpeg", Data: jpeg}}},
+		{"64 bit duration", cat(ftyp, atom("moov",
+			atom("mvhd", []byte{1, 0, 0, 0}, make([]byte, 16), be32(10), be32(0), be32(25), make([]byte, 80)),
+		)), Tags{Duration: 2500 * time.Millisecond}},
+		{"unknown duration", cat(ftyp, atom("moov", mvhd(1000, 1<<32-1))), Tags{}},
+		{"large size", cat(ftyp,
+			be32(1), []byte("moov"), []byte{0, 0, 0, 0}, be32(16+108), mvhd(1, 5),
+		), Tags{Duration: 5 * time.Second}},
+		{"to the end", cat(ftyp, be32(0), []byte("moov"), mvhd(1, 7)), Tags{Duration: 7 * time.Second}},
+		{"too large", cat(ftyp, atom("moov", mvhd(1, 3)), be32(1000), []byte("moov"), mvhd(1, 9)),
+			Tags{Duration: 3 * time.Second}},
+	}
+	for _, test := range tests {
+		tags, err := ReadFrom(bytes.NewReader(test.data))
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+		} else if !reflect.DeepEqual(*tags, test.want) {
+			t.Errorf("%s: got %+v, want %+v", test.name, *tags, test.want)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Package tags reads the metadata embedded in media files: ID3v2 tags of
+// MP3 files, MP4/M4A atoms, FLAC Vorbis comments and Matroska tags.
+package tags
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+var UnknownFormat = errors.New("Unknown media format")
+
+// maxElementSize limits how much we read of a single tag or picture, so that
+// a broken file can't make us allocate huge buffers.
+const maxElementSize = 32 << 20
+
+type Picture struct {
+	MIMEType string
+	Data     []byte
+}
+
+// Tags has the metadata of a media file. Fields the file doesn't have are
+// left empty.
+type Tags struct {
+	Title       string
+	Artist      string
+	Album       string
+	AlbumArtist string
+	Composer    string
+	Genre       string
+	// Date is the release date or year, as found in the file, e.g. "2006"
+	// or "2006-01-02".
+	Date        string
+	TrackNumber int
+	DiscNumber  int
+	// Duration is zero if the length of the media is unknown.
+	Duration time.Duration
+	// Picture is the cover art, if there is one.
+	Picture *Picture
+	// HasVideo tells movies from music.
+	HasVideo bool
+}
+
+func Read(path string) (*Tags, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadFrom(f)
+}
+
+// ReadFrom reads the tags of a media file, finding out its format from its
+// first bytes.
+func ReadFrom(r io.ReadSeeker) (*Tags, error) {
+	head := make([]byte, 12)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	t := &Tags{}
+	switch {
+	case bytes.HasPrefix(head, []byte("ID3")):
+		err = readMP3(r, t)
+	case isMPEGFrame(head):
+		err = readMP3(r, t)
+	case bytes.HasPrefix(head, []byte("fLaC")):
+		err = readFLAC(r, t)
+	case bytes.HasPrefix(head, []byte{0x1a, 0x45, 0xdf, 0xa3}):
+		err = readMatroska(r, t)
+	case len(head) >= 8 && bytes.Equal(head[4:8], []byte("ftyp")):
+		err = readMP4(r, t)
+	default:
+		return nil, UnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// readFull reads n bytes, refusing to allocate more than maxElementSize.
+func readFull(r io.Reader, n int64) ([]byte, error) {
+	if n < 0 || n > maxElementSize {
+		return nil, fmt.Errorf("element too large (%d bytes)", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// parseNumber parses the leading number of strings like "3" or "3/12",
+// which is how track and disc numbers are usually written.
+func parseNumber(s string) int {
+	n := 0
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			break
+		}
+		n = n*10 + int(r-'0')
+	}
+	return n
+}
+
+// detectPictureType guesses the type of an image that came without one.
+func detectPictureType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xff, 0xd8, 0xff}):
+		return "image/jpeg"
+	case bytes.HasPrefix(data, []byte("\x89PNG")):
+		return "image/png"
+	case bytes.HasPrefix(data, []byte("GIF8")):
+		return "image/gif"
+	case len(data) >= 12 && bytes.Equal(data[8:12], []byte("WEBP")):
+		return "image/webp"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,80 @@
+package tags
+
+import (
+	"bytes"
+	"testing"
+)
+
+var (
+	jpeg = []byte("\xff\xd8\xff\xe0jpeg")
+	png  = []byte("\x89PNG\r\n\x1a\npng")
+)
+
+// cat joins byte slices, so that test files can be written as a tree.
+func cat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func be32(n uint32) []byte {
+	return []byte{byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n)}
+}
+
+func TestReadFromUnknownFormat(t *testing.T) {
+	for _, data := range [][]byte{[]byte("ID"), []byte("RIFF\x00\x00\x00\x00WAVE")} {
+		if _, err := ReadFrom(bytes.NewReader(data)); err != UnknownFormat {
+			t.Errorf("%q: got %v, want %v", data, err, UnknownFormat)
+		}
+	}
+}
+
+// TestReadFromTruncated reads every prefix of valid files, which must fail
+// or give partial tags, but never panic.
+func TestReadFromTruncated(t *testing.T) {
+	files := map[string][]byte{
+		"id3v2.3": testID3v23(),
+		"id3v2.4": testID3v24(),
+		"id3v2.2": testID3v22(),
+		"mp4":     testMP4(),
+		"flac":    testFLAC(),
+		"mkv":     testMatroska(),
+	}
+	for name, data := range files {
+		for n := 0; n < len(data); n++ {
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						t.Errorf("%s: panicked reading %d of %d bytes: %v", name, n, len(data), r)
+					}
+				}()
+				ReadFrom(bytes.NewReader(data[:n]))
+			}()
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := map[string]int{"": 0, "3": 3, "03/12": 3, "12 of 20": 12, "x": 0}
+	for s, want := range tests {
+		if n := parseNumber(s); n != want {
+			t.Errorf("%q: got %d, want %d", s, n, want)
+		}
+	}
+}
+
+func TestDetectPictureType(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want string
+	}{
+		{jpeg, "image/jpeg"},
+		{png, "image/png"},
+		{[]byte("GIF89a"), "image/gif"},
+		{[]byte("RIFF\x00\x00\x00\x00WEBPVP8 "), "image/webp"},
+		{[]byte("BM"), "application/octet-stream"},
+	}
+	for _, test := range tests {
+		if got := detectPictureType(test.data); got != test.want {
+			t.Errorf("%q: got %s, want %s", test.data, got, test.want)
+		}
+	}
+}